@@ -0,0 +1,109 @@
+// Package cbor defines the CBOR (RFC 8949) codec.
+package cbor
+
+import (
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/sraphs/encoding/internal/protomap"
+	"github.com/sraphs/encoding/merge"
+	"github.com/sraphs/encoding/validate"
+)
+
+// Name is the name registered for the cbor codec.
+const Name = "cbor"
+
+var (
+	// EncOptions is a configurable CBOR encoder. It defaults to the core
+	// deterministic encoding of RFC 8949 section 4.2.1, so equal values always
+	// encode to the same bytes, with timestamps as tag 0 RFC 3339 strings and
+	// big.Int as tag 2/3 bignums when they do not fit in an integer.
+	EncOptions = coreDetEncOptions()
+	// DecOptions is a configurable CBOR decoder.
+	DecOptions = cbor.DecOptions{
+		DupMapKey: cbor.DupMapKeyEnforcedAPF,
+		TimeTag:   cbor.DecTagOptional,
+	}
+	// ProtoOptions configures how proto messages are mapped, by JSON name by default.
+	ProtoOptions = protomap.Options{}
+)
+
+func coreDetEncOptions() cbor.EncOptions {
+	opts := cbor.CoreDetEncOptions()
+	opts.Time = cbor.TimeRFC3339Nano
+	opts.TimeTag = cbor.EncTagRequired
+	opts.BigIntConvert = cbor.BigIntConvertShortest
+	return opts
+}
+
+// Codec is a Codec implementation with CBOR.
+type Codec struct {
+	// Merge is how Unmarshal combines data with the fields of the proto
+	// message already set. If zero, the fields of data are set in the
+	// message, appending to its lists.
+	Merge merge.Mode
+	// Validate validates the values decoded by Unmarshal, such as with their
+	// Validate methods, failing with a *validate.Error. No validation if nil.
+	Validate *validate.Registry
+}
+
+func (Codec) Marshal(v interface{}) ([]byte, error) {
+	em, err := EncOptions.EncMode()
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := v.(proto.Message); ok {
+		pv, err := ProtoOptions.Marshal(m.ProtoReflect())
+		if err != nil {
+			return nil, err
+		}
+		return em.Marshal(pv)
+	}
+	return em.Marshal(v)
+}
+
+func (c Codec) Unmarshal(data []byte, v interface{}) error {
+	if err := c.unmarshal(data, v); err != nil {
+		return err
+	}
+	return c.Validate.Validate(c.Name(), v)
+}
+
+func (c Codec) unmarshal(data []byte, v interface{}) error {
+	dm, err := DecOptions.DecMode()
+	if err != nil {
+		return err
+	}
+	if m, ok := v.(proto.Message); ok {
+		return c.Merge.Decode(m, func(m proto.Message) error {
+			return unmarshalProto(dm, data, m)
+		})
+	}
+	rv := reflect.ValueOf(v)
+	for rv := rv; rv.Kind() == reflect.Ptr; {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		rv = rv.Elem()
+	}
+	if m, ok := reflect.Indirect(rv).Interface().(proto.Message); ok {
+		return c.Merge.Decode(m, func(m proto.Message) error {
+			return unmarshalProto(dm, data, m)
+		})
+	}
+	return dm.Unmarshal(data, v)
+}
+
+func (Codec) Name() string {
+	return Name
+}
+
+func unmarshalProto(dm cbor.DecMode, data []byte, m proto.Message) error {
+	var pv interface{}
+	if err := dm.Unmarshal(data, &pv); err != nil {
+		return err
+	}
+	return ProtoOptions.Unmarshal(pv, m.ProtoReflect())
+}
@@ -0,0 +1,91 @@
+package cbor
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	testData "github.com/sraphs/encoding/internal/testdata/complex"
+)
+
+type device struct {
+	ID      string    `cbor:"id"`
+	Seen    time.Time `cbor:"seen"`
+	Counter *big.Int  `cbor:"counter"`
+}
+
+func TestCodec_Deterministic(t *testing.T) {
+	a := map[string]interface{}{"b": 1, "a": 2, "aa": []int{1}, "c": 1.5}
+	b := map[string]interface{}{"c": 1.5, "aa": []int{1}, "a": 2, "b": 1}
+
+	ba, err := (Codec{}).Marshal(a)
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		bb, err := (Codec{}).Marshal(b)
+		require.NoError(t, err)
+		assert.Equal(t, ba, bb)
+	}
+	// core deterministic order sorts shorter keys first, then bytewise.
+	assert.Equal(t, []byte{0xa4, 0x61, 'a', 0x02, 0x61, 'b', 0x01, 0x61, 'c', 0xf9, 0x3e, 0x00, 0x62, 'a', 'a', 0x81, 0x01}, ba)
+}
+
+func TestCodec_Tags(t *testing.T) {
+	counter, _ := new(big.Int).SetString("18446744073709551616", 10)
+	in := &device{ID: "d1", Seen: time.Unix(20, 2).UTC(), Counter: counter}
+
+	b, err := (Codec{}).Marshal(in)
+	require.NoError(t, err)
+	// tag 0 date/time string and tag 2 positive bignum.
+	assert.True(t, bytes.Contains(b, []byte{0xc0, 0x78}), "got %x", b)
+	assert.True(t, bytes.Contains(b, []byte{0xc2, 0x49, 0x01}), "got %x", b)
+
+	out := &device{}
+	require.NoError(t, (Codec{}).Unmarshal(b, out))
+	assert.Equal(t, in.ID, out.ID)
+	assert.True(t, in.Seen.Equal(out.Seen))
+	assert.Equal(t, 0, in.Counter.Cmp(out.Counter))
+}
+
+func TestCodec_Proto(t *testing.T) {
+	in := &testData.Complex{
+		Id:        2233,
+		NoOne:     "2233",
+		Simple:    &testData.Simple{Component: "5566"},
+		Simples:   []string{"3344", "5566"},
+		B:         true,
+		Sex:       testData.Sex_woman,
+		Count:     3,
+		Price:     11.23,
+		Byte:      []byte("123"),
+		Map:       map[string]string{"sraph": "https://sraph.com/", "a": "b"},
+		Timestamp: &timestamppb.Timestamp{Seconds: 20, Nanos: 2},
+		Duration:  &durationpb.Duration{Seconds: 120, Nanos: 22},
+		Field:     &fieldmaskpb.FieldMask{Paths: []string{"a.b", "b.c"}},
+		Float:     &wrapperspb.FloatValue{Value: 12.34},
+		Bytes:     &wrapperspb.BytesValue{Value: []byte("123")},
+	}
+
+	b, err := (Codec{}).Marshal(in)
+	require.NoError(t, err)
+	b2, err := (Codec{}).Marshal(proto.Clone(in))
+	require.NoError(t, err)
+	assert.Equal(t, b, b2)
+
+	var raw map[string]interface{}
+	require.NoError(t, (Codec{}).Unmarshal(b, &raw))
+	assert.Equal(t, "2233", raw["numberOne"])
+	assert.Equal(t, "woman", raw["sex"])
+
+	out := &testData.Complex{}
+	require.NoError(t, (Codec{}).Unmarshal(b, &out))
+	assert.True(t, proto.Equal(in, out), "got %v", out)
+}
@@ -0,0 +1,491 @@
+// Package csv defines the CSV and TSV codecs for tabular data.
+//
+// Rows are slices of Go structs, slices of proto messages, or the single
+// repeated message field of a proto message such as a List response. Columns
+// are the flattened field paths of the rows, such as address.city or
+// items[0].name for the lists of messages. Lists of scalars are written in one
+// cell, their items joined by the list separator; a backslash escapes the
+// separators and the backslashes in the items.
+package csv
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/sraphs/encoding/internal/protopath"
+	ejson "github.com/sraphs/encoding/json"
+	"github.com/sraphs/encoding/merge"
+	"github.com/sraphs/encoding/validate"
+)
+
+const (
+	// Name is the name registered for the csv codec.
+	Name = "csv"
+	// TSVName is the name registered for the csv codec using tab delimiters.
+	TSVName = "tab-separated-values"
+)
+
+// Codec is a Codec implementation with CSV.
+type Codec struct {
+	// Comma is the field delimiter, ',' by default. Use '\t' for TSV.
+	Comma rune
+	// Separator joins the segments of a field path in the header, "." by default.
+	Separator string
+	// ListSeparator joins the items of a list in a cell, "," by default.
+	ListSeparator string
+	// Prefix is prepended to the column names, such as "user.". Unmarshal
+	// ignores the columns without it.
+	Prefix string
+	// UseProtoNames names the columns of proto message fields by their proto
+	// names instead of their JSON names. Unmarshal reads both.
+	UseProtoNames bool
+	// Merge is how Unmarshal combines data with the fields of the proto
+	// message already set. If zero, the rows of data are appended to its
+	// repeated message field.
+	Merge merge.Mode
+	// Validate validates the values decoded by Unmarshal, such as with their
+	// Validate methods, failing with a *validate.Error. No validation if nil.
+	Validate *validate.Registry
+}
+
+func (c Codec) Marshal(v interface{}) ([]byte, error) {
+	rows, err := c.flattenRows(v)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var header []string
+	for _, row := range rows {
+		for k := range row {
+			if !seen[k] {
+				seen[k] = true
+				header = append(header, k)
+			}
+		}
+	}
+	sort.Strings(header)
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Comma = c.comma()
+	names := make([]string, len(header))
+	for i, k := range header {
+		names[i] = c.Prefix + k
+	}
+	if err := w.Write(names); err != nil {
+		return nil, err
+	}
+	record := make([]string, len(header))
+	for _, row := range rows {
+		for i, k := range header {
+			record[i] = row[k]
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func (c Codec) Unmarshal(data []byte, v interface{}) error {
+	if err := c.unmarshal(data, v); err != nil {
+		return err
+	}
+	return c.Validate.Validate(c.Name(), v)
+}
+
+func (c Codec) unmarshal(data []byte, v interface{}) error {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = c.comma()
+	records, err := r.ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	header, records := c.keys(records[0]), records[1:]
+
+	if m, ok := v.(proto.Message); ok {
+		return c.Merge.Decode(m, func(m proto.Message) error {
+			return c.unmarshalRepeated(header, records, m.ProtoReflect())
+		})
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("csv: cannot unmarshal into %T, want a pointer to a slice or a proto message", v)
+	}
+	slice := rv.Elem()
+	for line, record := range records {
+		ev := reflect.New(slice.Type().Elem())
+		if err := c.unmarshalRow(header, record, ev.Interface()); err != nil {
+			// line 1 is the header.
+			return fmt.Errorf("csv: line %d: %w", line+2, err)
+		}
+		slice.Set(reflect.Append(slice, ev.Elem()))
+	}
+	return nil
+}
+
+func (c Codec) Name() string {
+	if c.Comma == '\t' {
+		return TSVName
+	}
+	return Name
+}
+
+func (c Codec) comma() rune {
+	if c.Comma == 0 {
+		return ','
+	}
+	return c.Comma
+}
+
+func (c Codec) separator() string {
+	if c.Separator == "" {
+		return "."
+	}
+	return c.Separator
+}
+
+func (c Codec) listSeparator() string {
+	if c.ListSeparator == "" {
+		return ","
+	}
+	return c.ListSeparator
+}
+
+// keys returns the field paths of the columns of header, empty for the
+// columns without the prefix.
+func (c Codec) keys(header []string) []string {
+	keys := make([]string, len(header))
+	for i, name := range header {
+		if strings.HasPrefix(name, c.Prefix) {
+			keys[i] = strings.TrimPrefix(name, c.Prefix)
+		}
+	}
+	return keys
+}
+
+// flattenRows returns the flattened form of every row in v.
+func (c Codec) flattenRows(v interface{}) ([]map[string]string, error) {
+	if m, ok := v.(proto.Message); ok {
+		fd, err := repeatedMessageField(m.ProtoReflect().Descriptor())
+		if err != nil {
+			return nil, err
+		}
+		list := m.ProtoReflect().Get(fd).List()
+		rows := make([]map[string]string, 0, list.Len())
+		for i := 0; i < list.Len(); i++ {
+			row, err := c.flattenRow(list.Get(i).Message().Interface())
+			if err != nil {
+				return nil, err
+			}
+			rows = append(rows, row)
+		}
+		return rows, nil
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		row, err := c.flattenRow(v)
+		if err != nil {
+			return nil, err
+		}
+		return []map[string]string{row}, nil
+	}
+	rows := make([]map[string]string, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		row, err := c.flattenRow(rv.Index(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// flattenRow flattens the JSON form of v, the same form the json codec
+// produces for Go structs and proto messages.
+func (c Codec) flattenRow(v interface{}) (map[string]string, error) {
+	var b []byte
+	var err error
+	if m, ok := v.(proto.Message); ok {
+		opts := ejson.MarshalOptions
+		opts.UseProtoNames = c.UseProtoNames
+		b, err = opts.Marshal(m)
+	} else {
+		b, err = ejson.Codec{}.Marshal(v)
+	}
+	if err != nil {
+		return nil, err
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var doc interface{}
+	if err := d.Decode(&doc); err != nil {
+		return nil, err
+	}
+	row := make(map[string]string)
+	c.flatten("", doc, row)
+	return row, nil
+}
+
+func (c Codec) flatten(prefix string, v interface{}, dst map[string]string) {
+	switch x := v.(type) {
+	case nil:
+	case map[string]interface{}:
+		for k, e := range x {
+			if prefix != "" {
+				k = prefix + c.separator() + k
+			}
+			c.flatten(k, e, dst)
+		}
+	case []interface{}:
+		var items []string
+		for i, e := range x {
+			switch e.(type) {
+			case map[string]interface{}, []interface{}:
+				c.flatten(fmt.Sprintf("%s[%d]", prefix, i), e, dst)
+			default:
+				items = append(items, fmt.Sprint(e))
+			}
+		}
+		if len(items) > 0 || len(x) == 0 {
+			dst[prefix] = joinList(items, c.listSeparator())
+		}
+	default:
+		dst[prefix] = fmt.Sprint(x)
+	}
+}
+
+func (c Codec) unmarshalRepeated(header []string, records [][]string, m protoreflect.Message) error {
+	fd, err := repeatedMessageField(m.Descriptor())
+	if err != nil {
+		return err
+	}
+	list := m.Mutable(fd).List()
+	for line, record := range records {
+		ev := list.NewElement()
+		if err := c.unmarshalRow(header, record, ev.Message().Interface()); err != nil {
+			return fmt.Errorf("csv: line %d: %w", line+2, err)
+		}
+		list.Append(ev)
+	}
+	return nil
+}
+
+func (c Codec) unmarshalRow(header, record []string, v interface{}) error {
+	if len(record) != len(header) {
+		return fmt.Errorf("got %d fields, want %d", len(record), len(header))
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && rv.Elem().Kind() == reflect.Ptr {
+		if rv.Elem().IsNil() {
+			rv.Elem().Set(reflect.New(rv.Elem().Type().Elem()))
+		}
+		rv = rv.Elem()
+	}
+	if m, ok := rv.Interface().(proto.Message); ok {
+		values := make(map[string]string, len(header))
+		for i, k := range header {
+			if k == "" || record[i] == "" {
+				continue
+			}
+			values[k] = record[i]
+		}
+		return c.decoder().DecodeStrings(m, values)
+	}
+
+	values := make(map[string]interface{}, len(header))
+	for i, k := range header {
+		if k == "" || record[i] == "" {
+			continue
+		}
+		values[k] = record[i]
+	}
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Result:           rv.Interface(),
+		TagName:          "json",
+		WeaklyTypedInput: true,
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			c.stringToSliceHook,
+		),
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(c.unflatten(values))
+}
+
+// stringToSliceHook splits the cells decoded into slices with splitList.
+func (c Codec) stringToSliceHook(f, t reflect.Kind, data interface{}) (interface{}, error) {
+	if f != reflect.String || t != reflect.Slice {
+		return data, nil
+	}
+	if data.(string) == "" {
+		return []string{}, nil
+	}
+	return splitList(data.(string), c.listSeparator()), nil
+}
+
+// unflatten nests the values keyed by the field paths of the columns, the
+// indexed path elements such as items[0] making the elements of slices.
+func (c Codec) unflatten(values map[string]interface{}) interface{} {
+	root := make(map[string]interface{})
+	for k, v := range values {
+		var path []string
+		for _, elem := range strings.Split(k, c.separator()) {
+			path = append(path, splitIndexes(elem)...)
+		}
+		m := root
+		for _, p := range path[:len(path)-1] {
+			sub, ok := m[p].(map[string]interface{})
+			if !ok {
+				sub = make(map[string]interface{})
+				m[p] = sub
+			}
+			m = sub
+		}
+		m[path[len(path)-1]] = v
+	}
+	return listify(root)
+}
+
+// splitIndexes splits a path element such as items[0] into its name and its
+// indexes, kept in brackets.
+func splitIndexes(elem string) []string {
+	i := strings.IndexByte(elem, '[')
+	if i <= 0 || !strings.HasSuffix(elem, "]") {
+		return []string{elem}
+	}
+	path := []string{elem[:i]}
+	for _, index := range strings.Split(elem[i+1:len(elem)-1], "][") {
+		path = append(path, "["+index+"]")
+	}
+	return path
+}
+
+// listify replaces the maps keyed by indexes with slices of their values, in
+// the order of the indexes.
+func listify(v interface{}) interface{} {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return v
+	}
+	type item struct {
+		index int
+		value interface{}
+	}
+	items := make([]item, 0, len(m))
+	for k, e := range m {
+		e = listify(e)
+		m[k] = e
+		if !strings.HasPrefix(k, "[") || !strings.HasSuffix(k, "]") {
+			continue
+		}
+		if n, err := strconv.Atoi(k[1 : len(k)-1]); err == nil && n >= 0 {
+			items = append(items, item{n, e})
+		}
+	}
+	if len(items) == 0 || len(items) != len(m) {
+		return m
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].index < items[j].index })
+	list := make([]interface{}, len(items))
+	for i, it := range items {
+		list[i] = it.value
+	}
+	return list
+}
+
+// joinList joins the items of a list in a cell, escaping sep and the
+// backslashes in the items with a backslash.
+func joinList(items []string, sep string) string {
+	escaped := make([]string, len(items))
+	for i, item := range items {
+		item = strings.ReplaceAll(item, `\`, `\\`)
+		escaped[i] = strings.ReplaceAll(item, sep, `\`+sep)
+	}
+	return strings.Join(escaped, sep)
+}
+
+// splitList splits a cell into the items of a list, the reverse of joinList.
+func splitList(cell, sep string) []string {
+	var items []string
+	var b strings.Builder
+	for i := 0; i < len(cell); {
+		switch {
+		case cell[i] == '\\' && i+1 < len(cell):
+			if strings.HasPrefix(cell[i+1:], sep) {
+				b.WriteString(sep)
+				i += 1 + len(sep)
+			} else {
+				b.WriteByte(cell[i+1])
+				i += 2
+			}
+		case strings.HasPrefix(cell[i:], sep):
+			items = append(items, b.String())
+			b.Reset()
+			i += len(sep)
+		default:
+			b.WriteByte(cell[i])
+			i++
+		}
+	}
+	return append(items, b.String())
+}
+
+// decoder returns the proto decoder of the header keys and list cells.
+func (c Codec) decoder() protopath.Decoder {
+	return protopath.Decoder{
+		SplitKey:  protopath.SplitOn(c.separator()),
+		SplitList: c.splitList,
+		Field:     protopath.ByNameOrSnake,
+	}
+}
+
+// splitList splits the cells of list fields into their items.
+func (c Codec) splitList(values []string) []string {
+	var items []string
+	for _, v := range values {
+		items = append(items, splitList(v, c.listSeparator())...)
+	}
+	return items
+}
+
+// repeatedMessageField returns the only repeated message field of md.
+func repeatedMessageField(md protoreflect.MessageDescriptor) (protoreflect.FieldDescriptor, error) {
+	var found protoreflect.FieldDescriptor
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if !fd.IsList() || fd.Message() == nil {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("csv: %s has more than one repeated message field", md.FullName())
+		}
+		found = fd
+	}
+	if found == nil {
+		return nil, errors.New("csv: " + string(md.FullName()) + " has no repeated message field")
+	}
+	return found, nil
+}
@@ -0,0 +1,174 @@
+package csv
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	testData "github.com/sraphs/encoding/internal/testdata/complex"
+)
+
+type address struct {
+	City string `json:"city"`
+}
+
+type user struct {
+	Name    string        `json:"name"`
+	Age     int           `json:"age"`
+	Tags    []string      `json:"tags"`
+	Address address       `json:"address"`
+	Timeout time.Duration `json:"timeout"`
+}
+
+func TestCodec_Struct(t *testing.T) {
+	in := []user{
+		{Name: "sraph", Age: 18, Tags: []string{"a", "b"}, Address: address{City: "x, y"}},
+		{Name: "kratos", Tags: []string{}},
+	}
+
+	b, err := (Codec{}).Marshal(in)
+	require.NoError(t, err)
+	assert.Equal(t, "address.city,age,name,tags,timeout\n\"x, y\",18,sraph,\"a,b\",0\n,0,kratos,,0\n", string(b))
+
+	var out []user
+	require.NoError(t, (Codec{}).Unmarshal(b, &out))
+	assert.Equal(t, []user{
+		{Name: "sraph", Age: 18, Tags: []string{"a", "b"}, Address: address{City: "x, y"}},
+		{Name: "kratos"},
+	}, out)
+}
+
+func TestCodec_TSV(t *testing.T) {
+	c := Codec{Comma: '\t', Separator: "_", ListSeparator: "|"}
+	assert.Equal(t, "tab-separated-values", c.Name())
+
+	in := []*user{{Name: "sraph", Tags: []string{"a", "b"}, Address: address{City: "x"}}}
+	b, err := c.Marshal(in)
+	require.NoError(t, err)
+	assert.Equal(t, "address_city\tage\tname\ttags\ttimeout\nx\t0\tsraph\ta|b\t0\n", string(b))
+
+	var out []*user
+	require.NoError(t, c.Unmarshal(b, &out))
+	assert.Equal(t, in, out)
+}
+
+func TestCodec_ProtoSlice(t *testing.T) {
+	c := Codec{ListSeparator: ";"}
+	in := []*testData.Complex{
+		{
+			Id:       1,
+			NoOne:    "2233",
+			Simple:   &testData.Simple{Component: "5566"},
+			Simples:  []string{"3344", "5566"},
+			Sex:      testData.Sex_woman,
+			Map:      map[string]string{"sraph": "https://sraph.com/"},
+			Duration: &durationpb.Duration{Seconds: 120},
+			Int32:    &wrapperspb.Int32Value{Value: 32},
+		},
+		{Id: 2},
+	}
+
+	b, err := c.Marshal(in)
+	require.NoError(t, err)
+
+	var out []*testData.Complex
+	require.NoError(t, c.Unmarshal(b, &out))
+	require.Len(t, out, 2)
+	for i := range in {
+		assert.True(t, proto.Equal(in[i], out[i]), "row %d: got %v", i, out[i])
+	}
+}
+
+func TestCodec_RepeatedField(t *testing.T) {
+	in := &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{
+			{Name: proto.String("a.proto"), Package: proto.String("a"), Dependency: []string{"b.proto", "c.proto"}},
+			{Name: proto.String("b.proto"), Syntax: proto.String("proto3")},
+		},
+	}
+
+	b, err := (Codec{}).Marshal(in)
+	require.NoError(t, err)
+	assert.Equal(t, "dependency,enumType,extension,messageType,name,package,publicDependency,service,syntax,weakDependency\n"+
+		"\"b.proto,c.proto\",,,,a.proto,a,,,,\n"+
+		",,,,b.proto,,,,proto3,\n", string(b))
+
+	out := &descriptorpb.FileDescriptorSet{}
+	require.NoError(t, (Codec{}).Unmarshal(b, out))
+	assert.True(t, proto.Equal(in, out), "got %v", out)
+
+	_, err = (Codec{}).Marshal(&testData.Complex{})
+	assert.Error(t, err)
+}
+
+func TestCodec_UnmarshalErrors(t *testing.T) {
+	var out []user
+	err := (Codec{}).Unmarshal([]byte("name,age\nsraph,x\n"), &out)
+	assert.EqualError(t, err, "csv: line 2: 1 error(s) decoding:\n\n* cannot parse 'age' as int: strconv.ParseInt: parsing \"x\": invalid syntax")
+
+	err = (Codec{}).Unmarshal([]byte("name\n"), out)
+	assert.Error(t, err)
+}
+
+type item struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+type order struct {
+	ID    string `json:"id"`
+	Items []item `json:"items"`
+}
+
+func TestCodec_Lists(t *testing.T) {
+	in := []order{
+		{ID: "1", Items: []item{{Name: "a", Tags: []string{"x,y", `c:\`}}, {Name: "b"}}},
+		{ID: "2", Items: []item{}},
+	}
+
+	b, err := (Codec{}).Marshal(in)
+	require.NoError(t, err)
+	assert.Equal(t, "id,items,items[0].name,items[0].tags,items[1].name\n"+
+		"1,,a,\"x\\,y,c:\\\\\",b\n"+
+		"2,,,,\n", string(b))
+
+	var out []order
+	require.NoError(t, (Codec{}).Unmarshal(b, &out))
+	assert.Equal(t, []order{
+		{ID: "1", Items: []item{{Name: "a", Tags: []string{"x,y", `c:\`}}, {Name: "b"}}},
+		{ID: "2"},
+	}, out)
+}
+
+func TestCodec_Header(t *testing.T) {
+	c := Codec{Prefix: "file.", UseProtoNames: true}
+	in := &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{{
+			Name:       proto.String("a.proto"),
+			Dependency: []string{"b,c.proto", "d.proto"},
+			MessageType: []*descriptorpb.DescriptorProto{
+				{Name: proto.String("A")},
+				{Name: proto.String("B"), ReservedName: []string{"x", "y"}},
+			},
+		}},
+	}
+
+	b, err := c.Marshal(in)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "file.message_type[1].reserved_name")
+	assert.Contains(t, string(b), `"b\,c.proto,d.proto"`)
+
+	out := &descriptorpb.FileDescriptorSet{}
+	require.NoError(t, c.Unmarshal(b, out))
+	assert.True(t, proto.Equal(in, out), "got %v", out)
+
+	out = &descriptorpb.FileDescriptorSet{}
+	require.NoError(t, c.Unmarshal([]byte("name,file.name\nx,a.proto\n"), out))
+	assert.Equal(t, "a.proto", out.File[0].GetName())
+}
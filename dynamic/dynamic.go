@@ -0,0 +1,137 @@
+// Package dynamic loads proto files at runtime, from a FileDescriptorSet, so
+// that the codecs decode into dynamicpb messages of types whose generated
+// code is not linked in.
+//
+//	reg, err := dynamic.NewRegistry(set)
+//	m, err := reg.NewMessage("acme.config.v1.Server")
+//	err = json.Codec{Types: reg.Types}.Unmarshal(data, m)
+package dynamic
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Registry holds the descriptors of the files of a FileDescriptorSet and the
+// dynamicpb types of their messages, enums and extensions. Types is the
+// resolver to give to the Types option of the codecs, for the enums,
+// google.protobuf.Any values and extensions of the messages.
+type Registry struct {
+	Files *protoregistry.Files
+	Types *protoregistry.Types
+}
+
+// NewRegistry returns the registry of the files of set, in any order. The
+// imports missing from set, such as the well-known types, are looked up in
+// protoregistry.GlobalFiles.
+func NewRegistry(set *descriptorpb.FileDescriptorSet) (*Registry, error) {
+	r := &Registry{Files: &protoregistry.Files{}, Types: &protoregistry.Types{}}
+	protos := make(map[string]*descriptorpb.FileDescriptorProto, len(set.GetFile()))
+	for _, f := range set.GetFile() {
+		protos[f.GetName()] = f
+	}
+	loading := make(map[string]bool)
+	var load func(path string) error
+	load = func(path string) error {
+		if _, err := r.Files.FindFileByPath(path); err == nil {
+			return nil
+		}
+		fdp, ok := protos[path]
+		if !ok {
+			if _, err := protoregistry.GlobalFiles.FindFileByPath(path); err == nil {
+				return nil
+			}
+			return fmt.Errorf("dynamic: file %q is missing", path)
+		}
+		if loading[path] {
+			return fmt.Errorf("dynamic: import cycle at file %q", path)
+		}
+		loading[path] = true
+		for _, dep := range fdp.GetDependency() {
+			if err := load(dep); err != nil {
+				return err
+			}
+		}
+		fd, err := protodesc.NewFile(fdp, resolver{r.Files})
+		if err != nil {
+			return fmt.Errorf("dynamic: %w", err)
+		}
+		if err := r.Files.RegisterFile(fd); err != nil {
+			return fmt.Errorf("dynamic: %w", err)
+		}
+		return r.registerTypes(fd)
+	}
+	for _, f := range set.GetFile() {
+		if err := load(f.GetName()); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// NewMessage returns a new empty message of the type named name, such as
+// acme.config.v1.Server.
+func (r *Registry) NewMessage(name protoreflect.FullName) (*dynamicpb.Message, error) {
+	mt, err := r.Types.FindMessageByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("dynamic: message %q: %w", name, err)
+	}
+	return dynamicpb.NewMessage(mt.Descriptor()), nil
+}
+
+// typeContainer is implemented by files and messages, which declare
+// messages, enums and extensions.
+type typeContainer interface {
+	Messages() protoreflect.MessageDescriptors
+	Enums() protoreflect.EnumDescriptors
+	Extensions() protoreflect.ExtensionDescriptors
+}
+
+func (r *Registry) registerTypes(c typeContainer) error {
+	for i := 0; i < c.Enums().Len(); i++ {
+		if err := r.Types.RegisterEnum(dynamicpb.NewEnumType(c.Enums().Get(i))); err != nil {
+			return fmt.Errorf("dynamic: %w", err)
+		}
+	}
+	for i := 0; i < c.Extensions().Len(); i++ {
+		if err := r.Types.RegisterExtension(dynamicpb.NewExtensionType(c.Extensions().Get(i))); err != nil {
+			return fmt.Errorf("dynamic: %w", err)
+		}
+	}
+	for i := 0; i < c.Messages().Len(); i++ {
+		md := c.Messages().Get(i)
+		if !md.IsMapEntry() {
+			if err := r.Types.RegisterMessage(dynamicpb.NewMessageType(md)); err != nil {
+				return fmt.Errorf("dynamic: %w", err)
+			}
+		}
+		if err := r.registerTypes(md); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolver looks the imports up in files, then in protoregistry.GlobalFiles.
+type resolver struct {
+	files *protoregistry.Files
+}
+
+func (r resolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	if fd, err := r.files.FindFileByPath(path); err == nil {
+		return fd, nil
+	}
+	return protoregistry.GlobalFiles.FindFileByPath(path)
+}
+
+func (r resolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	if d, err := r.files.FindDescriptorByName(name); err == nil {
+		return d, nil
+	}
+	return protoregistry.GlobalFiles.FindDescriptorByName(name)
+}
@@ -0,0 +1,192 @@
+package dynamic_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"github.com/sraphs/encoding/dynamic"
+	"github.com/sraphs/encoding/env"
+	"github.com/sraphs/encoding/flag"
+	"github.com/sraphs/encoding/form"
+	"github.com/sraphs/encoding/json"
+	eproto "github.com/sraphs/encoding/proto"
+	"github.com/sraphs/encoding/yaml"
+)
+
+type codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+func TestRegistry(t *testing.T) {
+	reg, err := dynamic.NewRegistry(fileSet())
+	require.NoError(t, err)
+
+	_, err = reg.Files.FindFileByPath("acme/common/v1/peer.proto")
+	assert.NoError(t, err)
+	_, err = reg.Types.FindEnumByName("acme.config.v1.Level")
+	assert.NoError(t, err)
+	_, err = reg.Types.FindMessageByName("acme.config.v1.Server.LabelsEntry")
+	assert.Error(t, err)
+
+	_, err = reg.NewMessage("acme.config.v1.Missing")
+	assert.Error(t, err)
+
+	_, err = dynamic.NewRegistry(&descriptorpb.FileDescriptorSet{File: fileSet().File[:1]})
+	assert.EqualError(t, err, `dynamic: file "acme/common/v1/peer.proto" is missing`)
+}
+
+func TestCodecs(t *testing.T) {
+	reg, err := dynamic.NewRegistry(fileSet())
+	require.NoError(t, err)
+
+	want, err := reg.NewMessage("acme.config.v1.Server")
+	require.NoError(t, err)
+	require.NoError(t, json.Codec{Types: reg.Types}.Unmarshal([]byte(`{
+		"name": "api", "port": 8080, "timeout": "1.500s", "level": "LEVEL_DEBUG",
+		"tags": ["a", "b"], "labels": {"k": "v"}, "peer": {"host": "db"}}`), want))
+	fields := want.Descriptor().Fields()
+	assert.Equal(t, "api", want.Get(fields.ByName("name")).String())
+	assert.Equal(t, protoreflect.EnumNumber(1), want.Get(fields.ByName("level")).Enum())
+	assert.Equal(t, "db", want.Get(fields.ByName("peer")).Message().Get(
+		fields.ByName("peer").Message().Fields().ByName("host")).String())
+
+	for name, tt := range map[string]struct {
+		codec codec
+		input string
+	}{
+		"json": {json.Codec{Types: reg.Types},
+			`{"name":"api","port":8080,"timeout":"1.5s","level":"LEVEL_DEBUG","tags":["a","b"],"labels":{"k":"v"},"peer":{"host":"db"}}`},
+		"yaml": {yaml.Codec{Types: reg.Types},
+			"name: api\nport: 8080\ntimeout: 1.5s\nlevel: LEVEL_DEBUG\ntags: [a, b]\nlabels:\n  k: v\npeer:\n  host: db\n"},
+		"env": {env.Codec{Types: reg.Types},
+			"NAME=api\nPORT=8080\nTIMEOUT=1.5s\nLEVEL=debug\nTAGS=a,b\nLABELS_k=v\nPEER_HOST=db"},
+		"flag": {flag.Codec{Types: reg.Types},
+			"--name=api --port=8080 --timeout=1.5s --level=debug --tags=a,b --labels.k=v --peer.host=db"},
+		"form": {form.Codec{Types: reg.Types},
+			"name=api&port=8080&timeout=1.5s&level=debug&tags=a&tags=b&labels[k]=v&peer.host=db"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got, err := reg.NewMessage("acme.config.v1.Server")
+			require.NoError(t, err)
+			require.NoError(t, tt.codec.Unmarshal([]byte(tt.input), got))
+			assert.True(t, proto.Equal(want, got), "got %v", got)
+
+			// the output reads back.
+			b, err := tt.codec.Marshal(got)
+			require.NoError(t, err)
+			again, err := reg.NewMessage("acme.config.v1.Server")
+			require.NoError(t, err)
+			require.NoError(t, tt.codec.Unmarshal(b, again), string(b))
+			assert.True(t, proto.Equal(want, again), "got %v from %s", again, b)
+		})
+	}
+
+	t.Run("proto", func(t *testing.T) {
+		b, err := eproto.Codec{}.Marshal(want)
+		require.NoError(t, err)
+		got, err := reg.NewMessage("acme.config.v1.Server")
+		require.NoError(t, err)
+		require.NoError(t, eproto.Codec{Types: reg.Types}.Unmarshal(b, got))
+		assert.True(t, proto.Equal(want, got))
+	})
+}
+
+func TestAny(t *testing.T) {
+	reg, err := dynamic.NewRegistry(fileSet())
+	require.NoError(t, err)
+
+	peer, err := reg.NewMessage("acme.common.v1.Peer")
+	require.NoError(t, err)
+	peer.Set(peer.Descriptor().Fields().ByName("host"), protoreflect.ValueOfString("db"))
+	in, err := anypb.New(peer)
+	require.NoError(t, err)
+
+	b, err := json.Codec{Types: reg.Types}.Marshal(in)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"@type":"type.googleapis.com/acme.common.v1.Peer","host":"db"}`, string(b))
+	_, err = json.Codec{}.Marshal(in)
+	assert.Error(t, err, "the type is not registered globally")
+
+	out := &anypb.Any{}
+	require.NoError(t, yaml.Codec{Types: reg.Types}.Unmarshal([]byte("'@type': type.googleapis.com/acme.common.v1.Peer\nhost: db\n"), out))
+	assert.True(t, proto.Equal(in, out))
+}
+
+// fileSet returns the files of acme.config.v1.Server, the importing file
+// first, with the well-known types left out.
+func fileSet() *descriptorpb.FileDescriptorSet {
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string) *descriptorpb.FieldDescriptorProto {
+		f := &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(number),
+			Type:     typ.Enum(),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	repeated := func(f *descriptorpb.FieldDescriptorProto) *descriptorpb.FieldDescriptorProto {
+		f.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+		return f
+	}
+	const (
+		tString  = descriptorpb.FieldDescriptorProto_TYPE_STRING
+		tInt32   = descriptorpb.FieldDescriptorProto_TYPE_INT32
+		tEnum    = descriptorpb.FieldDescriptorProto_TYPE_ENUM
+		tMessage = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+	)
+	return &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{
+		{
+			Name:       proto.String("acme/config/v1/server.proto"),
+			Package:    proto.String("acme.config.v1"),
+			Syntax:     proto.String("proto3"),
+			Dependency: []string{"acme/common/v1/peer.proto", "google/protobuf/duration.proto"},
+			EnumType: []*descriptorpb.EnumDescriptorProto{{
+				Name: proto.String("Level"),
+				Value: []*descriptorpb.EnumValueDescriptorProto{
+					{Name: proto.String("LEVEL_UNSPECIFIED"), Number: proto.Int32(0)},
+					{Name: proto.String("LEVEL_DEBUG"), Number: proto.Int32(1)},
+				},
+			}},
+			MessageType: []*descriptorpb.DescriptorProto{{
+				Name: proto.String("Server"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("name", 1, tString, ""),
+					field("port", 2, tInt32, ""),
+					field("timeout", 3, tMessage, ".google.protobuf.Duration"),
+					field("level", 4, tEnum, ".acme.config.v1.Level"),
+					repeated(field("tags", 5, tString, "")),
+					repeated(field("labels", 6, tMessage, ".acme.config.v1.Server.LabelsEntry")),
+					field("peer", 7, tMessage, ".acme.common.v1.Peer"),
+				},
+				NestedType: []*descriptorpb.DescriptorProto{{
+					Name: proto.String("LabelsEntry"),
+					Field: []*descriptorpb.FieldDescriptorProto{
+						field("key", 1, tString, ""),
+						field("value", 2, tString, ""),
+					},
+					Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+				}},
+			}},
+		},
+		{
+			Name:    proto.String("acme/common/v1/peer.proto"),
+			Package: proto.String("acme.common.v1"),
+			Syntax:  proto.String("proto3"),
+			MessageType: []*descriptorpb.DescriptorProto{{
+				Name:  proto.String("Peer"),
+				Field: []*descriptorpb.FieldDescriptorProto{field("host", 1, tString, "")},
+			}},
+		},
+	}}
+}
@@ -4,11 +4,17 @@ import (
 	"errors"
 	"strings"
 
+	"github.com/sraphs/encoding/cbor"
+	"github.com/sraphs/encoding/csv"
 	"github.com/sraphs/encoding/env"
 	"github.com/sraphs/encoding/flag"
 	"github.com/sraphs/encoding/form"
 	"github.com/sraphs/encoding/json"
+	"github.com/sraphs/encoding/msgpack"
+	"github.com/sraphs/encoding/ndjson"
 	"github.com/sraphs/encoding/proto"
+	"github.com/sraphs/encoding/prototext"
+	"github.com/sraphs/encoding/toml"
 	"github.com/sraphs/encoding/xml"
 	"github.com/sraphs/encoding/yaml"
 )
@@ -58,14 +64,30 @@ func GetCodec(contentSubtype string) (codec Codec) {
 	switch contentSubtype {
 	case "yaml", "yml":
 		codec = yaml.Codec{}
+	case "toml":
+		codec = toml.Codec{}
 	case "xml":
 		codec = xml.Codec{}
 	case "proto":
 		codec = proto.Codec{}
+	case "textproto", "prototext":
+		codec = prototext.Codec{}
+	case "cbor":
+		codec = cbor.Codec{}
+	case "csv":
+		codec = csv.Codec{}
+	case "tab-separated-values", "tsv":
+		codec = csv.Codec{Comma: '\t'}
 	case "json":
 		codec = json.Codec{}
+	case "x-ndjson", "ndjson", "jsonl":
+		codec = ndjson.Codec{}
+	case "msgpack", "x-msgpack":
+		codec = msgpack.Codec{}
 	case "form":
 		codec = form.Codec{}
+	case "form-data":
+		codec = form.MultipartCodec{}
 	case "flag":
 		codec = flag.Codec{}
 	case "env":
@@ -7,12 +7,22 @@ import (
 	"sort"
 	"strings"
 
-	"github.com/joho/godotenv"
 	"github.com/mitchellh/mapstructure"
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 
 	"github.com/sraphs/flat"
+
+	"github.com/sraphs/encoding/format"
+	"github.com/sraphs/encoding/hooks"
+	"github.com/sraphs/encoding/internal/protopath"
+	"github.com/sraphs/encoding/interpolate"
+	"github.com/sraphs/encoding/merge"
+	"github.com/sraphs/encoding/redact"
+	"github.com/sraphs/encoding/secret"
+	"github.com/sraphs/encoding/validate"
 )
 
 // Name is the name registered for the env codec.
@@ -29,30 +39,82 @@ var (
 	}
 )
 
-// Codec is a Codec implementation with json.
-type Codec struct{}
+// Codec is a Codec implementation with env.
+type Codec struct {
+	// Hooks converts the values of Go struct fields from and to strings.
+	// If nil, hooks.Default is used. Marshal writes the values of the
+	// registered types with their hooks, such as 1s for a time.Duration,
+	// and flattens the nested structs, those behind pointers included, into
+	// PARENT_FIELD variables, leaving the nil pointers out.
+	Hooks *hooks.Registry
+	// Interpolate expands the ${VAR} references of the values, in place of
+	// the expansion of godotenv. See the interpolate package for the syntax.
+	// References to the variables of the input are resolved first, in any
+	// order; single quoted values are left as is.
+	Interpolate bool
+	// Lookup resolves the references to the variables missing in the input,
+	// interpolate.Env if nil.
+	Lookup interpolate.LookupFunc
+	// Resolvers replaces the values that are secret references, such as
+	// file:///run/secrets/db_password, by their content. References are
+	// left as is if nil.
+	Resolvers secret.Resolvers
+	// Redact replaces the values of the sensitive fields, the struct fields
+	// tagged sensitive:"true" and the proto fields with the debug_redact
+	// option, by redact.Placeholder in the output of Marshal.
+	Redact bool
+	// Bytes is the encoding of the bytes and google.protobuf.BytesValue
+	// fields of proto messages, standard base64 if zero. Decoding accepts
+	// every base64 variant, or only hexadecimal with format.Hex.
+	Bytes format.Bytes
+	// Timestamp is the form of the google.protobuf.Timestamp fields of proto
+	// messages, RFC 3339 if zero. Decoding also accepts RFC 3339.
+	Timestamp format.Timestamp
+	// Duration is the form of the google.protobuf.Duration fields of proto
+	// messages, Go syntax, such as 1.5s, if zero. Decoding accepts Go
+	// syntax, the protojson form and ISO 8601, such as P1D.
+	Duration format.Duration
+	// Types resolves the enums of proto messages by name, such as the types
+	// of a dynamic.Registry for dynamicpb messages, protoregistry.GlobalTypes
+	// if nil; the enums it misses are read with the descriptors of the
+	// fields. Enum values are matched by name in any case, with or without
+	// the prefix of the enum name, or by number.
+	Types *protoregistry.Types
+	// Mask limits the output of Marshal for proto messages to the fields of
+	// its paths, such as server.port, all of them if nil.
+	Mask *fieldmaskpb.FieldMask
+	// Merge is how Unmarshal combines data with the fields of the proto
+	// message already set. If zero, the fields of data are set in the
+	// message, appending to its lists.
+	Merge merge.Mode
+	// Validate validates the values decoded by Unmarshal, such as with their
+	// Validate methods, failing with a *validate.Error. No validation if nil.
+	Validate *validate.Registry
+}
 
-func (Codec) Marshal(v interface{}) (b []byte, err error) {
+func (c Codec) Marshal(v interface{}) (b []byte, err error) {
 	if v == nil {
 		return []byte{}, nil
 	}
+	if c.Redact {
+		v = redact.Copy(v)
+	}
 
 	vs := make(map[string]interface{})
 
 	if m, ok := v.(proto.Message); ok {
-		ev, err := EncodeValues(m)
+		mask, err := protopath.NewMask(m.ProtoReflect().Descriptor(), c.Mask)
 		if err != nil {
 			return nil, err
 		}
-		vs = mapStringToInterface(ev)
-	} else {
-		decoder, err := mapstructure.NewDecoder(defaultDecoderConfig(&vs))
-
+		ev, err := encodeValues(m, c.format(), mask)
 		if err != nil {
 			return nil, err
 		}
-
-		if err := decoder.Decode(v); err != nil {
+		vs = mapStringToInterface(ev)
+	} else {
+		vs, err = c.Hooks.ToMap(v, "mapstructure")
+		if err != nil {
 			return nil, err
 		}
 
@@ -83,21 +145,44 @@ func (Codec) Marshal(v interface{}) (b []byte, err error) {
 	return buf.Bytes(), nil
 }
 
-func (Codec) Unmarshal(data []byte, v interface{}) error {
+func (c Codec) Unmarshal(data []byte, v interface{}) error {
+	if err := c.unmarshal(data, v); err != nil {
+		return err
+	}
+	return c.Validate.Validate(c.Name(), v)
+}
+
+func (c Codec) unmarshal(data []byte, v interface{}) error {
 	if v == nil {
 		return nil
 	}
 
-	env, err := godotenv.Unmarshal(string(data))
+	env, err := c.parse(data)
 
 	if err != nil {
 		return err
 	}
 
 	if m, ok := v.(proto.Message); ok {
-		return DecodeValues(m, env)
+		d := c.pathDecoder()
+		if err := d.ResolveSecrets(c.Resolvers, env, m.ProtoReflect().Descriptor()); err != nil {
+			return err
+		}
+		return c.Merge.Decode(m, func(m proto.Message) error {
+			return d.DecodeStrings(m, env)
+		})
 	} else if m, ok := reflect.Indirect(reflect.ValueOf(v)).Interface().(proto.Message); ok {
-		return DecodeValues(m, env)
+		d := c.pathDecoder()
+		if err := d.ResolveSecrets(c.Resolvers, env, m.ProtoReflect().Descriptor()); err != nil {
+			return err
+		}
+		return c.Merge.Decode(m, func(m proto.Message) error {
+			return d.DecodeStrings(m, env)
+		})
+	}
+
+	if err := decoder.ResolveSecrets(c.Resolvers, env, nil); err != nil {
+		return err
 	}
 
 	env = lowercaseKeys(env)
@@ -108,27 +193,45 @@ func (Codec) Unmarshal(data []byte, v interface{}) error {
 
 	unflatted := fo.Unflatten(mapStringToInterface(env))
 
-	decoder, err := mapstructure.NewDecoder(defaultDecoderConfig(v))
+	md, err := mapstructure.NewDecoder(defaultDecoderConfig(v, c.Hooks))
 	if err != nil {
 		return err
 	}
-	err = decoder.Decode(unflatted)
+	err = md.Decode(unflatted)
 	return err
 }
 
+// UnmarshalMask is Unmarshal for proto messages, also returning the mask of
+// the fields present in data, such as the fields to update of a PATCH
+// request.
+func (c Codec) UnmarshalMask(data []byte, m proto.Message) (*fieldmaskpb.FieldMask, error) {
+	if err := c.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	env, err := c.parse(data)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	return decoder.Mask(m.ProtoReflect().Descriptor(), keys), nil
+}
+
 func (Codec) Name() string {
 	return Name
 }
 
 // defaultDecoderConfig returns default mapsstructure.DecoderConfig with suppot
-// of time.Duration values & string slices
-func defaultDecoderConfig(output interface{}) *mapstructure.DecoderConfig {
+// of the types of the hooks registry & string slices
+func defaultDecoderConfig(output interface{}, reg *hooks.Registry) *mapstructure.DecoderConfig {
 	c := &mapstructure.DecoderConfig{
 		Metadata:         nil,
 		Result:           output,
 		WeaklyTypedInput: true,
 		DecodeHook: mapstructure.ComposeDecodeHookFunc(
-			mapstructure.StringToTimeDurationHookFunc(),
+			reg.DecodeHook(),
 			mapstructure.StringToSliceHookFunc(","),
 		),
 	}
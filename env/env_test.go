@@ -1,18 +1,26 @@
 package env
 
 import (
+	"net"
+	"net/url"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/fieldmaskpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 
+	"github.com/sraphs/encoding/format"
+	"github.com/sraphs/encoding/hooks"
 	testData "github.com/sraphs/encoding/internal/testdata/complex"
+	"github.com/sraphs/encoding/secret"
 )
 
 var testCases = []struct {
@@ -210,6 +218,27 @@ var testCases = []struct {
 			},
 		},
 	},
+	{
+		desc:    "hooks types",
+		environ: []string{"ADDR=10.0.0.1", "ENDPOINT=https://sraph.com/", "SINCE=2022-05-01T10:00:00Z", "TIMEOUT=1m30s"},
+		element: &struct {
+			Addr     net.IP
+			Endpoint *url.URL
+			Since    time.Time
+			Timeout  time.Duration
+		}{},
+		data: &struct {
+			Addr     net.IP
+			Endpoint *url.URL
+			Since    time.Time
+			Timeout  time.Duration
+		}{
+			Addr:     net.ParseIP("10.0.0.1"),
+			Endpoint: &url.URL{Scheme: "https", Host: "sraph.com", Path: "/"},
+			Since:    time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC),
+			Timeout:  90 * time.Second,
+		},
+	},
 }
 
 func TestCodecMarshal(t *testing.T) {
@@ -320,3 +349,194 @@ func TestProtoEncodeDecode(t *testing.T) {
 	}
 
 }
+
+type percent float64
+
+func TestCodecHooks(t *testing.T) {
+	reg := hooks.New()
+	reg.Register(percent(0), hooks.Hook{
+		Decode: func(s string) (interface{}, error) {
+			f, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+			return percent(f / 100), err
+		},
+		Encode: func(v interface{}) (string, error) {
+			return strconv.FormatFloat(float64(v.(percent))*100, 'f', -1, 64) + "%", nil
+		},
+	})
+	c := Codec{Hooks: reg}
+
+	type config struct {
+		Ratio percent
+	}
+	b, err := c.Marshal(&config{Ratio: 0.25})
+	require.NoError(t, err)
+	assert.Equal(t, "RATIO=25%", string(b))
+
+	var out config
+	require.NoError(t, c.Unmarshal(b, &out))
+	assert.Equal(t, percent(0.25), out.Ratio)
+
+	// the default registry is left unchanged.
+	assert.Error(t, Codec{}.Unmarshal(b, &out))
+}
+
+func TestCodecInterpolate(t *testing.T) {
+	data := `URL=postgres://${DB_HOST}:${DB_PORT:-5432}/app
+DB_HOST="${HOST}"
+PRICE='5$ ${HOST}'
+COST=10$$
+NAME="\$HOST ${HOST}"`
+	c := Codec{Interpolate: true, Lookup: func(name string) (string, bool) {
+		if name == "HOST" {
+			return "db.local", true
+		}
+		return "", false
+	}}
+
+	var out struct {
+		Url   string
+		Price string
+		Cost  string
+		Name  string
+	}
+	require.NoError(t, c.Unmarshal([]byte(data), &out))
+	assert.Equal(t, "postgres://db.local:5432/app", out.Url)
+	assert.Equal(t, "5$ ${HOST}", out.Price)
+	assert.Equal(t, "10$", out.Cost)
+	assert.Equal(t, "$HOST db.local", out.Name)
+
+	err := c.Unmarshal([]byte("A=${B}\nB=${A}"), &out)
+	assert.Error(t, err)
+	err = c.Unmarshal([]byte("A=${MISSING:?is required}"), &out)
+	assert.EqualError(t, err, "interpolate: MISSING: is required")
+}
+
+func TestCodecSecrets(t *testing.T) {
+	c := Codec{Resolvers: secret.Resolvers{
+		"secretref": secret.Memory{"password": []byte("hunter2"), "key": []byte{0, 1, 2}},
+	}}
+
+	var out struct {
+		Password string
+		Key      []byte
+		User     string
+	}
+	data := "PASSWORD=secretref://password\nKEY=secretref://key\nUSER=sraph"
+	require.NoError(t, c.Unmarshal([]byte(data), &out))
+	assert.Equal(t, "hunter2", out.Password)
+	assert.Equal(t, []byte{0, 1, 2}, out.Key)
+	assert.Equal(t, "sraph", out.User)
+
+	m := &testData.Complex{}
+	data = "numberOne=secretref://password\nbyte=secretref://key\nbytes=secretref://key\nstring=secretref://password\nmap_x=secretref://password"
+	require.NoError(t, c.Unmarshal([]byte(data), m))
+	assert.Equal(t, "hunter2", m.NoOne)
+	assert.Equal(t, []byte{0, 1, 2}, m.Byte)
+	assert.Equal(t, []byte{0, 1, 2}, m.Bytes.GetValue())
+	assert.Equal(t, "hunter2", m.String_.GetValue())
+	assert.Equal(t, map[string]string{"x": "hunter2"}, m.Map)
+
+	assert.Error(t, c.Unmarshal([]byte("PASSWORD=secretref://missing"), &out))
+
+	// references are left as is without resolvers.
+	require.NoError(t, Codec{}.Unmarshal([]byte("PASSWORD=secretref://password"), &out))
+	assert.Equal(t, "secretref://password", out.Password)
+}
+
+func TestCodecRedact(t *testing.T) {
+	in := struct {
+		User     string
+		Password string `sensitive:"true"`
+	}{User: "sraph", Password: "hunter2"}
+
+	b, err := Codec{Redact: true}.Marshal(&in)
+	require.NoError(t, err)
+	assert.Equal(t, "PASSWORD=[REDACTED]\nUSER=sraph", string(b))
+
+	b, err = Codec{}.Marshal(&in)
+	require.NoError(t, err)
+	assert.Equal(t, "PASSWORD=hunter2\nUSER=sraph", string(b))
+}
+
+func TestCodecBytes(t *testing.T) {
+	in := &testData.Complex{Byte: []byte{0xfb, 0xff}, Bytes: wrapperspb.Bytes([]byte{0xfb, 0xff})}
+	for b, want := range map[format.Bytes]string{
+		format.Base64:    "+/8=",
+		format.Base64URL: "-_8=",
+		format.Hex:       "fbff",
+	} {
+		c := Codec{Bytes: b}
+		content, err := c.Marshal(in)
+		require.NoError(t, err)
+		assert.Contains(t, string(content), "\nbyte="+want+"\n")
+		assert.Contains(t, string(content), "\nbytes="+want+"\n")
+
+		out := &testData.Complex{}
+		require.NoError(t, c.Unmarshal(content, out))
+		assert.Equal(t, in.Byte, out.Byte)
+		assert.Equal(t, in.Bytes.GetValue(), out.Bytes.GetValue())
+	}
+
+	// every base64 variant decodes.
+	out := &testData.Complex{}
+	require.NoError(t, Codec{}.Unmarshal([]byte("byte=-_8\nbytes=+/8="), out))
+	assert.Equal(t, []byte{0xfb, 0xff}, out.Byte)
+	assert.Equal(t, []byte{0xfb, 0xff}, out.Bytes.GetValue())
+}
+
+func TestCodecTime(t *testing.T) {
+	in := &testData.Complex{
+		Timestamp: timestamppb.New(time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)),
+		Duration:  durationpb.New(90 * time.Second),
+	}
+	for _, tt := range []struct {
+		c                   Codec
+		timestamp, duration string
+	}{
+		{Codec{}, "2022-05-01T10:00:00Z", "1m30s"},
+		{Codec{Timestamp: format.UnixSeconds, Duration: format.ISO8601Duration}, "1651399200", "PT1M30S"},
+		{Codec{Timestamp: format.UnixMillis, Duration: format.ProtoJSONDuration}, "1651399200000", "90s"},
+		{Codec{Timestamp: time.RFC1123, Duration: format.GoDuration}, "Sun, 01 May 2022 10:00:00 UTC", "1m30s"},
+	} {
+		content, err := tt.c.Marshal(in)
+		require.NoError(t, err)
+		assert.Contains(t, string(content), "\ntimestamp="+tt.timestamp+"\n")
+		assert.Contains(t, string(content), "\nduration="+tt.duration+"\n")
+
+		out := &testData.Complex{}
+		require.NoError(t, tt.c.Unmarshal(content, out))
+		assert.True(t, proto.Equal(in.Timestamp, out.Timestamp), tt.timestamp)
+		assert.True(t, proto.Equal(in.Duration, out.Duration), tt.duration)
+	}
+
+	out := &testData.Complex{}
+	require.NoError(t, Codec{Timestamp: format.UnixSeconds}.Unmarshal([]byte("timestamp=2022-05-01T10:00:00Z\nduration=P1D"), out))
+	assert.Equal(t, int64(1651399200), out.Timestamp.GetSeconds())
+	assert.Equal(t, 24*time.Hour, out.Duration.AsDuration())
+	assert.Error(t, Codec{}.Unmarshal([]byte("duration=P1M"), &testData.Complex{}))
+}
+
+func TestCodecEnum(t *testing.T) {
+	for _, s := range []string{"woman", "WOMAN", "Woman", "1"} {
+		out := &testData.Complex{}
+		require.NoError(t, Codec{}.Unmarshal([]byte("SEX="+s), out), s)
+		assert.Equal(t, testData.Sex_woman, out.Sex, s)
+	}
+	assert.Error(t, Codec{}.Unmarshal([]byte("SEX=other"), &testData.Complex{}))
+}
+
+func TestCodecMask(t *testing.T) {
+	in := &testData.Complex{Id: 1, Simple: &testData.Simple{Component: "c"}, Map: map[string]string{"k": "v"}}
+	b, err := Codec{Mask: &fieldmaskpb.FieldMask{Paths: []string{"simple.component", "map", "age"}}}.Marshal(in)
+	require.NoError(t, err)
+	assert.Equal(t, "age=0\nmap_k=v\nverySimple_component=c", string(b))
+
+	_, err = Codec{Mask: &fieldmaskpb.FieldMask{Paths: []string{"age.x"}}}.Marshal(in)
+	assert.Error(t, err)
+
+	out := &testData.Complex{}
+	fm, err := Codec{}.UnmarshalMask([]byte("AGE=0\nverySimple_component=c\nSIMPLES=a,b\nOTHER=x"), out)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"age", "simple.component", "simples"}, fm.GetPaths())
+	assert.Equal(t, "c", out.Simple.GetComponent())
+}
@@ -0,0 +1,72 @@
+package env
+
+import (
+	"strings"
+
+	"github.com/joho/godotenv"
+
+	"github.com/sraphs/encoding/interpolate"
+)
+
+// dollar stands for the $ of the values to interpolate while godotenv parses
+// them, so that it does not expand the references itself.
+const dollar = "\uE000"
+
+// parse parses the env file, interpolating its values if enabled.
+func (c Codec) parse(data []byte) (map[string]string, error) {
+	if !c.Interpolate {
+		return godotenv.Unmarshal(string(data))
+	}
+
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		lines[i] = protectReferences(line)
+	}
+	env, err := godotenv.Unmarshal(strings.Join(lines, "\n"))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range env {
+		// the remaining $ come from single quoted values, which are literal.
+		v = strings.ReplaceAll(v, "$", "$$")
+		env[k] = strings.ReplaceAll(v, dollar, "$")
+	}
+	return interpolate.ExpandMap(env, c.Lookup)
+}
+
+// protectReferences replaces the $ of the value of the line by dollar,
+// unless the value is single quoted.
+func protectReferences(line string) string {
+	i := strings.IndexAny(line, "=:")
+	if i < 0 || strings.HasPrefix(strings.TrimSpace(line), "#") {
+		return line
+	}
+	value := strings.TrimSpace(line[i+1:])
+	switch {
+	case strings.HasPrefix(value, "'"):
+		return line
+	case strings.HasPrefix(value, `"`):
+		return line[:i+1] + protectQuoted(line[i+1:])
+	default:
+		return line[:i+1] + strings.ReplaceAll(line[i+1:], "$", dollar)
+	}
+}
+
+// protectQuoted replaces the $ of a double quoted value by dollar, and its
+// escaped \$ by two of them, the $$ escape of the interpolate package:
+// godotenv drops the backslash before anything but $, dollar included.
+func protectQuoted(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch {
+		case value[i] == '\\' && i+1 < len(value) && value[i+1] == '$':
+			b.WriteString(dollar + dollar)
+			i++
+		case value[i] == '$':
+			b.WriteString(dollar)
+		default:
+			b.WriteByte(value[i])
+		}
+	}
+	return b.String()
+}
@@ -10,31 +10,108 @@ import (
 	"github.com/mitchellh/mapstructure"
 	"github.com/tidwall/gjson"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 
 	"github.com/sraphs/flat"
 
+	"github.com/sraphs/encoding/format"
+	"github.com/sraphs/encoding/hooks"
+	"github.com/sraphs/encoding/internal/protopath"
 	"github.com/sraphs/encoding/json"
+	"github.com/sraphs/encoding/merge"
+	"github.com/sraphs/encoding/redact"
+	"github.com/sraphs/encoding/secret"
+	"github.com/sraphs/encoding/validate"
 )
 
 // Name is the name registered for the flag codec.
 const Name = "flag"
 
 // Codec is a Codec implementation with flag.
-type Codec struct{}
+type Codec struct {
+	// Hooks converts the values of Go struct fields from and to strings.
+	// If nil, hooks.Default is used. Marshal writes the values of the
+	// registered types with their hooks, such as 1s for a time.Duration
+	// where encoding/json writes nanoseconds, so that Unmarshal reads them
+	// back, and the other values in their encoding/json form.
+	Hooks *hooks.Registry
+	// Resolvers replaces the values that are secret references, such as
+	// file:///run/secrets/db_password, by their content. References are
+	// left as is if nil.
+	Resolvers secret.Resolvers
+	// Redact replaces the values of the sensitive fields, the struct fields
+	// tagged sensitive:"true" and the proto fields with the debug_redact
+	// option, by redact.Placeholder in the output of Marshal.
+	Redact bool
+	// Bytes is the encoding of the bytes and google.protobuf.BytesValue
+	// fields of proto messages, standard base64 if zero. Decoding accepts
+	// every base64 variant, or only hexadecimal with format.Hex.
+	Bytes format.Bytes
+	// Timestamp is the form of the google.protobuf.Timestamp fields of proto
+	// messages, RFC 3339 if zero. Decoding also accepts RFC 3339.
+	Timestamp format.Timestamp
+	// Duration is the form of the google.protobuf.Duration fields of proto
+	// messages, the protojson form, such as 1.500s, if zero. Decoding
+	// accepts Go syntax, the protojson form and ISO 8601, such as P1D.
+	Duration format.Duration
+	// Types resolves the enums and the google.protobuf.Any values of proto
+	// messages by name, such as the types of a dynamic.Registry for dynamicpb
+	// messages, protoregistry.GlobalTypes if nil; the enums it misses are
+	// read with the descriptors of the fields. Enum values are matched by
+	// name in any case, with or without the prefix of the enum name, or by
+	// number.
+	Types *protoregistry.Types
+	// Mask limits the output of Marshal for proto messages to the fields of
+	// its paths, such as server.port, all of them if nil.
+	Mask *fieldmaskpb.FieldMask
+	// Merge is how Unmarshal combines data with the fields of the proto
+	// message already set. If zero, the fields of data are set in the
+	// message, appending to its lists.
+	Merge merge.Mode
+	// Validate validates the values decoded by Unmarshal, such as with their
+	// Validate methods, failing with a *validate.Error. No validation if nil.
+	Validate *validate.Registry
+}
 
-func (Codec) Marshal(v interface{}) ([]byte, error) {
-	b, err := json.Codec{}.Marshal(v)
-	if err != nil {
-		return nil, err
+func (c Codec) Marshal(v interface{}) ([]byte, error) {
+	if c.Redact {
+		v = redact.Copy(v)
+	}
+	var m map[string]interface{}
+	pm, isProto := v.(proto.Message)
+	if isProto {
+		b, err := json.Codec{Types: c.Types}.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		m, _ = gjson.ParseBytes(b).Value().(map[string]interface{})
+		if c.Mask != nil {
+			md := pm.ProtoReflect().Descriptor()
+			mask, err := protopath.NewMask(md, c.Mask)
+			if err != nil {
+				return nil, err
+			}
+			mask.FilterJSON(md, m)
+		}
+	} else {
+		b, err := c.Hooks.ToJSON(v)
+		if err != nil {
+			return nil, err
+		}
+		m, _ = gjson.ParseBytes(b).Value().(map[string]interface{})
 	}
-
-	m := gjson.ParseBytes(b).Value().(map[string]interface{})
 
 	fo := flat.Option{
 		Separator: ".",
 	}
 
 	f := fo.Flatten(m)
+	if isProto {
+		if err := c.formatValues(pm.ProtoReflect().Descriptor(), f); err != nil {
+			return nil, err
+		}
+	}
 
 	// sort the keys
 	keys := make([]string, 0, len(f))
@@ -55,21 +132,44 @@ func (Codec) Marshal(v interface{}) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (Codec) Unmarshal(data []byte, v interface{}) error {
+func (c Codec) Unmarshal(data []byte, v interface{}) error {
+	if err := c.unmarshal(data, v); err != nil {
+		return err
+	}
+	return c.Validate.Validate(c.Name(), v)
+}
+
+func (c Codec) unmarshal(data []byte, v interface{}) error {
 	s := string(data)
 
 	args := strings.Split(s, " ")
 
-	m, err := Parse(args, v)
+	m, err := parse(args, v, c.Hooks)
 
 	if err != nil {
 		return err
 	}
 
 	if pm, ok := v.(proto.Message); ok {
-		return DecodeValues(pm, m)
+		d := c.pathDecoder()
+		if err := d.ResolveSecrets(c.Resolvers, m, pm.ProtoReflect().Descriptor()); err != nil {
+			return err
+		}
+		return c.Merge.Decode(pm, func(pm proto.Message) error {
+			return d.DecodeStrings(pm, m)
+		})
 	} else if pm, ok := reflect.Indirect(reflect.ValueOf(v)).Interface().(proto.Message); ok {
-		return DecodeValues(pm, m)
+		d := c.pathDecoder()
+		if err := d.ResolveSecrets(c.Resolvers, m, pm.ProtoReflect().Descriptor()); err != nil {
+			return err
+		}
+		return c.Merge.Decode(pm, func(pm proto.Message) error {
+			return d.DecodeStrings(pm, m)
+		})
+	}
+
+	if err := decoder.ResolveSecrets(c.Resolvers, m, nil); err != nil {
+		return err
 	}
 
 	fo := flat.Option{
@@ -80,15 +180,33 @@ func (Codec) Unmarshal(data []byte, v interface{}) error {
 
 	uf := fo.Unflatten(mi)
 
-	decoder, err := mapstructure.NewDecoder(defaultDecoderConfig(v))
+	md, err := mapstructure.NewDecoder(defaultDecoderConfig(v, c.Hooks))
 	if err != nil {
 		return err
 	}
-	err = decoder.Decode(uf)
+	err = md.Decode(uf)
 
 	return err
 }
 
+// UnmarshalMask is Unmarshal for proto messages, also returning the mask of
+// the fields present in data, such as the fields to update of a PATCH
+// request.
+func (c Codec) UnmarshalMask(data []byte, m proto.Message) (*fieldmaskpb.FieldMask, error) {
+	if err := c.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	args, err := parse(strings.Split(string(data), " "), m, c.Hooks)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(args))
+	for key := range args {
+		keys = append(keys, key)
+	}
+	return decoder.Mask(m.ProtoReflect().Descriptor(), keys), nil
+}
+
 func (Codec) Name() string {
 	return Name
 }
@@ -103,14 +221,14 @@ func mapStringToInterface(m map[string]string) map[string]interface{} {
 }
 
 // defaultDecoderConfig returns default mapsstructure.DecoderConfig with suppot
-// of time.Duration values & string slices
-func defaultDecoderConfig(output interface{}) *mapstructure.DecoderConfig {
+// of the types of the hooks registry & string slices
+func defaultDecoderConfig(output interface{}, reg *hooks.Registry) *mapstructure.DecoderConfig {
 	c := &mapstructure.DecoderConfig{
 		Metadata:         nil,
 		Result:           output,
 		WeaklyTypedInput: true,
 		DecodeHook: mapstructure.ComposeDecodeHookFunc(
-			mapstructure.StringToTimeDurationHookFunc(),
+			reg.DecodeHook(),
 			mapstructure.StringToSliceHookFunc(","),
 		),
 	}
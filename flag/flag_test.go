@@ -1,16 +1,24 @@
 package flag
 
 import (
+	"fmt"
+	"net"
+	"net/url"
 	"testing"
+	"time"
 
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/fieldmaskpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/sraphs/encoding/format"
 	testData "github.com/sraphs/encoding/internal/testdata/complex"
+	"github.com/sraphs/encoding/secret"
 )
 
 func TestProtoEncodeDecode(t *testing.T) {
@@ -68,3 +76,155 @@ func TestProtoEncodeDecode(t *testing.T) {
 
 	assert.Equal(t, in, in2)
 }
+
+func TestCodecHooks(t *testing.T) {
+	type config struct {
+		Addr     net.IP        `json:"addr"`
+		Endpoint *url.URL      `json:"endpoint"`
+		Since    time.Time     `json:"since"`
+		Timeout  time.Duration `json:"timeout"`
+		Peers    []net.IP      `json:"peers"`
+	}
+	in := &config{
+		Addr:     net.ParseIP("10.0.0.1"),
+		Endpoint: &url.URL{Scheme: "https", Host: "sraph.com", Path: "/"},
+		Since:    time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC),
+		Timeout:  90 * time.Second,
+		Peers:    []net.IP{net.ParseIP("10.0.0.2"), net.ParseIP("10.0.0.3")},
+	}
+
+	codec := Codec{}
+	content, err := codec.Marshal(in)
+	require.NoError(t, err)
+	assert.Equal(t, "--addr=10.0.0.1 --endpoint=https://sraph.com/ --peers=10.0.0.2,10.0.0.3 "+
+		"--since=2022-05-01T10:00:00Z --timeout=1m30s", string(content))
+
+	out := &config{}
+	require.NoError(t, codec.Unmarshal(content, out))
+	assert.Equal(t, in, out)
+
+	// a flag of a hooks type without value is not taken for a bool.
+	out = &config{}
+	require.NoError(t, codec.Unmarshal([]byte("--endpoint https://sraph.com/"), out))
+	assert.Equal(t, "sraph.com", out.Endpoint.Host)
+}
+
+type version struct{ Major, Minor int }
+
+func (v version) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf(`"v%d.%d"`, v.Major, v.Minor)), nil
+}
+
+func TestCodecJSONMarshaler(t *testing.T) {
+	type config struct {
+		Name    string   `json:"name"`
+		Version version  `json:"version"`
+		Timeout *float64 `json:"timeout"`
+	}
+
+	b, err := Codec{}.Marshal(&config{Name: "sraph", Version: version{1, 2}})
+	require.NoError(t, err)
+	assert.Equal(t, "--name=sraph --timeout=<nil> --version=v1.2", string(b))
+}
+
+func TestCodecSecrets(t *testing.T) {
+	c := Codec{Resolvers: secret.Resolvers{
+		"secretref": secret.Memory{"password": []byte("hunter2"), "key": []byte{0, 1, 2}},
+	}}
+
+	var out struct {
+		Password string
+		Key      []byte
+	}
+	require.NoError(t, c.Unmarshal([]byte("--password=secretref://password --key=secretref://key"), &out))
+	assert.Equal(t, "hunter2", out.Password)
+	assert.Equal(t, []byte{0, 1, 2}, out.Key)
+
+	m := &testData.Complex{}
+	require.NoError(t, c.Unmarshal([]byte("--numberOne=secretref://password --byte=secretref://key --bytes=secretref://key"), m))
+	assert.Equal(t, "hunter2", m.NoOne)
+	assert.Equal(t, []byte{0, 1, 2}, m.Byte)
+	assert.Equal(t, []byte{0, 1, 2}, m.Bytes.GetValue())
+}
+
+func TestCodecRedact(t *testing.T) {
+	in := struct {
+		User     string `json:"user"`
+		Password string `json:"password" sensitive:"true"`
+	}{User: "sraph", Password: "hunter2"}
+
+	b, err := Codec{Redact: true}.Marshal(&in)
+	require.NoError(t, err)
+	assert.Equal(t, "--password=[REDACTED] --user=sraph", string(b))
+	assert.Equal(t, "hunter2", in.Password)
+}
+
+func TestCodecBytes(t *testing.T) {
+	in := &testData.Complex{Byte: []byte{0xfb, 0xff}, Bytes: wrapperspb.Bytes([]byte{0xfb, 0xff})}
+	for b, want := range map[format.Bytes]string{
+		format.Base64:       "+/8=",
+		format.Base64RawURL: "-_8",
+		format.Hex:          "fbff",
+	} {
+		c := Codec{Bytes: b}
+		content, err := c.Marshal(in)
+		require.NoError(t, err)
+		assert.Contains(t, string(content), "--byte="+want+" ")
+		assert.Contains(t, string(content), "--bytes="+want+" ")
+
+		out := &testData.Complex{}
+		require.NoError(t, c.Unmarshal([]byte("--byte="+want+" --bytes="+want), out))
+		assert.Equal(t, in.Byte, out.Byte)
+		assert.Equal(t, in.Bytes.GetValue(), out.Bytes.GetValue())
+	}
+}
+
+func TestCodecTime(t *testing.T) {
+	in := &testData.Complex{
+		Timestamp: timestamppb.New(time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)),
+		Duration:  durationpb.New(90 * time.Second),
+	}
+	for _, tt := range []struct {
+		c                   Codec
+		timestamp, duration string
+	}{
+		{Codec{}, "2022-05-01T10:00:00Z", "90s"},
+		{Codec{Timestamp: format.UnixSeconds, Duration: format.ISO8601Duration}, "1651399200", "PT1M30S"},
+		{Codec{Timestamp: format.UnixMillis, Duration: format.GoDuration}, "1651399200000", "1m30s"},
+		{Codec{Timestamp: "2006-01-02T15:04", Duration: format.ProtoJSONDuration}, "2022-05-01T10:00", "90s"},
+	} {
+		content, err := tt.c.Marshal(in)
+		require.NoError(t, err)
+		assert.Contains(t, string(content), " --duration="+tt.duration+" ")
+		assert.Contains(t, string(content), " --timestamp="+tt.timestamp+" ")
+
+		args := "--duration=" + tt.duration + " --timestamp=" + tt.timestamp
+		out := &testData.Complex{}
+		require.NoError(t, tt.c.Unmarshal([]byte(args), out))
+		assert.True(t, proto.Equal(in.Timestamp, out.Timestamp), tt.timestamp)
+		assert.True(t, proto.Equal(in.Duration, out.Duration), tt.duration)
+	}
+
+	out := &testData.Complex{}
+	require.NoError(t, Codec{}.Unmarshal([]byte("--duration=P1DT2H"), out))
+	assert.Equal(t, 26*time.Hour, out.Duration.AsDuration())
+}
+
+func TestCodecEnum(t *testing.T) {
+	out := &testData.Complex{}
+	require.NoError(t, Codec{}.Unmarshal([]byte("--sex=WOMAN"), out))
+	assert.Equal(t, testData.Sex_woman, out.Sex)
+}
+
+func TestCodecMask(t *testing.T) {
+	in := &testData.Complex{Id: 1, Simple: &testData.Simple{Component: "c"}, Map: map[string]string{"k": "v"}}
+	b, err := Codec{Mask: &fieldmaskpb.FieldMask{Paths: []string{"simple.component", "map", "age"}}}.Marshal(in)
+	require.NoError(t, err)
+	assert.Equal(t, "--age=0 --map.k=v --very_simple.component=c", string(b))
+
+	out := &testData.Complex{}
+	fm, err := Codec{}.UnmarshalMask([]byte("--age=0 --very_simple.component=c --map.k=v --other=x"), out)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"age", "map", "simple.component"}, fm.GetPaths())
+	assert.Equal(t, map[string]string{"k": "v"}, out.Map)
+}
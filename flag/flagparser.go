@@ -5,14 +5,20 @@ import (
 	"reflect"
 	"regexp"
 	"strings"
+
+	"github.com/sraphs/encoding/hooks"
 )
 
 // Parse parses the command-line flag arguments into a map,
 // using the type information in element to discriminate whether a flag is supposed to be a bool,
 // and other such ambiguities.
 func Parse(args []string, element interface{}) (map[string]string, error) {
+	return parse(args, element, nil)
+}
+
+func parse(args []string, element interface{}, reg *hooks.Registry) (map[string]string, error) {
 	f := flagSet{
-		flagTypes: getFlagTypes(element),
+		flagTypes: getFlagTypes(element, reg),
 		args:      args,
 		values:    make(map[string]string),
 		keys:      make(map[string]string),
@@ -3,6 +3,8 @@ package flag
 import (
 	"reflect"
 	"strings"
+
+	"github.com/sraphs/encoding/hooks"
 )
 
 var (
@@ -10,7 +12,7 @@ var (
 	MapNamePlaceholder = "<name>"
 )
 
-func getFlagTypes(element interface{}) map[string]reflect.Kind {
+func getFlagTypes(element interface{}, reg *hooks.Registry) map[string]reflect.Kind {
 	ref := map[string]reflect.Kind{}
 
 	if element == nil {
@@ -19,26 +21,32 @@ func getFlagTypes(element interface{}) map[string]reflect.Kind {
 
 	tp := reflect.TypeOf(element).Elem()
 
-	addFlagType(ref, "", tp)
+	addFlagType(ref, "", tp, reg)
 
 	return ref
 }
 
-func addFlagType(ref map[string]reflect.Kind, name string, typ reflect.Type) {
+func addFlagType(ref map[string]reflect.Kind, name string, typ reflect.Type, reg *hooks.Registry) {
 	kind := typ.Kind()
 
+	// the types of the hooks take a single value.
+	if name != "" && reg.Decodes(typ) {
+		ref[name] = reflect.String
+		return
+	}
+
 	switch kind {
 	case reflect.Bool, reflect.Slice:
 		ref[name] = typ.Kind()
 
 	case reflect.Map:
-		addFlagType(ref, getName(name, MapNamePlaceholder), typ.Elem())
+		addFlagType(ref, getName(name, MapNamePlaceholder), typ.Elem(), reg)
 
 	case reflect.Ptr:
 		if typ.Elem().Kind() == reflect.Struct {
 			ref[name] = typ.Kind()
 		}
-		addFlagType(ref, name, typ.Elem())
+		addFlagType(ref, name, typ.Elem(), reg)
 
 	case reflect.Struct:
 		for j := 0; j < typ.NumField(); j++ {
@@ -48,9 +56,9 @@ func addFlagType(ref map[string]reflect.Kind, name string, typ reflect.Type) {
 				continue
 			}
 			if subField.Anonymous {
-				addFlagType(ref, getName(name), subField.Type)
+				addFlagType(ref, getName(name), subField.Type, reg)
 			} else {
-				addFlagType(ref, getName(name, subField.Name), subField.Type)
+				addFlagType(ref, getName(name, subField.Name), subField.Type, reg)
 			}
 		}
 
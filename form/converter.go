@@ -0,0 +1,136 @@
+package form
+
+import (
+	"encoding"
+	"errors"
+	"reflect"
+	"sync"
+
+	"github.com/go-playground/form/v4"
+)
+
+// EncodeFunc converts a value of a registered type to form values.
+type EncodeFunc func(v interface{}) ([]string, error)
+
+// DecodeFunc converts form values to a value of a registered type.
+type DecodeFunc func(values []string) (interface{}, error)
+
+var (
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Converters is a registry of custom type conversions for the Go structs
+// encoded and decoded by the form codecs, such as time.Time with a given
+// layout or a UUID type. The zero value is an empty registry ready to use.
+//
+// The encoders and decoders built from the registry are cached per tag name,
+// so that struct metadata is only computed once per type.
+type Converters struct {
+	mu     sync.RWMutex
+	types  []converter
+	coders map[string]*coders
+}
+
+type converter struct {
+	typ    interface{}
+	encode EncodeFunc
+	decode DecodeFunc
+}
+
+// coders are the go-playground encoders and decoder for a tag name.
+type coders struct {
+	encoder *form.Encoder
+	decoder *form.Decoder
+	// multipartEncoder leaves out the file fields, which are written as parts.
+	multipartEncoder *form.Encoder
+}
+
+// defaultConverters is used by the codecs without Converters.
+var defaultConverters = &Converters{}
+
+// Register registers the conversion of the type of typ. A nil encode or
+// decode falls back to the encoding.TextMarshaler or encoding.TextUnmarshaler
+// implementation of the type, if any.
+func (c *Converters) Register(typ interface{}, encode EncodeFunc, decode DecodeFunc) error {
+	t := reflect.TypeOf(typ)
+	if t == nil {
+		return errors.New("form: cannot register a converter for nil")
+	}
+	if encode == nil && t.Implements(textMarshalerType) {
+		encode = func(v interface{}) ([]string, error) {
+			b, err := v.(encoding.TextMarshaler).MarshalText()
+			if err != nil {
+				return nil, err
+			}
+			return []string{string(b)}, nil
+		}
+	}
+	if decode == nil && reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		decode = func(values []string) (interface{}, error) {
+			p := reflect.New(t)
+			if err := p.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(values[0])); err != nil {
+				return nil, err
+			}
+			return p.Elem().Interface(), nil
+		}
+	}
+	if encode == nil && decode == nil {
+		return errors.New("form: no conversion for " + t.String())
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.types = append(c.types, converter{typ: typ, encode: encode, decode: decode})
+	// drop the coders built without the new type.
+	c.coders = nil
+	return nil
+}
+
+// get returns the coders for the tag name, building them on first use.
+func (c *Converters) get(tagName string) *coders {
+	c.mu.RLock()
+	cd, ok := c.coders[tagName]
+	c.mu.RUnlock()
+	if ok {
+		return cd
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cd, ok := c.coders[tagName]; ok {
+		return cd
+	}
+	cd = &coders{
+		encoder:          form.NewEncoder(),
+		decoder:          form.NewDecoder(),
+		multipartEncoder: form.NewEncoder(),
+	}
+	cd.encoder.SetTagName(tagName)
+	cd.decoder.SetTagName(tagName)
+	cd.multipartEncoder.SetTagName(tagName)
+	for _, conv := range c.types {
+		if conv.encode != nil {
+			cd.encoder.RegisterCustomTypeFunc(form.EncodeCustomTypeFunc(conv.encode), conv.typ)
+			cd.multipartEncoder.RegisterCustomTypeFunc(form.EncodeCustomTypeFunc(conv.encode), conv.typ)
+		}
+		if conv.decode != nil {
+			cd.decoder.RegisterCustomTypeFunc(form.DecodeCustomTypeFunc(conv.decode), conv.typ)
+		}
+	}
+	cd.multipartEncoder.RegisterCustomTypeFunc(func(interface{}) ([]string, error) {
+		return nil, nil
+	}, FileHeader{}, []byte(nil), []FileHeader(nil), []*FileHeader(nil), [][]byte(nil))
+	if c.coders == nil {
+		c.coders = make(map[string]*coders)
+	}
+	c.coders[tagName] = cd
+	return cd
+}
+
+func convertersOrDefault(c *Converters) *Converters {
+	if c == nil {
+		return defaultConverters
+	}
+	return c
+}
@@ -0,0 +1,66 @@
+package form
+
+import (
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	testData "github.com/sraphs/encoding/internal/testdata/complex"
+)
+
+type QueryModel struct {
+	Name  string    `query:"name"`
+	Page  int       `query:"page,omitempty"`
+	Since time.Time `query:"since"`
+	Addr  net.IP    `query:"addr"`
+	Skip  string    `query:"-"`
+}
+
+func TestConverters(t *testing.T) {
+	convs := &Converters{}
+	require.NoError(t, convs.Register(time.Time{}, func(v interface{}) ([]string, error) {
+		return []string{v.(time.Time).Format("2006-01-02")}, nil
+	}, func(values []string) (interface{}, error) {
+		return time.Parse("2006-01-02", values[0])
+	}))
+	// net.IP implements encoding.TextMarshaler and encoding.TextUnmarshaler.
+	require.NoError(t, convs.Register(net.IP{}, nil, nil))
+	assert.Error(t, convs.Register(struct{}{}, nil, nil))
+
+	codec := Codec{TagName: "query", Converters: convs}
+	in := &QueryModel{
+		Name:  "sraph",
+		Since: time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC),
+		Addr:  net.ParseIP("10.0.0.1"),
+		Skip:  "skip",
+	}
+	content, err := codec.Marshal(in)
+	require.NoError(t, err)
+	assert.Equal(t, "addr=10.0.0.1&name=sraph&since=2022-05-01", string(content))
+
+	var out QueryModel
+	require.NoError(t, codec.Unmarshal(content, &out))
+	in.Skip = ""
+	assert.Equal(t, in, &out)
+
+	// the coders are built once per tag name.
+	assert.Same(t, codec.coders(), codec.coders())
+	assert.NotSame(t, codec.coders(), Codec{TagName: "form", Converters: convs}.coders())
+}
+
+func TestCodecOmitEmpty(t *testing.T) {
+	content, err := Codec{OmitEmpty: true}.Marshal(&TestModel{ID: 1})
+	require.NoError(t, err)
+	assert.Equal(t, "id=1", string(content))
+
+	content, err = Codec{OmitEmpty: true}.Marshal(&testData.Complex{Id: 1})
+	require.NoError(t, err)
+	vs, err := url.ParseQuery(string(content))
+	require.NoError(t, err)
+	assert.Equal(t, "1", vs.Get("id"))
+	assert.NotContains(t, vs, "numberOne")
+}
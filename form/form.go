@@ -4,8 +4,15 @@ import (
 	"net/url"
 	"reflect"
 
-	"github.com/go-playground/form/v4"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+
+	"github.com/sraphs/encoding/format"
+	"github.com/sraphs/encoding/internal/protopath"
+	"github.com/sraphs/encoding/merge"
+	"github.com/sraphs/encoding/redact"
+	"github.com/sraphs/encoding/validate"
 )
 
 const (
@@ -13,42 +20,126 @@ const (
 	Name = "x-www-form-urlencoded"
 )
 
-type Codec struct{}
+// Codec is a Codec implementation with x-www-form-urlencoded.
+type Codec struct {
+	// Syntax is the syntax of nested keys and lists written by Marshal,
+	// DotSyntax if zero. With CommaSyntax, Unmarshal also splits list values
+	// on commas.
+	Syntax Syntax
+	// TagName is the struct tag naming the fields of Go structs, such as
+	// "form" or "query"; "json" if empty. Tag options such as omitempty
+	// are honored.
+	TagName string
+	// OmitEmpty makes Marshal drop the keys whose values are all empty
+	// strings, for Go structs and proto messages alike.
+	OmitEmpty bool
+	// Converters converts the custom types of Go structs. If nil, a shared
+	// empty registry is used.
+	Converters *Converters
+	// Redact replaces the values of the sensitive fields, the struct fields
+	// tagged sensitive:"true" and the proto fields with the debug_redact
+	// option, by redact.Placeholder in the output of Marshal.
+	Redact bool
+	// Bytes is the encoding of the bytes and google.protobuf.BytesValue
+	// fields of proto messages, standard base64 if zero. Decoding accepts
+	// every base64 variant, or only hexadecimal with format.Hex.
+	Bytes format.Bytes
+	// Timestamp is the form of the google.protobuf.Timestamp fields of proto
+	// messages, RFC 3339 if zero. Decoding also accepts RFC 3339.
+	Timestamp format.Timestamp
+	// Duration is the form of the google.protobuf.Duration fields of proto
+	// messages, Go syntax, such as 1.5s, if zero. Decoding accepts Go
+	// syntax, the protojson form and ISO 8601, such as P1D.
+	Duration format.Duration
+	// Types resolves the enums of proto messages by name, such as the types
+	// of a dynamic.Registry for dynamicpb messages, protoregistry.GlobalTypes
+	// if nil; the enums it misses are read with the descriptors of the
+	// fields. Enum values are matched by name in any case, with or without
+	// the prefix of the enum name, or by number.
+	Types *protoregistry.Types
+	// Mask limits the output of Marshal for proto messages to the fields of
+	// its paths, such as server.port, all of them if nil.
+	Mask *fieldmaskpb.FieldMask
+	// Merge is how Unmarshal combines data with the fields of the proto
+	// message already set. If zero, the fields of data are set in the
+	// message, appending to its lists.
+	Merge merge.Mode
+	// Validate validates the values decoded by Unmarshal, such as with their
+	// Validate methods, failing with a *validate.Error. No validation if nil.
+	Validate *validate.Registry
+}
+
+func (c Codec) coders() *coders {
+	return convertersOrDefault(c.Converters).get(c.tagName())
+}
+
+func (c Codec) tagName() string {
+	if c.TagName == "" {
+		return "json"
+	}
+	return c.TagName
+}
 
 func (c Codec) Marshal(v interface{}) ([]byte, error) {
-	encoder := form.NewEncoder()
-	encoder.SetTagName("json")
+	if c.Redact {
+		v = redact.Copy(v)
+	}
+	vs, err := c.values(v, false)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(vs.Encode()), nil
+}
 
+// values returns the form values of v in the syntax of c, leaving out the
+// file fields of Go structs for multipart forms.
+func (c Codec) values(v interface{}, multipart bool) (url.Values, error) {
 	var vs url.Values
 	var err error
 	if m, ok := v.(proto.Message); ok {
-		vs, err = EncodeValues(m)
+		mask, err := protopath.NewMask(m.ProtoReflect().Descriptor(), c.Mask)
+		if err != nil {
+			return nil, err
+		}
+		vs, err = encodeValues(m, c.format(), mask)
 		if err != nil {
 			return nil, err
 		}
 	} else {
+		encoder := c.coders().encoder
+		if multipart {
+			encoder = c.coders().multipartEncoder
+		}
 		vs, err = encoder.Encode(v)
 		if err != nil {
 			return nil, err
 		}
 	}
 	for k, v := range vs {
-		if len(v) == 0 {
+		if len(v) == 0 || c.OmitEmpty && allEmpty(v) {
 			delete(vs, k)
 		}
 	}
-	return []byte(vs.Encode()), nil
+	return encodeSyntax(vs, c.Syntax), nil
 }
 
 func (c Codec) Unmarshal(data []byte, v interface{}) error {
-	decoder := form.NewDecoder()
-	decoder.SetTagName("json")
+	if err := c.unmarshal(data, v); err != nil {
+		return err
+	}
+	return c.Validate.Validate(c.Name(), v)
+}
 
+func (c Codec) unmarshal(data []byte, v interface{}) error {
 	vs, err := url.ParseQuery(string(data))
 	if err != nil {
 		return err
 	}
+	return c.decode(vs, v)
+}
 
+// decode decodes the form values vs into v.
+func (c Codec) decode(vs url.Values, v interface{}) error {
 	rv := reflect.ValueOf(v)
 	for rv.Kind() == reflect.Ptr {
 		if rv.IsNil() {
@@ -57,14 +148,45 @@ func (c Codec) Unmarshal(data []byte, v interface{}) error {
 		rv = rv.Elem()
 	}
 	if m, ok := v.(proto.Message); ok {
-		return DecodeValues(m, vs)
+		return c.Merge.Decode(m, func(m proto.Message) error {
+			return c.pathDecoder().Decode(m, vs)
+		})
 	} else if m, ok := reflect.Indirect(reflect.ValueOf(v)).Interface().(proto.Message); ok {
-		return DecodeValues(m, vs)
+		return c.Merge.Decode(m, func(m proto.Message) error {
+			return c.pathDecoder().Decode(m, vs)
+		})
 	}
 
-	return decoder.Decode(v, vs)
+	return c.coders().decoder.Decode(v, structValues(rv.Type(), vs, c.Syntax, c.tagName()))
+}
+
+// UnmarshalMask is Unmarshal for proto messages, also returning the mask of
+// the fields present in data, such as the fields to update of a PATCH
+// request.
+func (c Codec) UnmarshalMask(data []byte, m proto.Message) (*fieldmaskpb.FieldMask, error) {
+	if err := c.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	vs, err := url.ParseQuery(string(data))
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(vs))
+	for key := range vs {
+		keys = append(keys, key)
+	}
+	return c.pathDecoder().Mask(m.ProtoReflect().Descriptor(), keys), nil
 }
 
 func (Codec) Name() string {
 	return Name
 }
+
+func allEmpty(values []string) bool {
+	for _, v := range values {
+		if v != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -1,14 +1,21 @@
 package form
 
 import (
+	"net/url"
 	"reflect"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
 	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/fieldmaskpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 
+	"github.com/sraphs/encoding/format"
 	testData "github.com/sraphs/encoding/internal/testdata/complex"
 )
 
@@ -161,3 +168,202 @@ func TestProtoEncodeDecode(t *testing.T) {
 		t.Errorf("expect %v, got %v", "5566", in2.Simples[1])
 	}
 }
+
+type Item struct {
+	Name string `json:"name"`
+}
+
+type SyntaxModel struct {
+	Tags  []string          `json:"tags"`
+	Items []Item            `json:"items"`
+	Attrs map[string]string `json:"attrs"`
+	Inner struct {
+		Name string `json:"name"`
+	} `json:"inner"`
+}
+
+func TestFormSyntaxDecodeStruct(t *testing.T) {
+	tests := []struct {
+		syntax Syntax
+		query  string
+	}{
+		{DotSyntax, "tags=a&tags=b&items[0].name=x&items[1].name=y&attrs[k]=v&inner.name=n"},
+		{BracketSyntax, "tags[]=a&tags[]=b&items[0][name]=x&items[1][name]=y&attrs[k]=v&inner[name]=n"},
+		{IndexSyntax, "tags[0]=a&tags[1]=b&items[0].name=x&items[1].name=y&attrs[k]=v&inner.name=n"},
+		{CommaSyntax, "tags=a,b&items[0].name=x&items[1].name=y&attrs[k]=v&inner.name=n"},
+	}
+	for _, tt := range tests {
+		var m SyntaxModel
+		require.NoError(t, Codec{Syntax: tt.syntax}.Unmarshal([]byte(tt.query), &m), tt.query)
+		assert.Equal(t, []string{"a", "b"}, m.Tags, tt.query)
+		assert.Equal(t, []Item{{Name: "x"}, {Name: "y"}}, m.Items, tt.query)
+		assert.Equal(t, map[string]string{"k": "v"}, m.Attrs, tt.query)
+		assert.Equal(t, "n", m.Inner.Name, tt.query)
+	}
+}
+
+func TestFormSyntaxDecodeProto(t *testing.T) {
+	tests := []struct {
+		syntax Syntax
+		query  string
+	}{
+		{DotSyntax, "simples=a&simples=b&map.k=v&very_simple.component=c"},
+		{BracketSyntax, "simples[]=a&simples[]=b&map[k]=v&very_simple[component]=c"},
+		{IndexSyntax, "simples[1]=b&simples[0]=a&map[k]=v&very_simple.component=c"},
+		{CommaSyntax, "simples=a,b&map[k]=v&very_simple.component=c&numberOne=x,y"},
+	}
+	for _, tt := range tests {
+		var m testData.Complex
+		require.NoError(t, Codec{Syntax: tt.syntax}.Unmarshal([]byte(tt.query), &m), tt.query)
+		assert.Equal(t, []string{"a", "b"}, m.Simples, tt.query)
+		assert.Equal(t, map[string]string{"k": "v"}, m.Map, tt.query)
+		assert.Equal(t, "c", m.Simple.GetComponent(), tt.query)
+	}
+
+	// commas are only split for lists.
+	var m testData.Complex
+	require.NoError(t, Codec{Syntax: CommaSyntax}.Unmarshal([]byte("numberOne=x,y"), &m))
+	assert.Equal(t, "x,y", m.NoOne)
+
+	fds := &descriptorpb.FileDescriptorSet{}
+	require.NoError(t, DecodeValues(fds, url.Values{
+		"file[1].name":    {"b.proto"},
+		"file[0][name]":   {"a.proto"},
+		"file[0].package": {"pkg"},
+	}))
+	require.Len(t, fds.File, 2)
+	assert.Equal(t, "a.proto", fds.File[0].GetName())
+	assert.Equal(t, "pkg", fds.File[0].GetPackage())
+	assert.Equal(t, "b.proto", fds.File[1].GetName())
+
+	assert.Error(t, DecodeValues(fds, url.Values{"file[100000].name": {"x"}}))
+}
+
+func TestFormSyntaxEncode(t *testing.T) {
+	in := &SyntaxModel{
+		Tags:  []string{"a", "b"},
+		Items: []Item{{Name: "x"}},
+		Attrs: map[string]string{"k": "v"},
+	}
+	in.Inner.Name = "n"
+	tests := []struct {
+		syntax Syntax
+		want   string
+	}{
+		{DotSyntax, "attrs[k]=v&inner.name=n&items[0].name=x&tags=a&tags=b"},
+		{BracketSyntax, "attrs[k]=v&inner[name]=n&items[0][name]=x&tags[]=a&tags[]=b"},
+		{IndexSyntax, "attrs[k]=v&inner.name=n&items[0].name=x&tags[0]=a&tags[1]=b"},
+		{CommaSyntax, "attrs[k]=v&inner.name=n&items[0].name=x&tags=a,b"},
+	}
+	for _, tt := range tests {
+		codec := Codec{Syntax: tt.syntax}
+		content, err := codec.Marshal(in)
+		require.NoError(t, err)
+		got, err := url.QueryUnescape(string(content))
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got)
+
+		var out SyntaxModel
+		require.NoError(t, codec.Unmarshal(content, &out))
+		assert.Equal(t, in, &out)
+	}
+
+	pb := &testData.Complex{Simples: []string{"a", "b"}, Map: map[string]string{"k": "v"}, Simple: &testData.Simple{Component: "c"}}
+	content, err := Codec{Syntax: BracketSyntax}.Marshal(pb)
+	require.NoError(t, err)
+	got, err := url.QueryUnescape(string(content))
+	require.NoError(t, err)
+	assert.Contains(t, got, "simples[]=a&simples[]=b")
+	assert.Contains(t, got, "map[k]=v")
+	assert.Contains(t, got, "very_simple[component]=c")
+
+	var out testData.Complex
+	require.NoError(t, Codec{Syntax: BracketSyntax}.Unmarshal(content, &out))
+	assert.Equal(t, pb.Simples, out.Simples)
+	assert.Equal(t, pb.Map, out.Map)
+	assert.Equal(t, "c", out.Simple.GetComponent())
+}
+
+func TestFormCodecRedact(t *testing.T) {
+	in := &struct {
+		Username string `json:"username"`
+		Password string `json:"password" sensitive:"true"`
+	}{Username: "sraph", Password: "hunter2"}
+
+	b, err := Codec{Redact: true}.Marshal(in)
+	require.NoError(t, err)
+	assert.Equal(t, "password=%5BREDACTED%5D&username=sraph", string(b))
+	assert.Equal(t, "hunter2", in.Password)
+}
+
+func TestFormCodecBytes(t *testing.T) {
+	in := &testData.Complex{Byte: []byte{0xfb, 0xff}, Bytes: wrapperspb.Bytes([]byte{0xfb, 0xff})}
+	for b, want := range map[format.Bytes]string{
+		format.Base64:    "+/8=",
+		format.Base64URL: "-_8=",
+		format.Hex:       "fbff",
+	} {
+		c := Codec{Bytes: b}
+		content, err := c.Marshal(in)
+		require.NoError(t, err)
+		vs, err := url.ParseQuery(string(content))
+		require.NoError(t, err)
+		assert.Equal(t, want, vs.Get("byte"))
+		assert.Equal(t, want, vs.Get("bytes"))
+
+		out := &testData.Complex{}
+		require.NoError(t, c.Unmarshal(content, out))
+		assert.Equal(t, in.Byte, out.Byte)
+		assert.Equal(t, in.Bytes.GetValue(), out.Bytes.GetValue())
+	}
+
+	// every base64 variant decodes.
+	out := &testData.Complex{}
+	require.NoError(t, Codec{}.Unmarshal([]byte("byte=-_8&bytes=%2B%2F8%3D"), out))
+	assert.Equal(t, []byte{0xfb, 0xff}, out.Byte)
+	assert.Equal(t, []byte{0xfb, 0xff}, out.Bytes.GetValue())
+}
+
+func TestFormCodecTime(t *testing.T) {
+	in := &testData.Complex{
+		Timestamp: timestamppb.New(time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)),
+		Duration:  durationpb.New(90 * time.Second),
+	}
+	for _, tt := range []struct {
+		c                   Codec
+		timestamp, duration string
+	}{
+		{Codec{}, "2022-05-01T10:00:00Z", "1m30s"},
+		{Codec{Timestamp: format.UnixSeconds, Duration: format.ISO8601Duration}, "1651399200", "PT1M30S"},
+		{Codec{Timestamp: format.UnixMillis, Duration: format.ProtoJSONDuration}, "1651399200000", "90s"},
+	} {
+		content, err := tt.c.Marshal(in)
+		require.NoError(t, err)
+		vs, err := url.ParseQuery(string(content))
+		require.NoError(t, err)
+		assert.Equal(t, tt.timestamp, vs.Get("timestamp"))
+		assert.Equal(t, tt.duration, vs.Get("duration"))
+
+		out := &testData.Complex{}
+		require.NoError(t, tt.c.Unmarshal(content, out))
+		assert.True(t, proto.Equal(in.Timestamp, out.Timestamp), tt.timestamp)
+		assert.True(t, proto.Equal(in.Duration, out.Duration), tt.duration)
+	}
+
+	out := &testData.Complex{}
+	require.NoError(t, Codec{}.Unmarshal([]byte("duration=P1D"), out))
+	assert.Equal(t, 24*time.Hour, out.Duration.AsDuration())
+}
+
+func TestFormCodecMask(t *testing.T) {
+	in := &testData.Complex{Id: 1, Simple: &testData.Simple{Component: "c"}, Map: map[string]string{"k": "v"}}
+	b, err := Codec{Mask: &fieldmaskpb.FieldMask{Paths: []string{"simple.component", "map", "age"}}}.Marshal(in)
+	require.NoError(t, err)
+	assert.Equal(t, "age=0&map%5Bk%5D=v&very_simple.component=c", string(b))
+
+	out := &testData.Complex{}
+	fm, err := Codec{}.UnmarshalMask([]byte("age=0&very_simple.component=c&map[k]=v&simples=a&simples=b&other=x"), out)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"age", "map", "simple.component", "simples"}, fm.GetPaths())
+	assert.Equal(t, []string{"a", "b"}, out.Simples)
+}
@@ -0,0 +1,417 @@
+package form
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/sraphs/encoding/internal/protopath"
+	"github.com/sraphs/encoding/merge"
+	"github.com/sraphs/encoding/redact"
+	"github.com/sraphs/encoding/validate"
+)
+
+const (
+	// MultipartName is multipart form codec name
+	MultipartName = "form-data"
+
+	defaultMaxPartSize = 32 << 20
+	defaultMaxParts    = 1000
+)
+
+var (
+	// ErrPartTooLarge is returned when a part exceeds MultipartCodec.MaxPartSize.
+	ErrPartTooLarge = errors.New("form: multipart part too large")
+	// ErrTooManyParts is returned when a form has more than MultipartCodec.MaxParts parts.
+	ErrTooManyParts = errors.New("form: too many multipart parts")
+	// ErrNoBoundary is returned when the multipart boundary cannot be found.
+	ErrNoBoundary = errors.New("form: multipart boundary not found")
+)
+
+// FileHeader is a file part of a multipart form.
+type FileHeader struct {
+	Filename string
+	Header   textproto.MIMEHeader
+	Size     int64
+	Content  []byte
+}
+
+var (
+	fileHeaderType = reflect.TypeOf(FileHeader{})
+	bytesType      = reflect.TypeOf([]byte(nil))
+)
+
+// MultipartCodec is a Codec implementation with multipart/form-data.
+//
+// Text parts are decoded with the same path rules as DecodeValues and the
+// form Codec, and the names of the file parts are read the same way. File
+// parts are bound to []byte and FileHeader struct fields (or slices of them
+// for multiple files), and to bytes fields of proto messages.
+type MultipartCodec struct {
+	// Boundary is the boundary written by Marshal, a random one if empty.
+	Boundary string
+	// MaxPartSize limits the size of a single part, 32 MiB if zero.
+	MaxPartSize int64
+	// MaxParts limits the number of parts, 1000 if zero.
+	MaxParts int
+	// Form holds the options of the text parts, encoded and decoded as the
+	// form Codec does with them, such as Syntax, TagName, Converters and the
+	// value formats. Its Merge and Validate options are not used.
+	Form Codec
+	// Merge is how Unmarshal combines data with the fields of the proto
+	// message already set. If zero, the fields of data are set in the
+	// message, appending to its lists.
+	Merge merge.Mode
+	// Validate validates the values decoded by Unmarshal, such as with their
+	// Validate methods, failing with a *validate.Error. No validation if nil.
+	Validate *validate.Registry
+}
+
+func (c MultipartCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	if c.Boundary != "" {
+		if err := w.SetBoundary(c.Boundary); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.Form.Redact {
+		v = redact.Copy(v)
+	}
+	vs, err := c.Form.values(v, true)
+	if err != nil {
+		return nil, err
+	}
+	var files []namedFile
+	if _, ok := v.(proto.Message); !ok {
+		collectFiles(reflect.ValueOf(v), "", c.Form.tagName(), &files)
+	}
+
+	for _, k := range sortedKeys(vs) {
+		for _, value := range vs[k] {
+			if err := w.WriteField(k, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, f := range files {
+		name := f.name
+		if c.Form.Syntax == BracketSyntax {
+			name = renderKey(parseKey(name), true)
+		}
+		h := make(textproto.MIMEHeader)
+		for k, v := range f.Header {
+			h[k] = v
+		}
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+			escapeQuotes(name), escapeQuotes(f.Filename)))
+		if h.Get("Content-Type") == "" {
+			h.Set("Content-Type", "application/octet-stream")
+		}
+		pw, err := w.CreatePart(h)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := pw.Write(f.Content); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c MultipartCodec) Unmarshal(data []byte, v interface{}) error {
+	if err := c.unmarshal(data, v); err != nil {
+		return err
+	}
+	return c.Validate.Validate(c.Name(), v)
+}
+
+func (c MultipartCodec) unmarshal(data []byte, v interface{}) error {
+	boundary, err := Boundary(data)
+	if err != nil {
+		return err
+	}
+
+	vs, files, err := c.readParts(multipart.NewReader(bytes.NewReader(data), boundary))
+	if err != nil {
+		return err
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		rv = rv.Elem()
+	}
+	if m, ok := v.(proto.Message); ok {
+		return c.decodeProto(m, vs, files)
+	} else if m, ok := reflect.Indirect(reflect.ValueOf(v)).Interface().(proto.Message); ok {
+		return c.decodeProto(m, vs, files)
+	}
+
+	if err := c.Form.decode(vs, v); err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := bindFile(rv, parseKey(f.name), c.Form.tagName(), f.FileHeader); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (MultipartCodec) Name() string {
+	return MultipartName
+}
+
+// Boundary returns the boundary of the multipart body in data, taken from
+// its first delimiter line: the first line starting with "--" whose boundary
+// also closes the body, after the preamble if any.
+func Boundary(data []byte) (string, error) {
+	r := bufio.NewReader(bytes.NewReader(data))
+	for {
+		line, err := r.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(line, "--") && len(line) > 2 {
+			boundary := strings.TrimRight(line[2:], " \t")
+			if bytes.Contains(data, []byte("--"+boundary+"--")) {
+				return boundary, nil
+			}
+		}
+		if err != nil {
+			return "", ErrNoBoundary
+		}
+	}
+}
+
+type namedFile struct {
+	name string
+	*FileHeader
+}
+
+func (c MultipartCodec) readParts(r *multipart.Reader) (url.Values, []namedFile, error) {
+	maxSize := c.MaxPartSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxPartSize
+	}
+	maxParts := c.MaxParts
+	if maxParts <= 0 {
+		maxParts = defaultMaxParts
+	}
+
+	vs := make(url.Values)
+	var files []namedFile
+	for n := 0; ; n++ {
+		p, err := r.NextPart()
+		if err == io.EOF {
+			return vs, files, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if n >= maxParts {
+			return nil, nil, ErrTooManyParts
+		}
+		name := p.FormName()
+		if name == "" {
+			continue
+		}
+		// read one extra byte to detect parts over the limit.
+		b, err := io.ReadAll(io.LimitReader(p, maxSize+1))
+		if err != nil {
+			return nil, nil, err
+		}
+		if int64(len(b)) > maxSize {
+			return nil, nil, fmt.Errorf("%w: %q", ErrPartTooLarge, name)
+		}
+		if p.FileName() == "" {
+			vs.Add(name, string(b))
+			continue
+		}
+		files = append(files, namedFile{name: name, FileHeader: &FileHeader{
+			Filename: p.FileName(),
+			Header:   p.Header,
+			Size:     int64(len(b)),
+			Content:  b,
+		}})
+	}
+}
+
+// decodeProto decodes the parts as the form values of m according to
+// c.Merge, passing file contents as text in the bytes encoding of the form,
+// which is how bytes fields are read. Files are only bound to bytes fields.
+func (c MultipartCodec) decodeProto(m proto.Message, vs url.Values, files []namedFile) error {
+	d := c.Form.pathDecoder()
+	md := m.ProtoReflect().Descriptor()
+	for _, f := range files {
+		fd := d.FieldAt(md, f.name)
+		if fd == nil {
+			// ignore unexpected field.
+			continue
+		}
+		if !protopath.IsBytes(fd) {
+			return fmt.Errorf("form: cannot bind file %q to %s field %s", f.Filename, fd.Kind(), fd.FullName())
+		}
+		vs.Add(f.name, c.Form.Bytes.Encode(f.Content))
+	}
+	return c.Merge.Decode(m, func(m proto.Message) error {
+		return d.Decode(m, vs)
+	})
+}
+
+// bindFile sets the field at path in rv to the file. List indexes select the
+// elements of slices of structs, growing them as needed, and are ignored for
+// slices of files, which files are appended to.
+func bindFile(rv reflect.Value, path []keySegment, tagName string, fh *FileHeader) error {
+	for _, seg := range path {
+		for rv.Kind() == reflect.Ptr && rv.Type().Elem().Kind() == reflect.Struct && rv.Type().Elem() != fileHeaderType {
+			if rv.IsNil() {
+				rv.Set(reflect.New(rv.Type().Elem()))
+			}
+			rv = rv.Elem()
+		}
+		if rv.Kind() == reflect.Slice && seg.bracket && isIndex(seg.name) {
+			if isFileType(rv.Type().Elem()) {
+				continue
+			}
+			i, _ := strconv.Atoi(seg.name)
+			if i >= protopath.MaxListIndex {
+				return fmt.Errorf("form: list index %d of file %q out of range", i, fh.Filename)
+			}
+			if n := i + 1 - rv.Len(); n > 0 {
+				rv.Set(reflect.AppendSlice(rv, reflect.MakeSlice(rv.Type(), n, n)))
+			}
+			rv = rv.Index(i)
+			continue
+		}
+		if rv.Kind() != reflect.Struct {
+			return nil
+		}
+		f, ok := fieldByTag(rv.Type(), seg.name, tagName)
+		if !ok {
+			// ignore unexpected field.
+			return nil
+		}
+		rv = rv.FieldByIndex(f.Index)
+	}
+
+	switch t := rv.Type(); {
+	case t == bytesType:
+		rv.SetBytes(fh.Content)
+	case t == fileHeaderType:
+		rv.Set(reflect.ValueOf(*fh))
+	case t == reflect.PtrTo(fileHeaderType):
+		rv.Set(reflect.ValueOf(fh))
+	case t.Kind() == reflect.Slice && t.Elem() == bytesType:
+		rv.Set(reflect.Append(rv, reflect.ValueOf(fh.Content)))
+	case t.Kind() == reflect.Slice && t.Elem() == fileHeaderType:
+		rv.Set(reflect.Append(rv, reflect.ValueOf(*fh)))
+	case t.Kind() == reflect.Slice && t.Elem() == reflect.PtrTo(fileHeaderType):
+		rv.Set(reflect.Append(rv, reflect.ValueOf(fh)))
+	default:
+		return fmt.Errorf("form: cannot bind file %q to %s", fh.Filename, t)
+	}
+	return nil
+}
+
+func isFileType(t reflect.Type) bool {
+	return t == bytesType || t == fileHeaderType || t == reflect.PtrTo(fileHeaderType)
+}
+
+// collectFiles appends the file fields of the struct rv to files.
+func collectFiles(rv reflect.Value, prefix, tagName string, files *[]namedFile) {
+	rv = reflect.Indirect(rv)
+	if rv.Kind() != reflect.Struct {
+		return
+	}
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		name := fieldName(sf, tagName)
+		if name == "-" {
+			continue
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		fv := rv.Field(i)
+		switch ft := sf.Type; {
+		case ft == bytesType:
+			if fv.Len() > 0 {
+				*files = append(*files, namedFile{name: name, FileHeader: &FileHeader{Filename: sf.Name, Content: fv.Bytes()}})
+			}
+		case ft == fileHeaderType:
+			fh := fv.Interface().(FileHeader)
+			*files = append(*files, namedFile{name: name, FileHeader: &fh})
+		case ft == reflect.PtrTo(fileHeaderType):
+			if !fv.IsNil() {
+				*files = append(*files, namedFile{name: name, FileHeader: fv.Interface().(*FileHeader)})
+			}
+		case ft.Kind() == reflect.Slice && (ft.Elem() == bytesType || ft.Elem() == fileHeaderType || ft.Elem() == reflect.PtrTo(fileHeaderType)):
+			for j := 0; j < fv.Len(); j++ {
+				switch e := fv.Index(j).Interface().(type) {
+				case []byte:
+					*files = append(*files, namedFile{name: name, FileHeader: &FileHeader{Filename: sf.Name, Content: e}})
+				case FileHeader:
+					*files = append(*files, namedFile{name: name, FileHeader: &e})
+				case *FileHeader:
+					*files = append(*files, namedFile{name: name, FileHeader: e})
+				}
+			}
+		case ft.Kind() == reflect.Struct || ft.Kind() == reflect.Ptr && ft.Elem().Kind() == reflect.Struct:
+			collectFiles(fv, name, tagName, files)
+		}
+	}
+}
+
+func fieldByTag(t reflect.Type, name, tagName string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath == "" && fieldName(sf, tagName) == name {
+			return sf, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func fieldName(sf reflect.StructField, tagName string) string {
+	name := strings.Split(sf.Tag.Get(tagName), ",")[0]
+	if name == "" {
+		return sf.Name
+	}
+	return name
+}
+
+func sortedKeys(vs url.Values) []string {
+	keys := make([]string, 0, len(vs))
+	for k := range vs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
@@ -0,0 +1,207 @@
+package form
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	testData "github.com/sraphs/encoding/internal/testdata/complex"
+)
+
+type uploadMeta struct {
+	Title string `json:"title"`
+	Raw   []byte `json:"raw"`
+}
+
+type uploadRequest struct {
+	Name        string        `json:"name"`
+	Tags        []string      `json:"tags"`
+	Avatar      *FileHeader   `json:"avatar"`
+	Attachments []*FileHeader `json:"attachments"`
+	Meta        uploadMeta    `json:"meta"`
+}
+
+func TestMultipartCodec_Struct(t *testing.T) {
+	c := MultipartCodec{Boundary: "sraph-boundary"}
+	in := &uploadRequest{
+		Name: "sraph",
+		Tags: []string{"a", "b"},
+		Avatar: &FileHeader{
+			Filename: "avatar.png",
+			Header:   textproto.MIMEHeader{"Content-Type": {"image/png"}},
+			Content:  []byte("png"),
+		},
+		Attachments: []*FileHeader{
+			{Filename: "a.txt", Content: []byte("a")},
+			{Filename: "b.txt", Content: []byte("b")},
+		},
+		Meta: uploadMeta{Title: "t", Raw: []byte("raw")},
+	}
+
+	b, err := c.Marshal(in)
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(b, []byte("--sraph-boundary\r\n")))
+	assert.Contains(t, string(b), `Content-Disposition: form-data; name="avatar"; filename="avatar.png"`)
+	assert.Contains(t, string(b), "Content-Type: image/png")
+	assert.NotContains(t, string(b), `name="avatar.Filename"`)
+
+	boundary, err := Boundary(b)
+	require.NoError(t, err)
+	assert.Equal(t, "sraph-boundary", boundary)
+
+	out := &uploadRequest{}
+	require.NoError(t, c.Unmarshal(b, out))
+	assert.Equal(t, "sraph", out.Name)
+	assert.Equal(t, []string{"a", "b"}, out.Tags)
+	require.NotNil(t, out.Avatar)
+	assert.Equal(t, "avatar.png", out.Avatar.Filename)
+	assert.Equal(t, []byte("png"), out.Avatar.Content)
+	assert.Equal(t, int64(3), out.Avatar.Size)
+	require.Len(t, out.Attachments, 2)
+	assert.Equal(t, "b.txt", out.Attachments[1].Filename)
+	assert.Equal(t, "t", out.Meta.Title)
+	assert.Equal(t, []byte("raw"), out.Meta.Raw)
+}
+
+func TestMultipartCodec_Proto(t *testing.T) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	require.NoError(t, w.WriteField("id", "2233"))
+	require.NoError(t, w.WriteField("very_simple.component", "5566"))
+	require.NoError(t, w.WriteField("simples", "a"))
+	require.NoError(t, w.WriteField("simples", "b"))
+	fw, err := w.CreateFormFile("byte", "data.bin")
+	require.NoError(t, err)
+	_, err = fw.Write([]byte{0xfb, 0xff, 0x00})
+	require.NoError(t, err)
+	fw, err = w.CreateFormFile("bytes", "wrapped.bin")
+	require.NoError(t, err)
+	_, err = fw.Write([]byte("123"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	out := &testData.Complex{}
+	require.NoError(t, (MultipartCodec{}).Unmarshal(buf.Bytes(), out))
+	assert.Equal(t, int64(2233), out.Id)
+	assert.Equal(t, "5566", out.Simple.GetComponent())
+	assert.Equal(t, []string{"a", "b"}, out.Simples)
+	assert.Equal(t, []byte{0xfb, 0xff, 0x00}, out.Byte)
+	assert.Equal(t, []byte("123"), out.Bytes.GetValue())
+
+	b, err := (MultipartCodec{}).Marshal(&testData.Complex{Id: 1, String_: wrapperspb.String("s")})
+	require.NoError(t, err)
+	out2 := &testData.Complex{}
+	require.NoError(t, (MultipartCodec{}).Unmarshal(b, out2))
+	assert.Equal(t, int64(1), out2.Id)
+	assert.Equal(t, "s", out2.String_.GetValue())
+}
+
+func TestMultipartCodec_Limits(t *testing.T) {
+	b, err := (MultipartCodec{}).Marshal(&uploadRequest{Name: "sraph", Tags: []string{"a", "b"}})
+	require.NoError(t, err)
+
+	err = (MultipartCodec{MaxPartSize: 2}).Unmarshal(b, &uploadRequest{})
+	assert.True(t, errors.Is(err, ErrPartTooLarge), "got %v", err)
+
+	err = (MultipartCodec{MaxParts: 2}).Unmarshal(b, &uploadRequest{})
+	assert.Equal(t, ErrTooManyParts, err)
+
+	err = (MultipartCodec{}).Unmarshal([]byte("name=sraph"), &uploadRequest{})
+	assert.Equal(t, ErrNoBoundary, err)
+	err = (MultipartCodec{}).Unmarshal([]byte("--x\r\nno closing delimiter\r\n"), &uploadRequest{})
+	assert.Equal(t, ErrNoBoundary, err)
+
+	_, err = (MultipartCodec{Boundary: strings.Repeat("x", 71)}).Marshal(&uploadRequest{})
+	assert.Error(t, err)
+}
+
+type taggedMeta struct {
+	Title string `form:"title"`
+	Raw   []byte `form:"raw"`
+}
+
+type uploadDoc struct {
+	Title string      `form:"title"`
+	File  *FileHeader `form:"file"`
+}
+
+type taggedUpload struct {
+	Name string      `form:"name"`
+	Tags []string    `form:"tags"`
+	Meta taggedMeta  `form:"meta"`
+	Docs []uploadDoc `form:"docs"`
+}
+
+func TestMultipartCodec_FormOptions(t *testing.T) {
+	c := MultipartCodec{Form: Codec{TagName: "form", Syntax: CommaSyntax}}
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	require.NoError(t, w.WriteField("name", "sraph"))
+	require.NoError(t, w.WriteField("tags", "a,b"))
+	require.NoError(t, w.WriteField("docs[1][title]", "second"))
+	fw, err := w.CreateFormFile("docs[1][file]", "b.txt")
+	require.NoError(t, err)
+	_, err = fw.Write([]byte("b"))
+	require.NoError(t, err)
+	fw, err = w.CreateFormFile("meta[raw]", "raw.bin")
+	require.NoError(t, err)
+	_, err = fw.Write([]byte("raw"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	out := &taggedUpload{}
+	require.NoError(t, c.Unmarshal(buf.Bytes(), out))
+	assert.Equal(t, "sraph", out.Name)
+	assert.Equal(t, []string{"a", "b"}, out.Tags)
+	assert.Equal(t, []byte("raw"), out.Meta.Raw)
+	require.Len(t, out.Docs, 2)
+	assert.Equal(t, "second", out.Docs[1].Title)
+	require.NotNil(t, out.Docs[1].File)
+	assert.Equal(t, []byte("b"), out.Docs[1].File.Content)
+
+	b, err := (MultipartCodec{Form: Codec{TagName: "form", Syntax: BracketSyntax}}).Marshal(&taggedUpload{
+		Name: "sraph",
+		Meta: taggedMeta{Title: "t", Raw: []byte("raw")},
+	})
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `name="meta[raw]"; filename="Raw"`)
+	assert.Contains(t, string(b), `name="meta[title]"`)
+}
+
+func TestMultipartCodec_ProtoFileField(t *testing.T) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	fw, err := w.CreateFormFile("no_one", "a.txt")
+	require.NoError(t, err)
+	_, err = fw.Write([]byte("a"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	err = (MultipartCodec{}).Unmarshal(buf.Bytes(), &testData.Complex{})
+	assert.EqualError(t, err, `form: cannot bind file "a.txt" to string field testdata.complex.Complex.no_one`)
+}
+
+func TestMultipartCodec_Preamble(t *testing.T) {
+	// RFC 2046 allows a preamble before the first delimiter line, here with
+	// a line looking like one.
+	data := "\r\nThis is a multi-part message.\r\n-- not a delimiter\r\n" +
+		"--sraph\r\nContent-Disposition: form-data; name=\"name\"\r\n\r\nx\r\n" +
+		"--sraph\r\nContent-Disposition: form-data; name=\"tags\"\r\n\r\na\r\n" +
+		"--sraph--\r\nepilogue\r\n"
+	boundary, err := Boundary([]byte(data))
+	require.NoError(t, err)
+	assert.Equal(t, "sraph", boundary)
+
+	var out uploadRequest
+	require.NoError(t, (MultipartCodec{}).Unmarshal([]byte(data), &out))
+	assert.Equal(t, "x", out.Name)
+	assert.Equal(t, []string{"a"}, out.Tags)
+}
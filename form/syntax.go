@@ -0,0 +1,221 @@
+package form
+
+import (
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/sraphs/encoding/internal/protopath"
+)
+
+// Syntax is the way nested keys and lists are written in a form.
+//
+// Decoding accepts the key forms of every syntax, so a.b, a[b], list[] and
+// list[0].name all work with any of them; the syntax only selects whether
+// list values are split on commas. Encoding writes the selected syntax, with
+// lists of a single item written as a plain key.
+type Syntax int
+
+const (
+	// DotSyntax nests keys with dots and repeats the key for every list item:
+	// a.b=1&list=x&list=y&map[k]=v.
+	DotSyntax Syntax = iota
+	// BracketSyntax nests keys with brackets, PHP and Rails style:
+	// a[b]=1&list[]=x&list[]=y&map[k]=v.
+	BracketSyntax
+	// IndexSyntax nests keys with dots and indexes every list item:
+	// a.b=1&list[0]=x&list[1]=y&map[k]=v.
+	IndexSyntax
+	// CommaSyntax nests keys with dots and joins list items with commas:
+	// a.b=1&list=x,y&map[k]=v.
+	CommaSyntax
+)
+
+// keySegment is an element of a form key: a field name, or the content of
+// a bracket, which is a map key or a list index.
+type keySegment struct {
+	name    string
+	bracket bool
+}
+
+// parseKey splits a form key into its segments. Empty brackets, the list
+// append marker, are dropped.
+func parseKey(key string) []keySegment {
+	var segs []keySegment
+	for len(key) > 0 {
+		switch key[0] {
+		case '.':
+			key = key[1:]
+		case '[':
+			end := strings.IndexByte(key, ']')
+			if end < 0 {
+				segs = append(segs, keySegment{name: key})
+				return segs
+			}
+			if end > 1 {
+				segs = append(segs, keySegment{name: key[1:end], bracket: true})
+			}
+			key = key[end+1:]
+		default:
+			end := strings.IndexAny(key, ".[")
+			if end < 0 {
+				end = len(key)
+			}
+			segs = append(segs, keySegment{name: key[:end]})
+			key = key[end:]
+		}
+	}
+	return segs
+}
+
+func isIndex(s string) bool {
+	_, err := strconv.ParseUint(s, 10, 32)
+	return err == nil
+}
+
+// protoPath returns the field path the proto decoder expects: one element
+// per field or map key, with list indexes and numeric map keys kept in
+// brackets on the field name.
+func protoPath(segs []keySegment) []string {
+	path := make([]string, 0, len(segs))
+	for _, s := range segs {
+		if s.bracket && isIndex(s.name) && len(path) > 0 {
+			path[len(path)-1] += "[" + s.name + "]"
+			continue
+		}
+		path = append(path, s.name)
+	}
+	return path
+}
+
+// structKey returns the key the go-playground decoder expects for a value of
+// type t: struct fields joined by dots, map keys and list indexes in brackets.
+// It also reports whether the key names a list.
+func structKey(t reflect.Type, segs []keySegment, tagName string) (string, bool) {
+	var b strings.Builder
+	for i, s := range segs {
+		t = indirectType(t)
+		var kind reflect.Kind
+		if t != nil {
+			kind = t.Kind()
+		}
+		switch {
+		case kind == reflect.Map:
+			b.WriteString("[" + s.name + "]")
+			t = t.Elem()
+		case (kind == reflect.Slice || kind == reflect.Array) && isIndex(s.name):
+			b.WriteString("[" + s.name + "]")
+			t = t.Elem()
+		default:
+			if kind == reflect.Struct {
+				t = fieldType(t, s.name, tagName)
+			} else {
+				t = nil
+			}
+			if i > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(s.name)
+		}
+	}
+	t = indirectType(t)
+	isList := t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() != reflect.Uint8
+	return b.String(), isList
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// fieldType returns the type of the field named name, looking into embedded
+// structs, or nil if there is no such field.
+func fieldType(t reflect.Type, name, tagName string) reflect.Type {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		tag := strings.Split(sf.Tag.Get(tagName), ",")[0]
+		if tag == name || tag == "" && sf.Name == name {
+			return sf.Type
+		}
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && tag == "" && ft.Kind() == reflect.Struct {
+			if t := fieldType(ft, name, tagName); t != nil {
+				return t
+			}
+		}
+	}
+	return nil
+}
+
+// structValues rewrites the keys of vs for the go-playground decoder of
+// type t, splitting list values on commas for CommaSyntax.
+func structValues(t reflect.Type, vs url.Values, syntax Syntax, tagName string) url.Values {
+	out := make(url.Values, len(vs))
+	for k, values := range vs {
+		key, isList := structKey(t, parseKey(k), tagName)
+		if syntax == CommaSyntax && isList {
+			values = protopath.SplitComma(values)
+		}
+		out[key] = append(out[key], values...)
+	}
+	return out
+}
+
+// encodeSyntax rewrites the keys of vs, as written by EncodeValues or the
+// go-playground encoder, in the given syntax.
+func encodeSyntax(vs url.Values, syntax Syntax) url.Values {
+	if syntax == DotSyntax {
+		return vs
+	}
+	out := make(url.Values, len(vs))
+	for k, values := range vs {
+		segs := parseKey(k)
+		switch syntax {
+		case BracketSyntax:
+			key := renderKey(segs, true)
+			if len(values) > 1 {
+				key += "[]"
+			}
+			out[key] = append(out[key], values...)
+		case IndexSyntax:
+			key := renderKey(segs, false)
+			if len(values) > 1 {
+				for i, v := range values {
+					out[key+"["+strconv.Itoa(i)+"]"] = []string{v}
+				}
+				continue
+			}
+			out[key] = append(out[key], values...)
+		case CommaSyntax:
+			key := renderKey(segs, false)
+			out[key] = append(out[key], strings.Join(values, ","))
+		default:
+			out[k] = values
+		}
+	}
+	return out
+}
+
+func renderKey(segs []keySegment, brackets bool) string {
+	var b strings.Builder
+	for i, s := range segs {
+		switch {
+		case i == 0:
+			b.WriteString(s.name)
+		case brackets || s.bracket:
+			b.WriteString("[" + s.name + "]")
+		default:
+			b.WriteString("." + s.name)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,53 @@
+// Package format defines the text forms of the values the string based
+// codecs, env, flag and form, write and read for proto messages.
+package format
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// Bytes is the text encoding of bytes values. The zero value is Base64.
+type Bytes int
+
+const (
+	// Base64 is standard base64 with padding, as written by protojson.
+	Base64 Bytes = iota
+	// Base64URL is URL safe base64 with padding.
+	Base64URL
+	// Base64Raw is standard base64 without padding.
+	Base64Raw
+	// Base64RawURL is URL safe base64 without padding.
+	Base64RawURL
+	// Hex is lower case hexadecimal.
+	Hex
+)
+
+// Encode returns the text form of p.
+func (b Bytes) Encode(p []byte) string {
+	switch b {
+	case Base64URL:
+		return base64.URLEncoding.EncodeToString(p)
+	case Base64Raw:
+		return base64.RawStdEncoding.EncodeToString(p)
+	case Base64RawURL:
+		return base64.RawURLEncoding.EncodeToString(p)
+	case Hex:
+		return hex.EncodeToString(p)
+	default:
+		return base64.StdEncoding.EncodeToString(p)
+	}
+}
+
+// Decode returns the bytes of the text form s. All the base64 variants are
+// accepted whatever b is, standard or URL safe, padded or not; Hex only
+// accepts hexadecimal.
+func (b Bytes) Decode(s string) ([]byte, error) {
+	if b == Hex {
+		return hex.DecodeString(s)
+	}
+	s = strings.TrimRight(s, "=")
+	s = strings.NewReplacer("-", "+", "_", "/").Replace(s)
+	return base64.RawStdEncoding.DecodeString(s)
+}
@@ -0,0 +1,133 @@
+package format
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBytes(t *testing.T) {
+	p := []byte{0xfb, 0xff, 0x01}
+	for b, want := range map[Bytes]string{
+		Base64:       "+/8B",
+		Base64URL:    "-_8B",
+		Base64Raw:    "+/8B",
+		Base64RawURL: "-_8B",
+		Hex:          "fbff01",
+	} {
+		assert.Equal(t, want, b.Encode(p), b)
+		got, err := b.Decode(want)
+		require.NoError(t, err)
+		assert.Equal(t, p, got)
+	}
+
+	assert.Equal(t, "+/8=", Base64.Encode(p[:2]))
+	assert.Equal(t, "-_8=", Base64URL.Encode(p[:2]))
+	assert.Equal(t, "+/8", Base64Raw.Encode(p[:2]))
+	assert.Equal(t, "-_8", Base64RawURL.Encode(p[:2]))
+
+	// every base64 variant decodes whatever the format.
+	for _, s := range []string{"+/8=", "-_8=", "+/8", "-_8"} {
+		for _, b := range []Bytes{Base64, Base64URL, Base64Raw, Base64RawURL} {
+			got, err := b.Decode(s)
+			require.NoError(t, err, s)
+			assert.Equal(t, p[:2], got, s)
+		}
+	}
+
+	_, err := Hex.Decode("+/8=")
+	assert.Error(t, err)
+	_, err = Base64.Decode("!")
+	assert.Error(t, err)
+}
+
+func TestTimestamp(t *testing.T) {
+	ts := time.Date(2022, 5, 1, 10, 0, 0, 2e6, time.UTC)
+	for f, want := range map[Timestamp]string{
+		RFC3339:          "2022-05-01T10:00:00.002Z",
+		UnixSeconds:      "1651399200.002",
+		UnixMillis:       "1651399200002",
+		time.RFC1123:     "Sun, 01 May 2022 10:00:00 UTC",
+		"2006-01-02 15h": "2022-05-01 10h",
+	} {
+		assert.Equal(t, want, f.Format(ts), f)
+		got, err := f.Parse(want)
+		require.NoError(t, err, f)
+		assert.True(t, ts.Truncate(time.Hour).Equal(got.Truncate(time.Hour)), f)
+
+		// RFC 3339 decodes whatever the format.
+		got, err = f.Parse("2022-05-01T10:00:00.002Z")
+		require.NoError(t, err, f)
+		assert.True(t, ts.Equal(got), f)
+	}
+
+	assert.Equal(t, "1651399200", UnixSeconds.Format(ts.Truncate(time.Second)))
+	assert.Equal(t, "-0.5", UnixSeconds.Format(time.Unix(0, -5e8)))
+	got, err := UnixSeconds.Parse("-0.5")
+	require.NoError(t, err)
+	assert.True(t, time.Unix(0, -5e8).Equal(got))
+	got, err = UnixMillis.Parse("1500.5")
+	require.NoError(t, err)
+	assert.True(t, time.Unix(1, 500500000).Equal(got))
+
+	_, err = UnixSeconds.Parse("soon")
+	assert.Error(t, err)
+	_, err = RFC3339.Parse("1651399200")
+	assert.Error(t, err)
+}
+
+func TestDuration(t *testing.T) {
+	d := 2*time.Minute + 500*time.Millisecond
+	for f, want := range map[Duration]string{
+		DefaultDuration:   "2m0.5s",
+		GoDuration:        "2m0.5s",
+		ProtoJSONDuration: "120.500s",
+		ISO8601Duration:   "PT2M0.5S",
+	} {
+		assert.Equal(t, want, f.Format(d), f)
+		got, err := f.Parse(want)
+		require.NoError(t, err, f)
+		assert.Equal(t, d, got, f)
+	}
+
+	for d, want := range map[time.Duration]string{
+		0:                           "PT0S",
+		-90 * time.Second:           "-PT1M30S",
+		26 * time.Hour:              "PT26H",
+		time.Hour + time.Nanosecond: "PT1H0.000000001S",
+	} {
+		assert.Equal(t, want, ISO8601Duration.Format(d))
+		got, err := ISO8601Duration.Parse(want)
+		require.NoError(t, err, want)
+		assert.Equal(t, d, got, want)
+	}
+	assert.Equal(t, "-1.500s", ProtoJSONDuration.Format(-1500*time.Millisecond))
+	assert.Equal(t, "0s", ProtoJSONDuration.Format(0))
+
+	// every form decodes whatever the format.
+	for s, want := range map[string]time.Duration{
+		"P1D":        24 * time.Hour,
+		"P1W":        7 * 24 * time.Hour,
+		"P1DT12H":    36 * time.Hour,
+		"PT0.5S":     500 * time.Millisecond,
+		"PT1,5M":     90 * time.Second,
+		"PT1.5H":     90 * time.Minute,
+		"-PT2M":      -2 * time.Minute,
+		"1h30m":      90 * time.Minute,
+		"120.500s":   d,
+		"-0.000001s": -time.Microsecond,
+	} {
+		for _, f := range []Duration{DefaultDuration, GoDuration, ProtoJSONDuration, ISO8601Duration} {
+			got, err := f.Parse(s)
+			require.NoError(t, err, s)
+			assert.Equal(t, want, got, s)
+		}
+	}
+
+	for _, s := range []string{"P", "PT", "P1Y", "P2M", "PT1D", "P1H", "PTS", "P-1D", "PT1H1H1", "P1DT", "1x"} {
+		_, err := ISO8601Duration.Parse(s)
+		assert.Error(t, err, s)
+	}
+}
@@ -0,0 +1,266 @@
+package format
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Timestamp is the text form of timestamps: RFC3339, UnixSeconds,
+// UnixMillis, or any other value taken as a time layout, such as
+// time.RFC1123. The zero value is RFC3339.
+type Timestamp string
+
+const (
+	// RFC3339 is RFC 3339 in UTC with 0, 3, 6 or 9 fractional digits, as
+	// written by protojson.
+	RFC3339 Timestamp = ""
+	// UnixSeconds is the number of seconds since the Unix epoch, with
+	// fractional digits for the sub-second part.
+	UnixSeconds Timestamp = "unix"
+	// UnixMillis is the number of milliseconds since the Unix epoch.
+	UnixMillis Timestamp = "unixmilli"
+)
+
+// Format returns the text form of t.
+func (f Timestamp) Format(t time.Time) string {
+	switch f {
+	case RFC3339:
+		x := t.UTC().Format("2006-01-02T15:04:05.000000000")
+		x = strings.TrimSuffix(x, "000")
+		x = strings.TrimSuffix(x, "000")
+		x = strings.TrimSuffix(x, ".000")
+		return x + "Z"
+	case UnixSeconds:
+		return formatDecimal(t.Unix(), int64(t.Nanosecond()), 9)
+	case UnixMillis:
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	default:
+		return t.UTC().Format(string(f))
+	}
+}
+
+// Parse returns the time of the text form s. RFC 3339 is accepted whatever
+// f is.
+func (f Timestamp) Parse(s string) (time.Time, error) {
+	var t time.Time
+	var err error
+	switch f {
+	case RFC3339:
+		return time.Parse(time.RFC3339Nano, s)
+	case UnixSeconds:
+		var sec, nsec int64
+		if sec, nsec, err = parseDecimal(s, 9); err == nil {
+			t = time.Unix(sec, nsec)
+		}
+	case UnixMillis:
+		var msec, nsec int64
+		if msec, nsec, err = parseDecimal(s, 6); err == nil {
+			t = time.UnixMilli(msec).Add(time.Duration(nsec))
+		}
+	default:
+		t, err = time.Parse(string(f), s)
+	}
+	if err != nil {
+		if rt, rerr := time.Parse(time.RFC3339Nano, s); rerr == nil {
+			return rt, nil
+		}
+		return time.Time{}, err
+	}
+	return t, nil
+}
+
+// Duration is the text form of durations. The zero value, DefaultDuration,
+// keeps the form of the codec.
+type Duration int
+
+const (
+	// DefaultDuration is the form the codec writes without option: Go
+	// syntax for env and form, the protojson form for flag.
+	DefaultDuration Duration = iota
+	// GoDuration is Go syntax, as written by time.Duration.String, such as
+	// 2m0.5s.
+	GoDuration
+	// ProtoJSONDuration is the protojson form, seconds with 0, 3, 6 or 9
+	// fractional digits, such as 120.500s.
+	ProtoJSONDuration
+	// ISO8601Duration is the ISO 8601 form, such as PT2M0.5S, in hours,
+	// minutes and seconds.
+	ISO8601Duration
+)
+
+// Format returns the text form of d; DefaultDuration is GoDuration.
+func (f Duration) Format(d time.Duration) string {
+	switch f {
+	case ProtoJSONDuration:
+		secs, nanos := int64(d/time.Second), int64(d%time.Second)
+		sign := ""
+		if secs < 0 || nanos < 0 {
+			sign, secs, nanos = "-", -secs, -nanos
+		}
+		x := fmt.Sprintf("%s%d.%09d", sign, secs, nanos)
+		x = strings.TrimSuffix(x, "000")
+		x = strings.TrimSuffix(x, "000")
+		x = strings.TrimSuffix(x, ".000")
+		return x + "s"
+	case ISO8601Duration:
+		return formatISO8601(d)
+	default:
+		return d.String()
+	}
+}
+
+// Parse returns the duration of the text form s. Every form is accepted
+// whatever f is: Go syntax, which includes the protojson form, and ISO 8601.
+func (f Duration) Parse(s string) (time.Duration, error) {
+	if iso := strings.TrimLeft(s, "+-"); strings.HasPrefix(iso, "P") {
+		d, err := parseISO8601(iso)
+		if err != nil {
+			return 0, fmt.Errorf("format: invalid ISO 8601 duration %q: %w", s, err)
+		}
+		if strings.HasPrefix(s, "-") {
+			d = -d
+		}
+		return d, nil
+	}
+	return time.ParseDuration(s)
+}
+
+func formatISO8601(d time.Duration) string {
+	if d == 0 {
+		return "PT0S"
+	}
+	var b strings.Builder
+	// uint64 holds the absolute value of math.MinInt64.
+	u := uint64(d)
+	if d < 0 {
+		b.WriteByte('-')
+		u = -u
+	}
+	b.WriteString("PT")
+	h := u / uint64(time.Hour)
+	u -= h * uint64(time.Hour)
+	m := u / uint64(time.Minute)
+	u -= m * uint64(time.Minute)
+	if h > 0 {
+		b.WriteString(strconv.FormatUint(h, 10) + "H")
+	}
+	if m > 0 {
+		b.WriteString(strconv.FormatUint(m, 10) + "M")
+	}
+	if u > 0 {
+		secs, nanos := u/uint64(time.Second), u%uint64(time.Second)
+		b.WriteString(formatDecimal(int64(secs), int64(nanos), 9) + "S")
+	}
+	return b.String()
+}
+
+// parseISO8601 parses an ISO 8601 duration without sign, such as P1DT2H.
+// Years and months, whose lengths vary, are not supported; a day is 24
+// hours and a week 7 days.
+func parseISO8601(s string) (time.Duration, error) {
+	s = strings.TrimPrefix(s, "P")
+	if s == "" || strings.HasSuffix(s, "T") {
+		return 0, errors.New("no components")
+	}
+	var d time.Duration
+	inTime := false
+	for s != "" {
+		if s[0] == 'T' {
+			if inTime {
+				return 0, errors.New("unexpected T")
+			}
+			inTime = true
+			s = s[1:]
+			continue
+		}
+		n := strings.IndexAny(s, "YMWDHS")
+		if n <= 0 {
+			return 0, fmt.Errorf("invalid component %q", s)
+		}
+		number, designator := s[:n], s[n]
+		s = s[n+1:]
+
+		var unit time.Duration
+		switch {
+		case designator == 'W' && !inTime:
+			unit = 7 * 24 * time.Hour
+		case designator == 'D' && !inTime:
+			unit = 24 * time.Hour
+		case designator == 'H' && inTime:
+			unit = time.Hour
+		case designator == 'M' && inTime:
+			unit = time.Minute
+		case designator == 'S' && inTime:
+			unit = time.Second
+		case designator == 'Y' || designator == 'M':
+			return 0, errors.New("years and months are not supported")
+		default:
+			return 0, fmt.Errorf("invalid component %q", number+string(designator))
+		}
+
+		number = strings.Replace(number, ",", ".", 1)
+		whole, frac, err := parseDecimal(number, 9)
+		if err != nil || whole < 0 || frac < 0 {
+			return 0, fmt.Errorf("invalid number %q", number)
+		}
+		if whole > int64((1<<63-1)/unit) {
+			return 0, errors.New("overflow")
+		}
+		part := time.Duration(whole)*unit + time.Duration(float64(frac)/1e9*float64(unit))
+		if d+part < d {
+			return 0, errors.New("overflow")
+		}
+		d += part
+	}
+	return d, nil
+}
+
+// formatDecimal returns whole.frac, frac having digits digits, without
+// trailing zeros.
+func formatDecimal(whole, frac int64, digits int) string {
+	sign := ""
+	if whole < 0 && frac > 0 {
+		// time.Unix keeps the nanoseconds positive.
+		whole, frac = whole+1, 1e9-frac
+		if whole == 0 {
+			sign = "-"
+		}
+	}
+	if frac == 0 {
+		return sign + strconv.FormatInt(whole, 10)
+	}
+	return strings.TrimRight(fmt.Sprintf("%s%d.%0*d", sign, whole, digits, frac), "0")
+}
+
+// parseDecimal parses a decimal number into its whole part and its
+// fractional part scaled to digits digits, both with the sign of s.
+func parseDecimal(s string, digits int) (int64, int64, error) {
+	neg := strings.HasPrefix(s, "-")
+	intPart, fracPart := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, fracPart = s[:i], s[i+1:]
+	}
+	if intPart == "" || intPart == "-" || intPart == "+" {
+		intPart += "0"
+	}
+	whole, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(fracPart) > digits {
+		fracPart = fracPart[:digits]
+	}
+	var frac int64
+	if fracPart != "" {
+		if frac, err = strconv.ParseInt(fracPart+strings.Repeat("0", digits-len(fracPart)), 10, 64); err != nil || frac < 0 {
+			return 0, 0, fmt.Errorf("invalid fraction %q", s)
+		}
+	}
+	if neg {
+		frac = -frac
+	}
+	return whole, frac, nil
+}
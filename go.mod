@@ -3,15 +3,19 @@ module github.com/sraphs/encoding
 go 1.18
 
 require (
+	github.com/fxamacker/cbor/v2 v2.5.0
 	github.com/go-playground/form/v4 v4.2.0
 	github.com/joho/godotenv v1.4.0
 	github.com/mitchellh/mapstructure v1.5.0
+	github.com/pelletier/go-toml/v2 v2.0.1
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.0
 	github.com/sraphs/flat v1.0.0
 	github.com/sraphs/strcase v1.0.1
 	github.com/stretchr/testify v1.7.1
 	github.com/tidwall/gjson v1.14.1
+	github.com/vmihailenco/msgpack/v5 v5.3.5
 	google.golang.org/genproto v0.0.0-20220519153652-3a47de7e79bd
-	google.golang.org/protobuf v1.28.0
+	google.golang.org/protobuf v1.31.0
 	gopkg.in/yaml.v3 v3.0.0
 )
 
@@ -21,4 +25,6 @@ require (
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/tidwall/match v1.1.1 // indirect
 	github.com/tidwall/pretty v1.2.0 // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
 )
@@ -0,0 +1,578 @@
+// Package hooks provides the conversions between strings and Go types used
+// by the codecs that read flat string values, such as env and flag.
+//
+// A Registry holds the conversions. The Default registry knows []byte, taken
+// as is, time.Duration, time.Time, net.IP, url.URL, big.Int and regexp.Regexp,
+// and falls back to encoding.TextUnmarshaler and encoding.TextMarshaler for
+// the other types.
+package hooks
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// Hook converts the values of a type from and to strings. Either function may
+// be nil, for a type that is only decoded or only encoded.
+type Hook struct {
+	Decode func(s string) (interface{}, error)
+	Encode func(v interface{}) (string, error)
+}
+
+// Registry is a set of hooks keyed by type. A nil *Registry is the Default
+// registry.
+//
+// Registering is not safe for concurrent use with the codecs using the
+// registry; register the hooks before.
+type Registry struct {
+	hooks map[reflect.Type]Hook
+}
+
+// Default is the registry used by the codecs without their own.
+var Default *Registry
+
+func init() {
+	Default = New()
+}
+
+var (
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// New returns a registry with the built-in hooks.
+func New() *Registry {
+	r := &Registry{hooks: make(map[reflect.Type]Hook)}
+	r.Register([]byte(nil), Hook{
+		Decode: func(s string) (interface{}, error) { return []byte(s), nil },
+		Encode: func(v interface{}) (string, error) { return string(v.([]byte)), nil },
+	})
+	r.Register(time.Duration(0), Hook{
+		Decode: func(s string) (interface{}, error) { return time.ParseDuration(s) },
+		Encode: func(v interface{}) (string, error) { return v.(time.Duration).String(), nil },
+	})
+	r.Register(time.Time{}, Hook{
+		Decode: func(s string) (interface{}, error) { return time.Parse(time.RFC3339Nano, s) },
+		Encode: func(v interface{}) (string, error) { return v.(time.Time).Format(time.RFC3339Nano), nil },
+	})
+	r.Register(net.IP{}, Hook{
+		Decode: func(s string) (interface{}, error) {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", s)
+			}
+			return ip, nil
+		},
+		Encode: func(v interface{}) (string, error) { return v.(net.IP).String(), nil },
+	})
+	r.Register(url.URL{}, Hook{
+		Decode: func(s string) (interface{}, error) {
+			u, err := url.Parse(s)
+			if err != nil {
+				return nil, err
+			}
+			return *u, nil
+		},
+		Encode: func(v interface{}) (string, error) {
+			u := v.(url.URL)
+			return u.String(), nil
+		},
+	})
+	r.Register(big.Int{}, Hook{
+		Decode: func(s string) (interface{}, error) {
+			i, ok := new(big.Int).SetString(s, 0)
+			if !ok {
+				return nil, fmt.Errorf("invalid integer %q", s)
+			}
+			return *i, nil
+		},
+		Encode: func(v interface{}) (string, error) {
+			i := v.(big.Int)
+			return i.String(), nil
+		},
+	})
+	r.Register(regexp.Regexp{}, Hook{
+		Decode: func(s string) (interface{}, error) {
+			re, err := regexp.Compile(s)
+			if err != nil {
+				return nil, err
+			}
+			return *re, nil
+		},
+		Encode: func(v interface{}) (string, error) {
+			re := v.(regexp.Regexp)
+			return re.String(), nil
+		},
+	})
+	return r
+}
+
+// Clone returns a copy of the registry, to be extended without changing r.
+func (r *Registry) Clone() *Registry {
+	r = r.orDefault()
+	c := &Registry{hooks: make(map[reflect.Type]Hook, len(r.hooks))}
+	for t, h := range r.hooks {
+		c.hooks[t] = h
+	}
+	return c
+}
+
+// Register registers the hook for the type of typ, replacing any previous
+// one. Hooks registered for a type also apply to pointers to it.
+func (r *Registry) Register(typ interface{}, h Hook) {
+	r.orDefault().hooks[reflect.TypeOf(typ)] = h
+}
+
+// Lookup returns the hook for the type t, derived from its
+// encoding.TextUnmarshaler and encoding.TextMarshaler methods if it has
+// no registered one.
+func (r *Registry) Lookup(t reflect.Type) (Hook, bool) {
+	if h, ok := r.orDefault().hooks[t]; ok {
+		return h, true
+	}
+	var h Hook
+	if reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		h.Decode = func(s string) (interface{}, error) {
+			p := reflect.New(t)
+			if err := p.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+				return nil, err
+			}
+			return p.Elem().Interface(), nil
+		}
+	}
+	if t.Implements(textMarshalerType) {
+		h.Encode = func(v interface{}) (string, error) {
+			b, err := v.(encoding.TextMarshaler).MarshalText()
+			return string(b), err
+		}
+	} else if reflect.PtrTo(t).Implements(textMarshalerType) {
+		h.Encode = func(v interface{}) (string, error) {
+			p := reflect.New(t)
+			p.Elem().Set(reflect.ValueOf(v))
+			b, err := p.Interface().(encoding.TextMarshaler).MarshalText()
+			return string(b), err
+		}
+	}
+	return h, h.Decode != nil || h.Encode != nil
+}
+
+// Decodes reports whether strings can be decoded into the type t or a
+// pointer to it.
+func (r *Registry) Decodes(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	h, ok := r.Lookup(t)
+	return ok && h.Decode != nil
+}
+
+// DecodeHook returns a mapstructure decode hook converting strings to the
+// types of the registry.
+func (r *Registry) DecodeHook() mapstructure.DecodeHookFuncType {
+	return func(from, to reflect.Type, data interface{}) (interface{}, error) {
+		if from.Kind() != reflect.String {
+			return data, nil
+		}
+		if to.Kind() == reflect.Ptr {
+			to = to.Elem()
+		}
+		h, ok := r.Lookup(to)
+		if !ok || h.Decode == nil {
+			return data, nil
+		}
+		return h.Decode(reflect.ValueOf(data).String())
+	}
+}
+
+// EncodeValue returns the string form of v and true if v is of a type of
+// the registry or a non-nil pointer to one.
+func (r *Registry) EncodeValue(v reflect.Value) (string, bool, error) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", false, nil
+		}
+		v = v.Elem()
+	}
+	h, ok := r.Lookup(v.Type())
+	if !ok || h.Encode == nil {
+		return "", false, nil
+	}
+	s, err := h.Encode(v.Interface())
+	return s, true, err
+}
+
+// ToMap converts the struct or string keyed map v to nested maps keyed by
+// the field names, or the names given in the tagName tags, the way
+// mapstructure does. Values of the types of the registry are converted to
+// strings and nil pointers are left out.
+func (r *Registry) ToMap(v interface{}, tagName string) (map[string]interface{}, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return map[string]interface{}{}, nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() == reflect.Map && rv.Type().Key().Kind() == reflect.String {
+		m, _, err := r.toValue(rv, tagName)
+		if err != nil {
+			return nil, err
+		}
+		return m.(map[string]interface{}), nil
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("hooks: cannot convert %s to a map", rv.Type())
+	}
+	m := make(map[string]interface{})
+	if err := r.structToMap(rv, tagName, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (r *Registry) structToMap(rv reflect.Value, tagName string, m map[string]interface{}) error {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		name, opts := sf.Name, ""
+		if tag := sf.Tag.Get(tagName); tag != "" {
+			if i := strings.IndexByte(tag, ','); i >= 0 {
+				tag, opts = tag[:i], tag[i+1:]
+			}
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+		fv := rv.Field(i)
+		if strings.Contains(opts, "omitempty") && fv.IsZero() {
+			continue
+		}
+		if strings.Contains(opts, "squash") {
+			fv = reflect.Indirect(fv)
+			if fv.Kind() != reflect.Struct {
+				return fmt.Errorf("hooks: cannot squash non-struct type %s", sf.Type)
+			}
+			if err := r.structToMap(fv, tagName, m); err != nil {
+				return err
+			}
+			continue
+		}
+		value, ok, err := r.toValue(fv, tagName)
+		if err != nil {
+			return fmt.Errorf("hooks: field %s: %w", sf.Name, err)
+		}
+		if ok {
+			m[name] = value
+		}
+	}
+	return nil
+}
+
+// toValue converts v for ToMap, reporting false for the values to leave out.
+func (r *Registry) toValue(v reflect.Value, tagName string) (interface{}, bool, error) {
+	if s, ok, err := r.EncodeValue(v); ok || err != nil {
+		return s, ok, err
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil, false, nil
+		}
+		return r.toValue(v.Elem(), tagName)
+	case reflect.Struct:
+		m := make(map[string]interface{})
+		if err := r.structToMap(v, tagName, m); err != nil {
+			return nil, false, err
+		}
+		return m, true, nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return v.Interface(), true, nil
+		}
+		m := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			value, ok, err := r.toValue(iter.Value(), tagName)
+			if err != nil {
+				return nil, false, err
+			}
+			if ok {
+				m[iter.Key().String()] = value
+			}
+		}
+		return m, true, nil
+	case reflect.Slice, reflect.Array:
+		et := v.Type().Elem()
+		if et.Kind() == reflect.Ptr {
+			et = et.Elem()
+		}
+		if h, ok := r.Lookup(et); ok && h.Encode != nil && et.Kind() != reflect.Uint8 {
+			values := make([]string, 0, v.Len())
+			for i := 0; i < v.Len(); i++ {
+				s, ok, err := r.EncodeValue(v.Index(i))
+				if err != nil {
+					return nil, false, err
+				}
+				if ok {
+					values = append(values, s)
+				}
+			}
+			return values, true, nil
+		}
+	}
+	return v.Interface(), true, nil
+}
+
+// ToJSON returns the encoding/json form of v, except for the values of the
+// registered types, written as JSON strings by their hooks.
+//
+// encoding/json writes a copy of v whose types have the registered types
+// replaced by a json.Marshaler calling their hooks, and which keeps the names,
+// tags and order of the fields. The types implementing json.Marshaler or
+// encoding.TextMarshaler are written as is, and so are the recursive
+// references of a type: hooks are not applied within them.
+func (r *Registry) ToJSON(v interface{}) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return json.Marshal(v)
+	}
+	m := jsonMirror{r: r.orDefault(), fields: make(map[reflect.Type][]int), busy: make(map[reflect.Type]bool)}
+	c, err := m.copy(rv, m.typ(rv.Type()))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(c.Interface())
+}
+
+// jsonString is the json.Marshaler replacing the values of the registered
+// types for ToJSON.
+type jsonString string
+
+func (s jsonString) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(s))
+}
+
+// jsonAny replaces the interface values for ToJSON, holding their copy: the
+// hooks apply to their dynamic types. A nil *jsonAny is a nil interface.
+type jsonAny struct {
+	value interface{}
+}
+
+func (a *jsonAny) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.value)
+}
+
+var (
+	jsonStringType       = reflect.TypeOf(jsonString(""))
+	jsonAnyType          = reflect.TypeOf((*jsonAny)(nil))
+	jsonMarshalerType    = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	errUnsupportedMirror = errors.New("unsupported value")
+)
+
+// jsonMirror makes the types and the values encoding/json writes for ToJSON.
+type jsonMirror struct {
+	r *Registry
+	// fields holds the indexes of the fields of the structs that their
+	// mirrors keep, by struct type.
+	fields map[reflect.Type][]int
+	busy   map[reflect.Type]bool
+}
+
+// encodes reports whether the values of t are written by a registered hook.
+func (m jsonMirror) encodes(t reflect.Type) bool {
+	h, ok := m.r.hooks[t]
+	return ok && h.Encode != nil
+}
+
+// typ returns the type whose values encoding/json writes for the values of
+// t, t itself if hooks do not apply within it.
+func (m jsonMirror) typ(t reflect.Type) reflect.Type {
+	switch {
+	case m.encodes(t):
+		return jsonStringType
+	case implements(t, jsonMarshalerType) || implements(t, textMarshalerType) || m.busy[t]:
+		return t
+	case t.Kind() == reflect.Interface:
+		return jsonAnyType
+	}
+	m.busy[t] = true
+	defer delete(m.busy, t)
+	switch t.Kind() {
+	case reflect.Ptr:
+		if e := m.typ(t.Elem()); e != t.Elem() {
+			return reflect.PtrTo(e)
+		}
+	case reflect.Slice:
+		if e := m.typ(t.Elem()); e != t.Elem() {
+			return reflect.SliceOf(e)
+		}
+	case reflect.Array:
+		if e := m.typ(t.Elem()); e != t.Elem() {
+			return reflect.ArrayOf(t.Len(), e)
+		}
+	case reflect.Map:
+		if e := m.typ(t.Elem()); e != t.Elem() {
+			return reflect.MapOf(t.Key(), e)
+		}
+	case reflect.Struct:
+		return m.structType(t, false)
+	}
+	return t
+}
+
+// structType returns the mirror of the struct t, t itself if hooks do not
+// apply to its fields and not force. The unexported fields, which
+// encoding/json ignores, are left out, but for the embedded structs, which
+// are renamed and mirrored: their values cannot be set as is.
+func (m jsonMirror) structType(t reflect.Type, force bool) reflect.Type {
+	var fields []reflect.StructField
+	var indexes []int
+	changed := force
+	names := make(map[string]bool, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		names[t.Field(i).Name] = true
+	}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		ft := m.typ(sf.Type)
+		if ft != sf.Type {
+			changed = true
+		}
+		et := ft
+		if et.Kind() == reflect.Ptr {
+			et = et.Elem()
+		}
+		if sf.Anonymous && et.Kind() != reflect.Struct {
+			// encoding/json names the other embedded types by their type.
+			sf.Anonymous = false
+		}
+		if sf.PkgPath != "" {
+			changed = true
+			if !sf.Anonymous || m.encodes(sf.Type) {
+				continue
+			}
+			if ft == sf.Type && et == ft {
+				ft = m.structType(ft, true)
+			} else if ft == sf.Type {
+				ft = reflect.PtrTo(m.structType(et, true))
+			}
+			// reflect.StructOf only takes exported fields.
+			sf.Name = "Embedded" + strconv.Itoa(i)
+			for names[sf.Name] {
+				sf.Name += "_"
+			}
+			sf.PkgPath = ""
+		}
+		fields = append(fields, reflect.StructField{Name: sf.Name, Type: ft, Tag: sf.Tag, Anonymous: sf.Anonymous})
+		indexes = append(indexes, i)
+	}
+	if !changed {
+		return t
+	}
+	m.fields[t] = indexes
+	return reflect.StructOf(fields)
+}
+
+// copy returns the value of type mt, made by typ, of the value v.
+func (m jsonMirror) copy(v reflect.Value, mt reflect.Type) (reflect.Value, error) {
+	if mt == v.Type() {
+		return v, nil
+	}
+	if mt == jsonAnyType {
+		if v.IsNil() {
+			return reflect.Zero(mt), nil
+		}
+		e, err := m.copy(v.Elem(), m.typ(v.Elem().Type()))
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(&jsonAny{e.Interface()}), nil
+	}
+	if mt == jsonStringType {
+		s, ok, err := m.r.EncodeValue(v)
+		if !ok && err == nil {
+			err = errUnsupportedMirror
+		}
+		return reflect.ValueOf(jsonString(s)), err
+	}
+	c := reflect.New(mt).Elem()
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return c, nil
+		}
+		e, err := m.copy(v.Elem(), mt.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		c.Set(reflect.New(mt.Elem()))
+		c.Elem().Set(e)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice {
+			if v.IsNil() {
+				return c, nil
+			}
+			c.Set(reflect.MakeSlice(mt, v.Len(), v.Len()))
+		}
+		for i := 0; i < v.Len(); i++ {
+			e, err := m.copy(v.Index(i), mt.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			c.Index(i).Set(e)
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			return c, nil
+		}
+		c.Set(reflect.MakeMapWithSize(mt, v.Len()))
+		iter := v.MapRange()
+		for iter.Next() {
+			e, err := m.copy(iter.Value(), mt.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			c.SetMapIndex(iter.Key(), e)
+		}
+	case reflect.Struct:
+		for j, i := range m.fields[v.Type()] {
+			e, err := m.copy(v.Field(i), mt.Field(j).Type)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("hooks: field %s: %w", v.Type().Field(i).Name, err)
+			}
+			c.Field(j).Set(e)
+		}
+	default:
+		return reflect.Value{}, errUnsupportedMirror
+	}
+	return c, nil
+}
+
+func implements(t, iface reflect.Type) bool {
+	return t.Implements(iface) || reflect.PtrTo(t).Implements(iface)
+}
+
+func (r *Registry) orDefault() *Registry {
+	if r == nil {
+		return Default
+	}
+	return r
+}
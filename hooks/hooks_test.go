@@ -0,0 +1,244 @@
+package hooks
+
+import (
+	"encoding/json"
+	"math/big"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type level int
+
+func (l *level) UnmarshalText(b []byte) error {
+	switch strings.ToLower(string(b)) {
+	case "debug":
+		*l = 0
+	case "info":
+		*l = 1
+	default:
+		return assert.AnError
+	}
+	return nil
+}
+
+func (l level) MarshalText() ([]byte, error) {
+	return []byte([]string{"debug", "info"}[l]), nil
+}
+
+type config struct {
+	Timeout  time.Duration
+	Since    time.Time
+	Addr     net.IP
+	Endpoint *url.URL
+	Big      big.Int
+	Pattern  *regexp.Regexp
+	Level    level
+	Levels   []level
+	Skip     string `mapstructure:"-"`
+	Empty    string `mapstructure:"empty,omitempty"`
+}
+
+func decode(t *testing.T, r *Registry, in map[string]interface{}, out interface{}) error {
+	t.Helper()
+	d, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Result:           out,
+		WeaklyTypedInput: true,
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			r.DecodeHook(),
+			mapstructure.StringToSliceHookFunc(","),
+		),
+	})
+	require.NoError(t, err)
+	return d.Decode(in)
+}
+
+func TestDecodeHook(t *testing.T) {
+	var c config
+	require.NoError(t, decode(t, nil, map[string]interface{}{
+		"timeout":  "1m30s",
+		"since":    "2022-05-01T10:00:00Z",
+		"addr":     "10.0.0.1",
+		"endpoint": "https://sraph.com/api?x=1",
+		"big":      "123456789012345678901234567890",
+		"pattern":  "^a+$",
+		"level":    "INFO",
+		"levels":   "debug,info",
+	}, &c))
+
+	assert.Equal(t, 90*time.Second, c.Timeout)
+	assert.Equal(t, time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC), c.Since)
+	assert.Equal(t, "10.0.0.1", c.Addr.String())
+	assert.Equal(t, "sraph.com", c.Endpoint.Host)
+	assert.Equal(t, "123456789012345678901234567890", c.Big.String())
+	assert.True(t, c.Pattern.MatchString("aaa"))
+	assert.Equal(t, level(1), c.Level)
+	assert.Equal(t, []level{0, 1}, c.Levels)
+
+	assert.Error(t, decode(t, nil, map[string]interface{}{"addr": "nope"}, &c))
+	assert.Error(t, decode(t, nil, map[string]interface{}{"level": "trace"}, &c))
+}
+
+func TestToMap(t *testing.T) {
+	u, _ := url.Parse("https://sraph.com/")
+	c := config{
+		Timeout:  time.Second,
+		Since:    time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC),
+		Addr:     net.ParseIP("10.0.0.1"),
+		Endpoint: u,
+		Pattern:  regexp.MustCompile("^a+$"),
+		Level:    1,
+		Levels:   []level{0, 1},
+		Skip:     "skip",
+	}
+	c.Big.SetInt64(42)
+
+	m, err := Default.ToMap(&c, "mapstructure")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"Timeout":  "1s",
+		"Since":    "2022-05-01T10:00:00Z",
+		"Addr":     "10.0.0.1",
+		"Endpoint": "https://sraph.com/",
+		"Big":      "42",
+		"Pattern":  "^a+$",
+		"Level":    "info",
+		"Levels":   []string{"debug", "info"},
+	}, m)
+
+	_, err = Default.ToMap(42, "mapstructure")
+	assert.Error(t, err)
+}
+
+type point struct{ X, Y int }
+
+func (p point) MarshalJSON() ([]byte, error) {
+	return []byte(`[` + strconv.Itoa(p.X) + `,` + strconv.Itoa(p.Y) + `]`), nil
+}
+
+type base struct {
+	Name string `json:"name"`
+}
+
+func TestToJSON(t *testing.T) {
+	type doc struct {
+		base
+		Timeout time.Duration `json:"timeout"`
+		At      point         `json:"at"`
+		Since   time.Time     `json:"since"`
+		Next    *point        `json:"next"`
+		Note    string        `json:"note,omitempty"`
+		Skip    string        `json:"-"`
+		Sizes   []int         `json:"sizes"`
+	}
+	in := &doc{
+		base:    base{Name: "sraph"},
+		Timeout: time.Second,
+		At:      point{1, 2},
+		Since:   time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC),
+		Skip:    "skip",
+		Sizes:   []int{1, 2},
+	}
+
+	b, err := Default.ToJSON(in)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name":"sraph","timeout":"1s","at":[1,2],"since":"2022-05-01T10:00:00Z","next":null,"sizes":[1,2]}`, string(b))
+}
+
+type named struct {
+	Name string
+}
+
+type limits struct {
+	Name    string
+	Timeout time.Duration `json:"timeout"`
+}
+
+type port struct {
+	Port int `json:"port,string"`
+}
+
+func TestToJSONEncodingJSON(t *testing.T) {
+	// encoding/json rules: the conflicting names of the embedded structs are
+	// left out, and the string option and the order of the fields are kept.
+	type doc struct {
+		named
+		*limits
+		port
+		Count int64                  `json:"count,string"`
+		Extra map[string]interface{} `json:"extra"`
+		Any   []interface{}          `json:"any"`
+		hide  time.Duration
+	}
+	in := doc{
+		named:  named{Name: "a"},
+		limits: &limits{Name: "b", Timeout: time.Second},
+		port:   port{Port: 80},
+		Count:  3,
+		Extra:  map[string]interface{}{"d": time.Minute},
+		Any:    []interface{}{time.Millisecond, 1},
+		hide:   time.Hour,
+	}
+	b, err := Default.ToJSON(in)
+	require.NoError(t, err)
+	assert.Equal(t, `{"timeout":"1s","port":"80","count":"3","extra":{"d":"1m0s"},"any":["1ms",1]}`, string(b))
+
+	// without registered types, the output is the one of encoding/json.
+	type plain struct {
+		named
+		port
+		Tags []string `json:"tags,omitempty"`
+	}
+	want, err := json.Marshal(plain{named{"a"}, port{1}, nil})
+	require.NoError(t, err)
+	b, err = Default.ToJSON(plain{named{"a"}, port{1}, nil})
+	require.NoError(t, err)
+	assert.Equal(t, string(want), string(b))
+
+	// the recursive references are written by encoding/json.
+	type node struct {
+		Timeout time.Duration
+		Next    *node
+	}
+	b, err = Default.ToJSON(&node{Timeout: time.Second, Next: &node{Timeout: time.Second}})
+	require.NoError(t, err)
+	assert.Equal(t, `{"Timeout":"1s","Next":{"Timeout":1000000000,"Next":null}}`, string(b))
+}
+
+type celsius float64
+
+func TestRegistryClone(t *testing.T) {
+	r := Default.Clone()
+	r.Register(celsius(0), Hook{
+		Decode: func(s string) (interface{}, error) {
+			f, err := strconv.ParseFloat(strings.TrimSuffix(s, "C"), 64)
+			return celsius(f), err
+		},
+		Encode: func(v interface{}) (string, error) {
+			return strconv.FormatFloat(float64(v.(celsius)), 'f', -1, 64) + "C", nil
+		},
+	})
+
+	ct := reflect.TypeOf(celsius(0))
+	assert.True(t, r.Decodes(ct))
+	assert.True(t, r.Decodes(reflect.PtrTo(ct)))
+	assert.False(t, Default.Decodes(ct))
+
+	var out struct{ Temp celsius }
+	require.NoError(t, decode(t, r, map[string]interface{}{"temp": "21.5C"}, &out))
+	assert.Equal(t, celsius(21.5), out.Temp)
+
+	s, ok, err := r.EncodeValue(reflect.ValueOf(out.Temp))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "21.5C", s)
+}
@@ -0,0 +1,196 @@
+package httprule
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/sraphs/encoding/form"
+)
+
+// ErrNotMatch is returned when the request path does not match the template.
+var ErrNotMatch = errors.New("httprule: path does not match template")
+
+// Unmarshaler decodes a request body, as the Codec implementations do.
+type Unmarshaler interface {
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// Binder populates proto messages from HTTP requests according to a
+// google.api.HttpRule style template and body selector.
+type Binder struct {
+	md       protoreflect.MessageDescriptor
+	template *Template
+	body     string
+	codec    Unmarshaler
+}
+
+// NewBinder returns a Binder of the messages of md for the path template and
+// body selector. The body is "*" to bind the request body to the whole
+// message, a field path to bind it to that field, or empty when the request
+// has no body. The codec decodes the body and may be nil when body is empty.
+// The path variables and the body field are resolved against md, so that a
+// template naming a missing field fails here rather than in Bind.
+func NewBinder(md protoreflect.MessageDescriptor, template, body string, codec Unmarshaler) (*Binder, error) {
+	t, err := Parse(template)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range t.variables() {
+		if _, err := lookupField(md, name); err != nil {
+			return nil, fmt.Errorf("httprule: template %q: %w", template, err)
+		}
+	}
+	if body != "" && codec == nil {
+		return nil, fmt.Errorf("httprule: body %q needs a codec", body)
+	}
+	if body != "" && body != "*" {
+		fd, err := lookupField(md, body)
+		if err != nil {
+			return nil, err
+		}
+		if fd.Message() == nil || fd.IsList() || fd.IsMap() {
+			return nil, fmt.Errorf("httprule: body field %q is not a message", body)
+		}
+	}
+	return &Binder{md: md, template: t, body: body, codec: codec}, nil
+}
+
+// Template returns the path template of the binder.
+func (b *Binder) Template() *Template {
+	return b.template
+}
+
+// Bind populates msg from the body, the query parameters and the path
+// variables of u, in that order so that path variables take precedence.
+//
+// Query parameters are ignored when the body binds the whole message, and
+// so are the ones naming a path variable or a field under the body field.
+func (b *Binder) Bind(msg proto.Message, u *url.URL, body []byte) error {
+	if name := msg.ProtoReflect().Descriptor().FullName(); name != b.md.FullName() {
+		return fmt.Errorf("httprule: binder of %s cannot bind %s", b.md.FullName(), name)
+	}
+	vars, ok := b.template.Match(u.EscapedPath())
+	if !ok {
+		return ErrNotMatch
+	}
+
+	if err := b.bindBody(msg, body); err != nil {
+		return err
+	}
+
+	if b.body != "*" {
+		query := u.Query()
+		for key := range query {
+			if b.isBound(key, vars) {
+				delete(query, key)
+			}
+		}
+		if err := form.DecodeValues(msg, query); err != nil {
+			return fmt.Errorf("httprule: query: %w", err)
+		}
+	}
+
+	for name, value := range vars {
+		fd, _ := lookupField(msg.ProtoReflect().Descriptor(), name)
+		// clear the field so the path wins over a value set by the body.
+		if !fd.IsList() && !fd.IsMap() {
+			clearField(msg.ProtoReflect(), name)
+		}
+		if err := form.DecodeValues(msg, url.Values{name: {value}}); err != nil {
+			return fmt.Errorf("httprule: path variable %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// BindRequest populates msg from r, reading its body.
+func (b *Binder) BindRequest(msg proto.Message, r *http.Request) error {
+	var body []byte
+	if b.body != "" && r.Body != nil {
+		var err error
+		if body, err = io.ReadAll(r.Body); err != nil {
+			return err
+		}
+	}
+	return b.Bind(msg, r.URL, body)
+}
+
+func (b *Binder) bindBody(msg proto.Message, body []byte) error {
+	if b.body == "" || len(body) == 0 {
+		return nil
+	}
+	if b.body == "*" {
+		if err := b.codec.Unmarshal(body, msg); err != nil {
+			return fmt.Errorf("httprule: body: %w", err)
+		}
+		return nil
+	}
+
+	// the body field was checked by NewBinder.
+	fd, _ := lookupField(msg.ProtoReflect().Descriptor(), b.body)
+	parent := mutableParent(msg.ProtoReflect(), b.body)
+	field := parent.NewField(fd)
+	if err := b.codec.Unmarshal(body, field.Message().Interface()); err != nil {
+		return fmt.Errorf("httprule: body: %w", err)
+	}
+	parent.Set(fd, field)
+	return nil
+}
+
+// isBound reports whether the query parameter key names a path variable or
+// a field under the body field.
+func (b *Binder) isBound(key string, vars map[string]string) bool {
+	if _, ok := vars[key]; ok {
+		return true
+	}
+	return b.body != "" && (key == b.body || strings.HasPrefix(key, b.body+"."))
+}
+
+// lookupField returns the descriptor of the field at the dotted path.
+func lookupField(md protoreflect.MessageDescriptor, path string) (protoreflect.FieldDescriptor, error) {
+	names := strings.Split(path, ".")
+	var fd protoreflect.FieldDescriptor
+	for i, name := range names {
+		if md == nil {
+			return nil, fmt.Errorf("httprule: %q is not a message", strings.Join(names[:i], "."))
+		}
+		if fd = md.Fields().ByName(protoreflect.Name(name)); fd == nil {
+			if fd = md.Fields().ByJSONName(name); fd == nil {
+				return nil, fmt.Errorf("httprule: field %q not found in %s", path, md.FullName())
+			}
+		}
+		md = fd.Message()
+	}
+	return fd, nil
+}
+
+// mutableParent returns the message holding the last field of the dotted
+// path, allocating the intermediate messages.
+func mutableParent(m protoreflect.Message, path string) protoreflect.Message {
+	names := strings.Split(path, ".")
+	for _, name := range names[:len(names)-1] {
+		fd, _ := lookupField(m.Descriptor(), name)
+		m = m.Mutable(fd).Message()
+	}
+	return m
+}
+
+func clearField(m protoreflect.Message, path string) {
+	names := strings.Split(path, ".")
+	for _, name := range names[:len(names)-1] {
+		fd, _ := lookupField(m.Descriptor(), name)
+		if !m.Has(fd) {
+			return
+		}
+		m = m.Mutable(fd).Message()
+	}
+	fd, _ := lookupField(m.Descriptor(), names[len(names)-1])
+	m.Clear(fd)
+}
@@ -0,0 +1,143 @@
+package httprule
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	testData "github.com/sraphs/encoding/internal/testdata/complex"
+	"github.com/sraphs/encoding/json"
+)
+
+func TestTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		path     string
+		vars     map[string]string
+	}{
+		{"/v1/books", "/v1/books", map[string]string{}},
+		{"/v1/books", "/v1/books/1", nil},
+		{"/v1/{id}", "/v1/42", map[string]string{"id": "42"}},
+		{"/v1/{id}", "/v1/", nil},
+		{"/v1/{name=projects/*/books/*}", "/v1/projects/p1/books/b%2F1", map[string]string{"name": "projects/p1/books/b/1"}},
+		{"/v1/{name=projects/*/books/*}", "/v1/projects/p1/shelves/b1", nil},
+		{"/v1/{name=projects/*}/books/{book.id}", "/v1/projects/p1/books/b1", map[string]string{"name": "projects/p1", "book.id": "b1"}},
+		{"/v1/{name=**}", "/v1/a/b/c", map[string]string{"name": "a/b/c"}},
+		{"/v1/files/**", "/v1/files/a/b", map[string]string{}},
+		{"/v1/{name=books/*}:publish", "/v1/books/1:publish", map[string]string{"name": "books/1"}},
+		{"/v1/{name=books/*}:publish", "/v1/books/1", nil},
+		{"/v1/a:b:c", "/v1/a:b:c", map[string]string{}},
+		{"/v1/a:b:c", "/v1/a:b", nil},
+	}
+	for _, tt := range tests {
+		tmpl, err := Parse(tt.template)
+		require.NoError(t, err, tt.template)
+		vars, ok := tmpl.Match(tt.path)
+		assert.Equal(t, tt.vars != nil, ok, "%s %s", tt.template, tt.path)
+		if tt.vars != nil {
+			assert.Equal(t, tt.vars, vars, "%s %s", tt.template, tt.path)
+		}
+	}
+
+	// the verb follows the last ':'.
+	tmpl, err := Parse("/v1/a:b:c")
+	require.NoError(t, err)
+	assert.Equal(t, "c", tmpl.verb)
+	assert.Equal(t, []segment{{kind: literalSegment, value: "v1"}, {kind: literalSegment, value: "a:b"}}, tmpl.segments)
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tmpl := range []string{
+		"v1/books",
+		"/v1/{id",
+		"/v1/{1id}",
+		"/v1//books",
+		"/v1/{name=**}/books",
+		"/v1/{id}/{id}",
+		"/v1/books:",
+		"/v1/bo*ks",
+		"/v1/books:a/b",
+	} {
+		_, err := Parse(tmpl)
+		assert.Error(t, err, tmpl)
+	}
+}
+
+var md = (&testData.Complex{}).ProtoReflect().Descriptor()
+
+func TestBinder_BodyStar(t *testing.T) {
+	b, err := NewBinder(md, "/v1/complex/{id}", "*", json.Codec{})
+	require.NoError(t, err)
+
+	u, _ := url.Parse("/v1/complex/7?age=18")
+	msg := &testData.Complex{}
+	require.NoError(t, b.Bind(msg, u, []byte(`{"id":"1","numberOne":"x","very_simple":{"component":"c"}}`)))
+	assert.Equal(t, int64(7), msg.Id)
+	assert.Equal(t, "x", msg.NoOne)
+	assert.Equal(t, "c", msg.Simple.Component)
+	// query parameters are not bound with body "*".
+	assert.Equal(t, int32(0), msg.Age)
+}
+
+func TestBinder_BodyField(t *testing.T) {
+	b, err := NewBinder(md, "/v1/{no_one=projects/*}/complex", "simple", json.Codec{})
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, "/v1/projects/p1/complex?age=18&simples=a&simples=b&no_one=ignored&simple.component=ignored",
+		strings.NewReader(`{"component":"body"}`))
+	require.NoError(t, err)
+
+	msg := &testData.Complex{}
+	require.NoError(t, b.BindRequest(msg, req))
+	assert.Equal(t, "projects/p1", msg.NoOne)
+	assert.Equal(t, "body", msg.Simple.Component)
+	assert.Equal(t, int32(18), msg.Age)
+	assert.Equal(t, []string{"a", "b"}, msg.Simples)
+}
+
+func TestBinder_NoBody(t *testing.T) {
+	b, err := NewBinder(md, "/v1/{simple.component}", "", nil)
+	require.NoError(t, err)
+
+	u, _ := url.Parse("/v1/c1?sex=woman&very_simple.component=query")
+	msg := &testData.Complex{}
+	require.NoError(t, b.Bind(msg, u, nil))
+	assert.Equal(t, "c1", msg.Simple.Component)
+	assert.Equal(t, testData.Sex_woman, msg.Sex)
+
+	u, _ = url.Parse("/v2/c1")
+	assert.Equal(t, ErrNotMatch, b.Bind(msg, u, nil))
+
+	_, err = NewBinder(md, "/v1/{id}", "*", nil)
+	assert.Error(t, err)
+}
+
+func TestBinder_BodyNotMessage(t *testing.T) {
+	_, err := NewBinder(md, "/v1/complex", "age", json.Codec{})
+	assert.Error(t, err)
+}
+
+func TestBinder_UnknownField(t *testing.T) {
+	for _, tt := range []struct{ template, body string }{
+		{"/v1/{missing}", ""},
+		{"/v1/{simple.missing}", ""},
+		{"/v1/{id.value}", ""},
+		{"/v1/complex", "missing"},
+	} {
+		_, err := NewBinder(md, tt.template, tt.body, json.Codec{})
+		assert.Error(t, err, tt.template)
+	}
+
+	b, err := NewBinder(md, "/v1/{component}", "", nil)
+	require.Error(t, err)
+	assert.Nil(t, b)
+
+	b, err = NewBinder((&testData.Simple{}).ProtoReflect().Descriptor(), "/v1/{component}", "", nil)
+	require.NoError(t, err)
+	u, _ := url.Parse("/v1/c")
+	assert.Error(t, b.Bind(&testData.Complex{}, u, nil), "a binder only binds the messages of its descriptor")
+}
@@ -0,0 +1,254 @@
+// Package httprule binds HTTP requests to proto messages following the
+// google.api.http annotation rules: path templates, query parameters and body.
+package httprule
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Template is a parsed path template such as "/v1/{name=projects/*/books/*}:publish".
+//
+// The grammar is the one of google.api.HttpRule:
+//
+//	Template = "/" Segments [ Verb ] ;
+//	Segments = Segment { "/" Segment } ;
+//	Segment  = "*" | "**" | LITERAL | Variable ;
+//	Variable = "{" FieldPath [ "=" Segments ] "}" ;
+//	FieldPath = IDENT { "." IDENT } ;
+//	Verb     = ":" LITERAL ;
+type Template struct {
+	raw      string
+	segments []segment
+	verb     string
+}
+
+type segmentKind int
+
+const (
+	literalSegment segmentKind = iota
+	wildcardSegment
+	deepWildcardSegment
+)
+
+type segment struct {
+	kind  segmentKind
+	value string
+	// variable is the field path the segment is captured into, if any.
+	variable string
+}
+
+// Parse parses a path template.
+func Parse(tmpl string) (*Template, error) {
+	if !strings.HasPrefix(tmpl, "/") {
+		return nil, fmt.Errorf("httprule: template %q must start with /", tmpl)
+	}
+	t := &Template{raw: tmpl}
+	p := tmpl[1:]
+
+	// the verb is the part after the last ':' outside of a variable.
+	depth, colon := 0, -1
+	for i := 0; i < len(p); i++ {
+		switch p[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ':':
+			if depth == 0 {
+				colon = i
+			}
+		}
+	}
+	if colon >= 0 {
+		t.verb, p = p[colon+1:], p[:colon]
+		if t.verb == "" {
+			return nil, fmt.Errorf("httprule: template %q has an empty verb", tmpl)
+		}
+		if strings.ContainsAny(t.verb, "/{}*=") {
+			return nil, fmt.Errorf("httprule: template %q has an invalid verb %q", tmpl, t.verb)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for len(p) > 0 {
+		var seg string
+		if p[0] == '{' {
+			end := strings.IndexByte(p, '}')
+			if end < 0 {
+				return nil, fmt.Errorf("httprule: template %q has an unterminated variable", tmpl)
+			}
+			seg, p = p[:end+1], p[end+1:]
+			segs, err := parseVariable(seg[1 : len(seg)-1])
+			if err != nil {
+				return nil, fmt.Errorf("httprule: template %q: %w", tmpl, err)
+			}
+			if seen[segs[0].variable] {
+				return nil, fmt.Errorf("httprule: template %q binds %q twice", tmpl, segs[0].variable)
+			}
+			seen[segs[0].variable] = true
+			t.segments = append(t.segments, segs...)
+		} else {
+			end := strings.IndexByte(p, '/')
+			if end < 0 {
+				end = len(p)
+			}
+			seg, p = p[:end], p[end:]
+			s, err := parseSegment(seg)
+			if err != nil {
+				return nil, fmt.Errorf("httprule: template %q: %w", tmpl, err)
+			}
+			t.segments = append(t.segments, s)
+		}
+		if len(p) > 0 {
+			if p[0] != '/' || len(p) == 1 {
+				return nil, fmt.Errorf("httprule: template %q has an invalid segment separator", tmpl)
+			}
+			p = p[1:]
+		}
+	}
+
+	for i, s := range t.segments {
+		if s.kind == deepWildcardSegment && i != len(t.segments)-1 {
+			return nil, fmt.Errorf("httprule: template %q: ** must be the last segment", tmpl)
+		}
+	}
+	return t, nil
+}
+
+func parseVariable(s string) ([]segment, error) {
+	name, pattern := s, "*"
+	if i := strings.IndexByte(s, '='); i >= 0 {
+		name, pattern = s[:i], s[i+1:]
+	}
+	for _, ident := range strings.Split(name, ".") {
+		if !isIdent(ident) {
+			return nil, fmt.Errorf("invalid field path %q", name)
+		}
+	}
+	var segs []segment
+	for _, p := range strings.Split(pattern, "/") {
+		seg, err := parseSegment(p)
+		if err != nil {
+			return nil, err
+		}
+		seg.variable = name
+		segs = append(segs, seg)
+	}
+	return segs, nil
+}
+
+func parseSegment(s string) (segment, error) {
+	switch s {
+	case "":
+		return segment{}, fmt.Errorf("empty segment")
+	case "*":
+		return segment{kind: wildcardSegment}, nil
+	case "**":
+		return segment{kind: deepWildcardSegment}, nil
+	}
+	if strings.ContainsAny(s, "{}*=") {
+		return segment{}, fmt.Errorf("invalid literal %q", s)
+	}
+	return segment{kind: literalSegment, value: s}, nil
+}
+
+func isIdent(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '_', 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case '0' <= c && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// variables returns the field paths of the variables of the template.
+func (t *Template) variables() []string {
+	var names []string
+	for i, s := range t.segments {
+		if s.variable != "" && (i == 0 || t.segments[i-1].variable != s.variable) {
+			names = append(names, s.variable)
+		}
+	}
+	return names
+}
+
+// Match matches the escaped path against the template and returns the
+// values of its variables keyed by field path.
+func (t *Template) Match(path string) (map[string]string, bool) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, false
+	}
+	path = path[1:]
+	if t.verb != "" {
+		if !strings.HasSuffix(path, ":"+t.verb) {
+			return nil, false
+		}
+		path = strings.TrimSuffix(path, ":"+t.verb)
+	}
+
+	parts := strings.Split(path, "/")
+	captured := make(map[string][]string)
+	i := 0
+	for _, s := range t.segments {
+		if s.kind == deepWildcardSegment {
+			if i > len(parts) {
+				return nil, false
+			}
+			if s.variable != "" {
+				captured[s.variable] = append(captured[s.variable], parts[i:]...)
+			}
+			i = len(parts)
+			continue
+		}
+		if i >= len(parts) || parts[i] == "" {
+			return nil, false
+		}
+		if s.kind == literalSegment && s.value != parts[i] {
+			return nil, false
+		}
+		if s.variable != "" {
+			captured[s.variable] = append(captured[s.variable], parts[i])
+		}
+		i++
+	}
+	if i != len(parts) {
+		return nil, false
+	}
+
+	vars := make(map[string]string, len(captured))
+	for name, values := range captured {
+		for j, v := range values {
+			u, err := url.PathUnescape(v)
+			if err != nil {
+				return nil, false
+			}
+			values[j] = u
+		}
+		vars[name] = strings.Join(values, "/")
+	}
+	return vars, true
+}
+
+// Variables returns the field paths bound by the template.
+func (t *Template) Variables() []string {
+	var names []string
+	for _, s := range t.segments {
+		if s.variable != "" && (len(names) == 0 || names[len(names)-1] != s.variable) {
+			names = append(names, s.variable)
+		}
+	}
+	return names
+}
+
+func (t *Template) String() string {
+	return t.raw
+}
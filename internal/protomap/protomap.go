@@ -0,0 +1,577 @@
+// Package protomap converts proto messages to and from generic Go values, the
+// shape used by self-describing binary formats such as MessagePack and CBOR.
+//
+// Messages become maps keyed by JSON name, proto name or field number, and
+// well-known types use their native or JSON string forms: Timestamp becomes a
+// time.Time, wrappers become their scalar, Struct/Value/ListValue become
+// generic maps and slices, and Duration and FieldMask become strings.
+package protomap
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Options configures the conversion.
+type Options struct {
+	// UseProtoNames keys message fields by their proto name instead of their JSON name.
+	UseProtoNames bool
+	// UseFieldNumbers keys message fields by their field number.
+	UseFieldNumbers bool
+	// UseEnumNumbers writes enum values as numbers instead of names.
+	UseEnumNumbers bool
+	// EmitUnpopulated writes fields that are not populated.
+	EmitUnpopulated bool
+}
+
+// Marshal returns the generic value of m. The result is a
+// map[string]interface{}, or a map[int]interface{} with UseFieldNumbers.
+func (o Options) Marshal(m protoreflect.Message) (interface{}, error) {
+	return o.marshalMessage(m)
+}
+
+func (o Options) marshalMessage(m protoreflect.Message) (interface{}, error) {
+	if v, ok, err := o.marshalWellKnown(m); ok {
+		return v, err
+	}
+
+	var names map[string]interface{}
+	var numbers map[int]interface{}
+	if o.UseFieldNumbers {
+		numbers = make(map[int]interface{})
+	} else {
+		names = make(map[string]interface{})
+	}
+
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if !m.Has(fd) {
+			if !o.EmitUnpopulated || fd.ContainingOneof() != nil || fd.HasPresence() && fd.Message() != nil {
+				continue
+			}
+		}
+		v, err := o.marshalField(fd, m.Get(fd))
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %w", fd.Name(), err)
+		}
+		switch {
+		case o.UseFieldNumbers:
+			numbers[int(fd.Number())] = v
+		case o.UseProtoNames:
+			names[string(fd.Name())] = v
+		default:
+			names[fd.JSONName()] = v
+		}
+	}
+	if o.UseFieldNumbers {
+		return numbers, nil
+	}
+	return names, nil
+}
+
+func (o Options) marshalField(fd protoreflect.FieldDescriptor, v protoreflect.Value) (interface{}, error) {
+	switch {
+	case fd.IsList():
+		list := v.List()
+		out := make([]interface{}, list.Len())
+		for i := range out {
+			e, err := o.marshalSingular(fd, list.Get(i))
+			if err != nil {
+				return nil, err
+			}
+			out[i] = e
+		}
+		return out, nil
+	case fd.IsMap():
+		out := make(map[interface{}]interface{})
+		var err error
+		v.Map().Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+			var e interface{}
+			if e, err = o.marshalSingular(fd.MapValue(), v); err != nil {
+				return false
+			}
+			out[k.Interface()] = e
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+		if fd.MapKey().Kind() == protoreflect.StringKind {
+			return stringKeys(out), nil
+		}
+		return out, nil
+	}
+	return o.marshalSingular(fd, v)
+}
+
+func (o Options) marshalSingular(fd protoreflect.FieldDescriptor, v protoreflect.Value) (interface{}, error) {
+	switch fd.Kind() {
+	case protoreflect.EnumKind:
+		if fd.Enum().FullName() == "google.protobuf.NullValue" {
+			return nil, nil
+		}
+		if !o.UseEnumNumbers {
+			if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+				return string(ev.Name()), nil
+			}
+		}
+		return int32(v.Enum()), nil
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return o.marshalMessage(v.Message())
+	default:
+		return v.Interface(), nil
+	}
+}
+
+func (o Options) marshalWellKnown(m protoreflect.Message) (interface{}, bool, error) {
+	md := m.Descriptor()
+	switch md.FullName() {
+	case "google.protobuf.Timestamp":
+		secs := m.Get(md.Fields().ByNumber(1)).Int()
+		nanos := m.Get(md.Fields().ByNumber(2)).Int()
+		return time.Unix(secs, nanos).UTC(), true, nil
+	case "google.protobuf.Duration", "google.protobuf.FieldMask":
+		b, err := protojson.Marshal(m.Interface())
+		if err != nil {
+			return nil, true, err
+		}
+		s, err := strconv.Unquote(string(b))
+		return s, true, err
+	case "google.protobuf.DoubleValue", "google.protobuf.FloatValue", "google.protobuf.Int64Value",
+		"google.protobuf.Int32Value", "google.protobuf.UInt64Value", "google.protobuf.UInt32Value",
+		"google.protobuf.BoolValue", "google.protobuf.StringValue", "google.protobuf.BytesValue":
+		return m.Get(md.Fields().ByNumber(1)).Interface(), true, nil
+	case "google.protobuf.Struct", "google.protobuf.Value", "google.protobuf.ListValue":
+		b, err := protojson.Marshal(m.Interface())
+		if err != nil {
+			return nil, true, err
+		}
+		v, err := decodeJSON(b)
+		return v, true, err
+	}
+	return nil, false, nil
+}
+
+// Unmarshal populates m from the generic value v.
+func (o Options) Unmarshal(v interface{}, m protoreflect.Message) error {
+	return o.unmarshalMessage(v, m)
+}
+
+func (o Options) unmarshalMessage(v interface{}, m protoreflect.Message) error {
+	if ok, err := o.unmarshalWellKnown(v, m); ok {
+		return err
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Map {
+		return fmt.Errorf("%s: expected map, got %T", m.Descriptor().FullName(), v)
+	}
+	fields := m.Descriptor().Fields()
+	iter := rv.MapRange()
+	for iter.Next() {
+		fd := lookupField(fields, iter.Key().Interface())
+		if fd == nil {
+			// ignore unknown field.
+			continue
+		}
+		val := iter.Value().Interface()
+		if val == nil && !(fd.Message() != nil && fd.Message().FullName() == "google.protobuf.Value") {
+			continue
+		}
+		if err := o.unmarshalField(val, fd, m); err != nil {
+			return fmt.Errorf("field %q: %w", fd.Name(), err)
+		}
+	}
+	return nil
+}
+
+func lookupField(fields protoreflect.FieldDescriptors, key interface{}) protoreflect.FieldDescriptor {
+	if s, ok := key.(string); ok {
+		if fd := fields.ByJSONName(s); fd != nil {
+			return fd
+		}
+		if fd := fields.ByName(protoreflect.Name(s)); fd != nil {
+			return fd
+		}
+		if n, err := strconv.ParseInt(s, 10, 32); err == nil {
+			return fields.ByNumber(protoreflect.FieldNumber(n))
+		}
+		return nil
+	}
+	if n, ok := toInt64(key); ok && n > 0 && n <= math.MaxInt32 {
+		return fields.ByNumber(protoreflect.FieldNumber(n))
+	}
+	return nil
+}
+
+func (o Options) unmarshalField(v interface{}, fd protoreflect.FieldDescriptor, m protoreflect.Message) error {
+	switch {
+	case fd.IsList():
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			return fmt.Errorf("expected list, got %T", v)
+		}
+		list := m.Mutable(fd).List()
+		for i := 0; i < rv.Len(); i++ {
+			e, err := o.unmarshalSingular(rv.Index(i).Interface(), fd, list.NewElement)
+			if err != nil {
+				return err
+			}
+			list.Append(e)
+		}
+		return nil
+	case fd.IsMap():
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Map {
+			return fmt.Errorf("expected map, got %T", v)
+		}
+		mp := m.Mutable(fd).Map()
+		iter := rv.MapRange()
+		for iter.Next() {
+			k, err := o.unmarshalSingular(iter.Key().Interface(), fd.MapKey(), nil)
+			if err != nil {
+				return err
+			}
+			e, err := o.unmarshalSingular(iter.Value().Interface(), fd.MapValue(), mp.NewValue)
+			if err != nil {
+				return err
+			}
+			mp.Set(k.MapKey(), e)
+		}
+		return nil
+	}
+	val, err := o.unmarshalSingular(v, fd, func() protoreflect.Value { return m.NewField(fd) })
+	if err != nil {
+		return err
+	}
+	m.Set(fd, val)
+	return nil
+}
+
+func (o Options) unmarshalSingular(v interface{}, fd protoreflect.FieldDescriptor, newValue func() protoreflect.Value) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		val := newValue()
+		if err := o.unmarshalMessage(v, val.Message()); err != nil {
+			return protoreflect.Value{}, err
+		}
+		return val, nil
+	case protoreflect.EnumKind:
+		if s, ok := v.(string); ok {
+			ev := fd.Enum().Values().ByName(protoreflect.Name(s))
+			if ev == nil {
+				return protoreflect.Value{}, fmt.Errorf("%q is not a valid value", s)
+			}
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+		if v == nil && fd.Enum().FullName() == "google.protobuf.NullValue" {
+			return protoreflect.ValueOfEnum(0), nil
+		}
+		n, ok := toInt64(v)
+		if !ok || n < math.MinInt32 || n > math.MaxInt32 {
+			return protoreflect.Value{}, fmt.Errorf("invalid enum value %v", v)
+		}
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(n)), nil
+	}
+	return scalar(v, fd.Kind())
+}
+
+func scalar(v interface{}, kind protoreflect.Kind) (protoreflect.Value, error) {
+	if s, ok := v.(string); ok && kind != protoreflect.StringKind && kind != protoreflect.BytesKind {
+		// numbers and booleans may be given in their text form, as map keys
+		// always are in some formats.
+		return parseScalar(s, kind)
+	}
+	switch kind {
+	case protoreflect.BoolKind:
+		if b, ok := v.(bool); ok {
+			return protoreflect.ValueOfBool(b), nil
+		}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		if n, ok := toInt64(v); ok && n >= math.MinInt32 && n <= math.MaxInt32 {
+			return protoreflect.ValueOfInt32(int32(n)), nil
+		}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		if n, ok := toInt64(v); ok {
+			return protoreflect.ValueOfInt64(n), nil
+		}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		if n, ok := toUint64(v); ok && n <= math.MaxUint32 {
+			return protoreflect.ValueOfUint32(uint32(n)), nil
+		}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		if n, ok := toUint64(v); ok {
+			return protoreflect.ValueOfUint64(n), nil
+		}
+	case protoreflect.FloatKind:
+		if f, ok := toFloat64(v); ok {
+			return protoreflect.ValueOfFloat32(float32(f)), nil
+		}
+	case protoreflect.DoubleKind:
+		if f, ok := toFloat64(v); ok {
+			return protoreflect.ValueOfFloat64(f), nil
+		}
+	case protoreflect.StringKind:
+		switch s := v.(type) {
+		case string:
+			return protoreflect.ValueOfString(s), nil
+		case []byte:
+			return protoreflect.ValueOfString(string(s)), nil
+		}
+	case protoreflect.BytesKind:
+		switch b := v.(type) {
+		case []byte:
+			return protoreflect.ValueOfBytes(b), nil
+		case string:
+			d, err := base64.StdEncoding.DecodeString(b)
+			if err != nil {
+				return protoreflect.Value{}, err
+			}
+			return protoreflect.ValueOfBytes(d), nil
+		}
+	}
+	return protoreflect.Value{}, fmt.Errorf("cannot use %T as %v", v, kind)
+}
+
+func parseScalar(s string, kind protoreflect.Kind) (protoreflect.Value, error) {
+	switch kind {
+	case protoreflect.BoolKind:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfBool(b), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		n, err := strconv.ParseInt(s, 10, 32)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfInt32(int32(n)), nil
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfInt64(n), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		n, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfUint32(uint32(n)), nil
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfUint64(n), nil
+	case protoreflect.FloatKind:
+		f, err := strconv.ParseFloat(s, 32)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfFloat32(float32(f)), nil
+	case protoreflect.DoubleKind:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfFloat64(f), nil
+	}
+	return protoreflect.Value{}, fmt.Errorf("cannot use string as %v", kind)
+}
+
+func (o Options) unmarshalWellKnown(v interface{}, m protoreflect.Message) (bool, error) {
+	md := m.Descriptor()
+	switch md.FullName() {
+	case "google.protobuf.Timestamp":
+		var t time.Time
+		switch x := v.(type) {
+		case time.Time:
+			t = x
+		case string:
+			var err error
+			if t, err = time.Parse(time.RFC3339Nano, x); err != nil {
+				return true, err
+			}
+		default:
+			if f, ok := toFloat64(v); ok {
+				sec, frac := math.Modf(f)
+				t = time.Unix(int64(sec), int64(frac*1e9))
+				break
+			}
+			return true, fmt.Errorf("cannot use %T as %s", v, md.FullName())
+		}
+		m.Set(md.Fields().ByNumber(1), protoreflect.ValueOfInt64(t.Unix()))
+		m.Set(md.Fields().ByNumber(2), protoreflect.ValueOfInt32(int32(t.Nanosecond())))
+		return true, nil
+	case "google.protobuf.Duration":
+		switch x := v.(type) {
+		case string:
+			if isJSONDuration(x) {
+				return true, unmarshalJSONString(x, m)
+			}
+			d, err := time.ParseDuration(x)
+			if err != nil {
+				return true, err
+			}
+			v = d
+		case time.Duration:
+		default:
+			n, ok := toInt64(v)
+			if !ok {
+				return true, fmt.Errorf("cannot use %T as %s", v, md.FullName())
+			}
+			v = time.Duration(n)
+		}
+		d := v.(time.Duration)
+		m.Set(md.Fields().ByNumber(1), protoreflect.ValueOfInt64(int64(d/time.Second)))
+		m.Set(md.Fields().ByNumber(2), protoreflect.ValueOfInt32(int32(d%time.Second)))
+		return true, nil
+	case "google.protobuf.FieldMask":
+		s, ok := v.(string)
+		if !ok {
+			return true, fmt.Errorf("cannot use %T as %s", v, md.FullName())
+		}
+		return true, unmarshalJSONString(s, m)
+	case "google.protobuf.DoubleValue", "google.protobuf.FloatValue", "google.protobuf.Int64Value",
+		"google.protobuf.Int32Value", "google.protobuf.UInt64Value", "google.protobuf.UInt32Value",
+		"google.protobuf.BoolValue", "google.protobuf.StringValue", "google.protobuf.BytesValue":
+		fd := md.Fields().ByNumber(1)
+		val, err := scalar(v, fd.Kind())
+		if err != nil {
+			return true, err
+		}
+		m.Set(fd, val)
+		return true, nil
+	case "google.protobuf.Struct", "google.protobuf.Value", "google.protobuf.ListValue":
+		b, err := encodeJSON(v)
+		if err != nil {
+			return true, err
+		}
+		return true, protojson.Unmarshal(b, m.Interface())
+	}
+	return false, nil
+}
+
+// isJSONDuration reports whether s is in the protojson form, e.g. "1.5s",
+// which covers a wider range than time.Duration.
+func isJSONDuration(s string) bool {
+	for i := 0; i < len(s)-1; i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && c != '.' && c != '-' {
+			return false
+		}
+	}
+	return len(s) > 0 && s[len(s)-1] == 's'
+}
+
+func unmarshalJSONString(s string, m protoreflect.Message) error {
+	return protojson.Unmarshal([]byte(strconv.Quote(s)), m.Interface())
+}
+
+func toInt64(v interface{}) (int64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if rv.Uint() > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return 0, false
+		}
+		return int64(f), true
+	}
+	return 0, false
+}
+
+func toUint64(v interface{}) (uint64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if rv.Int() < 0 {
+			return 0, false
+		}
+		return uint64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint(), true
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if f != math.Trunc(f) || f < 0 || f >= math.MaxUint64 {
+			return 0, false
+		}
+		return uint64(f), true
+	}
+	return 0, false
+}
+
+func toFloat64(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+func stringKeys(m map[interface{}]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k.(string)] = v
+	}
+	return out
+}
+
+func decodeJSON(b []byte) (interface{}, error) {
+	var v interface{}
+	err := json.Unmarshal(b, &v)
+	return v, err
+}
+
+// encodeJSON writes v as JSON, converting the map types produced by binary
+// decoders to string keyed maps first.
+func encodeJSON(v interface{}) ([]byte, error) {
+	return json.Marshal(jsonValue(v))
+}
+
+func jsonValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case nil, string, bool, []byte, time.Time:
+		return x
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map:
+		out := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			out[fmt.Sprint(iter.Key().Interface())] = jsonValue(iter.Value().Interface())
+		}
+		return out
+	case reflect.Slice, reflect.Array:
+		out := make([]interface{}, rv.Len())
+		for i := range out {
+			out[i] = jsonValue(rv.Index(i).Interface())
+		}
+		return out
+	}
+	return v
+}
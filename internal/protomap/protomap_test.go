@@ -0,0 +1,73 @@
+package protomap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	testData "github.com/sraphs/encoding/internal/testdata/complex"
+)
+
+func TestMarshal(t *testing.T) {
+	in := &testData.Complex{
+		NoOne:     "2233",
+		Sex:       testData.Sex_woman,
+		Simple:    &testData.Simple{Component: "5566"},
+		Timestamp: &timestamppb.Timestamp{Seconds: 20, Nanos: 2},
+		Duration:  &durationpb.Duration{Seconds: 120, Nanos: 22},
+		Int32:     &wrapperspb.Int32Value{Value: 32},
+	}
+
+	v, err := Options{}.Marshal(in.ProtoReflect())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"numberOne":   "2233",
+		"sex":         "woman",
+		"very_simple": map[string]interface{}{"component": "5566"},
+		"timestamp":   time.Unix(20, 2).UTC(),
+		"duration":    "120.000000022s",
+		"int32":       int32(32),
+	}, v)
+
+	v, err = Options{UseFieldNumbers: true, UseEnumNumbers: true}.Marshal(in.ProtoReflect())
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), v.(map[int]interface{})[6])
+}
+
+func TestUnmarshal(t *testing.T) {
+	v := map[interface{}]interface{}{
+		"no_one":    "2233",
+		uint8(1):    int16(7),
+		"sex":       uint8(1),
+		"simples":   []interface{}{"a", "b"},
+		"map":       map[interface{}]interface{}{"k": "v"},
+		"timestamp": "1970-01-01T00:00:20Z",
+		"duration":  "2m",
+		"field":     "aB,c",
+		"int64":     "64",
+		"unknown":   true,
+	}
+	out := &testData.Complex{}
+	require.NoError(t, Options{}.Unmarshal(v, out.ProtoReflect()))
+	assert.True(t, proto.Equal(&testData.Complex{
+		Id:        7,
+		NoOne:     "2233",
+		Sex:       testData.Sex_woman,
+		Simples:   []string{"a", "b"},
+		Map:       map[string]string{"k": "v"},
+		Timestamp: &timestamppb.Timestamp{Seconds: 20},
+		Duration:  &durationpb.Duration{Seconds: 120},
+		Field:     out.Field,
+		Int64:     &wrapperspb.Int64Value{Value: 64},
+	}, out), "got %v", out)
+	assert.Equal(t, []string{"a_b", "c"}, out.Field.Paths)
+
+	err := Options{}.Unmarshal(map[string]interface{}{"age": "x"}, out.ProtoReflect())
+	assert.Error(t, err)
+}
@@ -0,0 +1,538 @@
+// Package protopath populates proto messages from string values keyed by
+// field paths, such as verySimple_component=x or list[0].name=x.
+//
+// It is the engine shared by the codecs reading flat text keys, env, flag,
+// form and csv, which plug in their own rules to split keys into paths, split
+// list values into items and look fields up by name.
+package protopath
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"google.golang.org/genproto/protobuf/field_mask"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/sraphs/strcase"
+)
+
+// FieldFunc returns the field of fields named by a path element, or nil.
+type FieldFunc func(fields protoreflect.FieldDescriptors, name string) protoreflect.FieldDescriptor
+
+// Decoder populates proto messages from path keyed values.
+//
+// A path element may carry a list index or a map key in brackets, as in
+// list[0] or map[key]; a map key may also be the next element of the path.
+// Unknown fields are ignored.
+type Decoder struct {
+	// SplitKey splits a key into the elements of its path. If nil, the key
+	// is split on dots.
+	SplitKey func(key string) []string
+	// SplitList splits the values of a list field into its items. If nil,
+	// each value is an item.
+	SplitList func(values []string) []string
+	// Field looks up the field named by a path element, ByName if nil.
+	Field FieldFunc
+	// Format gives the text forms of the values.
+	Format Format
+}
+
+// MaxListIndex bounds the list indexes in keys, so that a key cannot make the
+// decoder allocate an arbitrarily large list.
+const MaxListIndex = 10000
+
+// SplitOn returns a SplitKey function splitting keys on sep.
+func SplitOn(sep string) func(key string) []string {
+	return func(key string) []string {
+		return strings.Split(key, sep)
+	}
+}
+
+// SplitComma splits each of values on commas.
+func SplitComma(values []string) []string {
+	return SplitListOn(",")(values)
+}
+
+// SplitListOn returns a SplitList function splitting each value on sep.
+func SplitListOn(sep string) func(values []string) []string {
+	return func(values []string) []string {
+		var out []string
+		for _, v := range values {
+			out = append(out, strings.Split(v, sep)...)
+		}
+		return out
+	}
+}
+
+// ByName looks a field up by its proto name, then by its JSON name.
+func ByName(fields protoreflect.FieldDescriptors, name string) protoreflect.FieldDescriptor {
+	if fd := fields.ByName(protoreflect.Name(name)); fd != nil {
+		return fd
+	}
+	return fields.ByJSONName(name)
+}
+
+// ByNameOrSnake is ByName, also matching the JSON name against the snake
+// case form of name, so that VerySimple finds very_simple.
+func ByNameOrSnake(fields protoreflect.FieldDescriptors, name string) protoreflect.FieldDescriptor {
+	if fd := ByName(fields, name); fd != nil {
+		return fd
+	}
+	return fields.ByJSONName(strcase.ToSnake(name))
+}
+
+// Decode populates msg with the values, keyed by path.
+func (d Decoder) Decode(msg proto.Message, values map[string][]string) error {
+	for key, vs := range values {
+		if err := d.Populate(msg.ProtoReflect(), key, vs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeStrings populates msg with the values, keyed by path, of a single
+// string each.
+func (d Decoder) DecodeStrings(msg proto.Message, values map[string]string) error {
+	for key, val := range values {
+		if err := d.Populate(msg.ProtoReflect(), key, []string{val}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Populate sets the field of v at the path key to values. Scalar fields take
+// a single value, list fields take all of them and map fields the last one.
+func (d Decoder) Populate(v protoreflect.Message, key string, values []string) error {
+	fieldPath := d.splitKey(key)
+	if len(fieldPath) < 1 {
+		return errors.New("no field path")
+	}
+	if len(values) < 1 {
+		return errors.New("no value provided")
+	}
+
+	var fd protoreflect.FieldDescriptor
+	var name string
+	for i, elem := range fieldPath {
+		var sub string
+		var hasSub bool
+		name, sub, hasSub = splitBracket(elem)
+		if fd = d.getFieldDescriptor(v, name); fd == nil {
+			// ignore unexpected field.
+			return nil
+		}
+		last := i == len(fieldPath)-1
+
+		if fd.IsMap() && (hasSub || !last) {
+			if !hasSub {
+				sub = fieldPath[i+1]
+			}
+			return d.populateMapField(fd, v.Mutable(fd).Map(), sub, values)
+		}
+		if hasSub {
+			if !fd.IsList() {
+				return fmt.Errorf("invalid path: %q is not a list or a map", name)
+			}
+			index, err := strconv.ParseUint(sub, 10, 32)
+			if err != nil {
+				return fmt.Errorf("invalid path: %q is not a list index of %q", sub, name)
+			}
+			if index > MaxListIndex {
+				return fmt.Errorf("list index %d of %q exceeds %d", index, fd.FullName().Name(), MaxListIndex)
+			}
+			list := v.Mutable(fd).List()
+			for list.Len() <= int(index) {
+				list.Append(list.NewElement())
+			}
+			if last {
+				if len(values) > 1 {
+					return fmt.Errorf("too many values for field %q: %s", elem, strings.Join(values, ", "))
+				}
+				val, err := d.Format.ParseField(fd, values[0])
+				if err != nil {
+					return fmt.Errorf("parsing list %q: %w", fd.FullName().Name(), err)
+				}
+				list.Set(int(index), val)
+				return nil
+			}
+			if fd.Message() == nil {
+				return fmt.Errorf("invalid path: %q is not a message", elem)
+			}
+			v = list.Get(int(index)).Message()
+			continue
+		}
+
+		if last {
+			break
+		}
+
+		if fd.Message() == nil || fd.Cardinality() == protoreflect.Repeated {
+			return fmt.Errorf("invalid path: %q is not a message", elem)
+		}
+
+		v = v.Mutable(fd).Message()
+	}
+	if of := fd.ContainingOneof(); of != nil {
+		if f := v.WhichOneof(of); f != nil {
+			return fmt.Errorf("field already set for oneof %q", of.FullName().Name())
+		}
+	}
+	switch {
+	case fd.IsList():
+		if d.SplitList != nil {
+			values = d.SplitList(values)
+		}
+		return d.populateRepeatedField(fd, v.Mutable(fd).List(), values)
+	case fd.IsMap():
+		return d.populateMapField(fd, v.Mutable(fd).Map(), name, values)
+	}
+	if len(values) > 1 {
+		return fmt.Errorf("too many values for field %q: %s", fd.FullName().Name(), strings.Join(values, ", "))
+	}
+	return d.populateField(fd, v, values[0])
+}
+
+// FieldAt returns the field of md at the path key, the value field for the
+// keys of map entries, or nil if there is none.
+func (d Decoder) FieldAt(md protoreflect.MessageDescriptor, key string) protoreflect.FieldDescriptor {
+	var fd protoreflect.FieldDescriptor
+	path := d.splitKey(key)
+	for i := 0; i < len(path); i++ {
+		if md == nil {
+			return nil
+		}
+		name, _, hasSub := splitBracket(path[i])
+		if fd = d.field()(md.Fields(), name); fd == nil {
+			return nil
+		}
+		if fd.IsMap() {
+			if !hasSub {
+				// the next element is the key.
+				i++
+			}
+			fd = fd.MapValue()
+		}
+		md = fd.Message()
+	}
+	return fd
+}
+
+// IsBytes reports whether fd is a bytes field or a BytesValue.
+func IsBytes(fd protoreflect.FieldDescriptor) bool {
+	return fd.Kind() == protoreflect.BytesKind || fd.Message() != nil && fd.Message().FullName() == BytesMessageFullname
+}
+
+func (d Decoder) splitKey(key string) []string {
+	if d.SplitKey == nil {
+		return strings.Split(key, ".")
+	}
+	return d.SplitKey(key)
+}
+
+func (d Decoder) field() FieldFunc {
+	if d.Field == nil {
+		return ByName
+	}
+	return d.Field
+}
+
+// splitBracket splits a path element such as "list[2]" or "map[key]" into
+// its name and the content of the brackets. Empty brackets, the list append
+// marker, are dropped.
+func splitBracket(s string) (string, string, bool) {
+	i := strings.IndexByte(s, '[')
+	if i <= 0 || !strings.HasSuffix(s, "]") {
+		return s, "", false
+	}
+	sub := s[i+1 : len(s)-1]
+	return s[:i], sub, sub != ""
+}
+
+func (d Decoder) getFieldDescriptor(v protoreflect.Message, fieldName string) protoreflect.FieldDescriptor {
+	fields := v.Descriptor().Fields()
+	fd := d.field()(fields, fieldName)
+	if fd == nil && v.Descriptor().FullName() == structMessageFullname {
+		fd = fields.ByNumber(structFieldsFieldNumber)
+	}
+	return fd
+}
+
+func (d Decoder) populateField(fd protoreflect.FieldDescriptor, v protoreflect.Message, value string) error {
+	val, err := d.Format.ParseField(fd, value)
+	if err != nil {
+		return fmt.Errorf("parsing field %q: %w", fd.FullName().Name(), err)
+	}
+	v.Set(fd, val)
+	return nil
+}
+
+func (d Decoder) populateRepeatedField(fd protoreflect.FieldDescriptor, list protoreflect.List, values []string) error {
+	for _, value := range values {
+		v, err := d.Format.ParseField(fd, value)
+		if err != nil {
+			return fmt.Errorf("parsing list %q: %w", fd.FullName().Name(), err)
+		}
+		list.Append(v)
+	}
+	return nil
+}
+
+func (d Decoder) populateMapField(fd protoreflect.FieldDescriptor, mp protoreflect.Map, mapKey string, values []string) error {
+	key, err := d.Format.ParseField(fd.MapKey(), mapKey)
+	if err != nil {
+		return fmt.Errorf("parsing map key %q: %w", fd.FullName().Name(), err)
+	}
+	value, err := d.Format.ParseField(fd.MapValue(), values[len(values)-1])
+	if err != nil {
+		return fmt.Errorf("parsing map value %q: %w", fd.FullName().Name(), err)
+	}
+	mp.Set(key.MapKey(), value)
+	return nil
+}
+
+// enum returns the descriptor of the enum of fd found by f.Types, or
+// protoregistry.GlobalTypes if nil, or the one of fd if not registered.
+func (f Format) enum(fd protoreflect.FieldDescriptor) (protoreflect.EnumDescriptor, error) {
+	types := f.Types
+	if types == nil {
+		types = protoregistry.GlobalTypes
+	}
+	enum, err := types.FindEnumByName(fd.Enum().FullName())
+	switch {
+	case errors.Is(err, protoregistry.NotFound):
+		return fd.Enum(), nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to look up enum: %w", err)
+	}
+	return enum.Descriptor(), nil
+}
+
+// enumValue returns the value of ed given by s, or nil. s is the name of the
+// value, in any case and with or without the prefix of the enum name, such
+// as active, ACTIVE or Status_Active for STATUS_ACTIVE of Status, or its
+// number.
+func enumValue(ed protoreflect.EnumDescriptor, s string) protoreflect.EnumValueDescriptor {
+	values := ed.Values()
+	if v := values.ByName(protoreflect.Name(s)); v != nil {
+		return v
+	}
+	if i, err := strconv.ParseInt(s, 10, 32); err == nil { //nolint:gomnd
+		return values.ByNumber(protoreflect.EnumNumber(i))
+	}
+
+	key := enumKey(s)
+	prefix := enumKey(string(ed.Name()))
+	var stripped protoreflect.EnumValueDescriptor
+	for i := 0; i < values.Len(); i++ {
+		v := values.Get(i)
+		name := enumKey(string(v.Name()))
+		if name == key {
+			return v
+		}
+		if stripped == nil && len(name) > len(prefix) && strings.HasPrefix(name, prefix) && name[len(prefix):] == key {
+			stripped = v
+		}
+	}
+	return stripped
+}
+
+// enumKey returns s in lower case without underscores and dashes, so that
+// STATUS_ACTIVE, status-active and statusActive compare equal.
+func enumKey(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r == '-' {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, s)
+}
+
+// ParseField parses the text form of a value of the field fd.
+func (f Format) ParseField(fd protoreflect.FieldDescriptor, value string) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfBool(v), nil
+	case protoreflect.EnumKind:
+		ed, err := f.enum(fd)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		v := enumValue(ed, value)
+		if v == nil {
+			return protoreflect.Value{}, fmt.Errorf("%q is not a valid value", value)
+		}
+		return protoreflect.ValueOfEnum(v.Number()), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		v, err := strconv.ParseInt(value, 10, 32) //nolint:gomnd
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfInt32(int32(v)), nil
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		v, err := strconv.ParseInt(value, 10, 64) //nolint:gomnd
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfInt64(v), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		v, err := strconv.ParseUint(value, 10, 32) //nolint:gomnd
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfUint32(uint32(v)), nil
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		v, err := strconv.ParseUint(value, 10, 64) //nolint:gomnd
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfUint64(v), nil
+	case protoreflect.FloatKind:
+		v, err := strconv.ParseFloat(value, 32) //nolint:gomnd
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfFloat32(float32(v)), nil
+	case protoreflect.DoubleKind:
+		v, err := strconv.ParseFloat(value, 64) //nolint:gomnd
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfFloat64(v), nil
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(value), nil
+	case protoreflect.BytesKind:
+		v, err := f.Bytes.Decode(value)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfBytes(v), nil
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return f.parseMessage(fd.Message(), value)
+	default:
+		panic(fmt.Sprintf("unknown field kind: %v", fd.Kind()))
+	}
+}
+
+func (f Format) parseMessage(md protoreflect.MessageDescriptor, value string) (protoreflect.Value, error) {
+	var msg proto.Message
+	switch md.FullName() {
+	case TimestampMessageFullname:
+		if value == NullString {
+			break
+		}
+		t, err := f.Timestamp.Parse(value)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		msg = timestamppb.New(t)
+	case DurationMessageFullname:
+		if value == NullString {
+			break
+		}
+		d, err := f.Duration.Parse(value)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		msg = durationpb.New(d)
+	case "google.protobuf.DoubleValue":
+		v, err := strconv.ParseFloat(value, 64) //nolint:gomnd
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		msg = wrapperspb.Double(v)
+	case "google.protobuf.FloatValue":
+		v, err := strconv.ParseFloat(value, 32) //nolint:gomnd
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		msg = wrapperspb.Float(float32(v))
+	case "google.protobuf.Int64Value":
+		v, err := strconv.ParseInt(value, 10, 64) //nolint:gomnd
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		msg = wrapperspb.Int64(v)
+	case "google.protobuf.Int32Value":
+		v, err := strconv.ParseInt(value, 10, 32) //nolint:gomnd
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		msg = wrapperspb.Int32(int32(v))
+	case "google.protobuf.UInt64Value":
+		v, err := strconv.ParseUint(value, 10, 64) //nolint:gomnd
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		msg = wrapperspb.UInt64(v)
+	case "google.protobuf.UInt32Value":
+		v, err := strconv.ParseUint(value, 10, 32) //nolint:gomnd
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		msg = wrapperspb.UInt32(uint32(v))
+	case "google.protobuf.BoolValue":
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		msg = wrapperspb.Bool(v)
+	case "google.protobuf.StringValue":
+		msg = wrapperspb.String(value)
+	case BytesMessageFullname:
+		v, err := f.Bytes.Decode(value)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		msg = wrapperspb.Bytes(v)
+	case "google.protobuf.FieldMask":
+		fm := &field_mask.FieldMask{}
+		for _, fv := range strings.Split(value, ",") {
+			fm.Paths = append(fm.Paths, jsonSnakeCase(fv))
+		}
+		msg = fm
+	case "google.protobuf.Value":
+		fm, err := structpb.NewValue(value)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		msg = fm
+	default:
+		return protoreflect.Value{}, fmt.Errorf("unsupported message type: %q", string(md.FullName()))
+	}
+	return protoreflect.ValueOfMessage(msg.ProtoReflect()), nil
+}
+
+// jsonSnakeCase converts a camelCase identifier to a snake_case identifier,
+// according to the protobuf JSON specification.
+// references: https://github.com/protocolbuffers/protobuf-go/blob/master/encoding/protojson/well_known_types.go#L864
+func jsonSnakeCase(s string) string {
+	var b []byte
+	for i := 0; i < len(s); i++ { // proto identifiers are always ASCII
+		c := s[i]
+		if isASCIIUpper(c) {
+			b = append(b, '_')
+			c += 'a' - 'A' // convert to lowercase
+		}
+		b = append(b, c)
+	}
+	return string(b)
+}
+
+func isASCIIUpper(c byte) bool {
+	return 'A' <= c && c <= 'Z'
+}
@@ -0,0 +1,175 @@
+package protopath
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// Mask is the tree of the fields named by the paths of a FieldMask: the
+// masked fields of a message and the masks of their own fields. The mask of
+// a field masked as a whole is nil, and so is the mask of a message whose
+// fields are all masked.
+type Mask map[protoreflect.Name]Mask
+
+// NewMask returns the mask of the paths of fm on the messages of md, nil if
+// fm is nil. Path elements are proto names, JSON names or the lowerCamelCase
+// form of proto names; only the last element of a path may name a list or a
+// map.
+func NewMask(md protoreflect.MessageDescriptor, fm *fieldmaskpb.FieldMask) (Mask, error) {
+	if fm == nil {
+		return nil, nil
+	}
+	mask := Mask{}
+	for _, path := range fm.GetPaths() {
+		m, fields := mask, md.Fields()
+		elems := strings.Split(path, ".")
+		for i, elem := range elems {
+			fd := fields.ByName(protoreflect.Name(elem))
+			if fd == nil {
+				fd = fields.ByJSONName(elem)
+			}
+			if fd == nil {
+				fd = fields.ByName(protoreflect.Name(jsonSnakeCase(elem)))
+			}
+			if fd == nil {
+				return nil, fmt.Errorf("invalid mask path %q: unknown field %q", path, elem)
+			}
+			sub, seen := m[fd.Name()]
+			if seen && sub == nil {
+				// the field is already masked as a whole.
+				break
+			}
+			if i == len(elems)-1 {
+				m[fd.Name()] = nil
+				break
+			}
+			if fd.Message() == nil || fd.IsList() || fd.IsMap() {
+				return nil, fmt.Errorf("invalid mask path %q: %q is not a message", path, elem)
+			}
+			if sub == nil {
+				sub = Mask{}
+				m[fd.Name()] = sub
+			}
+			m, fields = sub, fd.Message().Fields()
+		}
+	}
+	return mask, nil
+}
+
+// Field returns the mask of the field named name and whether it is masked.
+// Every field of a nil mask is.
+func (m Mask) Field(name protoreflect.Name) (Mask, bool) {
+	if m == nil {
+		return nil, true
+	}
+	sub, ok := m[name]
+	return sub, ok
+}
+
+// FilterJSON removes from doc, the protojson form of a message of md, the
+// fields m does not mask.
+func (m Mask) FilterJSON(md protoreflect.MessageDescriptor, doc map[string]interface{}) {
+	if m == nil {
+		return
+	}
+	for key, value := range doc {
+		fd := jsonField(md, key)
+		if fd == nil {
+			delete(doc, key)
+			continue
+		}
+		sub, ok := m.Field(fd.Name())
+		if !ok {
+			delete(doc, key)
+			continue
+		}
+		if obj, isObj := value.(map[string]interface{}); isObj && sub != nil && isMessageField(fd) {
+			sub.FilterJSON(fd.Message(), obj)
+		}
+	}
+}
+
+// JSONMask returns the mask of the fields present in doc, the protojson form
+// of a message of md. The fields of the messages are named one by one,
+// lists, maps and well-known types as a whole.
+func JSONMask(md protoreflect.MessageDescriptor, doc map[string]interface{}) *fieldmaskpb.FieldMask {
+	fm := &fieldmaskpb.FieldMask{}
+	jsonPaths(md, doc, "", &fm.Paths)
+	fm.Normalize()
+	return fm
+}
+
+func jsonPaths(md protoreflect.MessageDescriptor, doc map[string]interface{}, prefix string, paths *[]string) {
+	for key, value := range doc {
+		fd := jsonField(md, key)
+		if fd == nil {
+			continue
+		}
+		path := prefix + string(fd.Name())
+		if obj, isObj := value.(map[string]interface{}); isObj && len(obj) > 0 && isMessageField(fd) {
+			jsonPaths(fd.Message(), obj, path+".", paths)
+			continue
+		}
+		*paths = append(*paths, path)
+	}
+}
+
+// Mask returns the mask of the fields the keys populate, in the way of
+// JSONMask.
+func (d Decoder) Mask(md protoreflect.MessageDescriptor, keys []string) *fieldmaskpb.FieldMask {
+	fm := &fieldmaskpb.FieldMask{}
+	for _, key := range keys {
+		if path := d.maskPath(md, key); path != "" {
+			fm.Paths = append(fm.Paths, path)
+		}
+	}
+	fm.Normalize()
+	return fm
+}
+
+// maskPath returns the path of the field populated by key, or "" if key is
+// ignored.
+func (d Decoder) maskPath(md protoreflect.MessageDescriptor, key string) string {
+	var names []string
+	for _, elem := range d.splitKey(key) {
+		name, _, _ := splitBracket(elem)
+		fd := d.field()(md.Fields(), name)
+		if fd == nil {
+			return ""
+		}
+		names = append(names, string(fd.Name()))
+		if !isMessageField(fd) {
+			break
+		}
+		md = fd.Message()
+	}
+	return strings.Join(names, ".")
+}
+
+// jsonField returns the field of md named key in a protojson document.
+func jsonField(md protoreflect.MessageDescriptor, key string) protoreflect.FieldDescriptor {
+	if fd := md.Fields().ByJSONName(key); fd != nil {
+		return fd
+	}
+	return md.Fields().ByName(protoreflect.Name(key))
+}
+
+// isMessageField reports whether fd is a singular message field whose own
+// fields make paths, which excludes the well-known types.
+func isMessageField(fd protoreflect.FieldDescriptor) bool {
+	if fd.Message() == nil || fd.IsList() || fd.IsMap() {
+		return false
+	}
+	switch fd.Message().FullName() {
+	case TimestampMessageFullname, DurationMessageFullname, BytesMessageFullname, structMessageFullname,
+		"google.protobuf.FieldMask", "google.protobuf.Any", "google.protobuf.Value", "google.protobuf.ListValue",
+		"google.protobuf.Empty", "google.protobuf.DoubleValue", "google.protobuf.FloatValue",
+		"google.protobuf.Int64Value", "google.protobuf.Int32Value", "google.protobuf.UInt64Value",
+		"google.protobuf.UInt32Value", "google.protobuf.BoolValue", "google.protobuf.StringValue":
+		return false
+	}
+	return true
+}
@@ -0,0 +1,388 @@
+package protopath
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/sraphs/encoding/format"
+	testData "github.com/sraphs/encoding/internal/testdata/complex"
+	"github.com/sraphs/encoding/secret"
+)
+
+var (
+	envDecoder = Decoder{
+		SplitKey:  SplitOn("_"),
+		SplitList: SplitComma,
+		Field:     ByNameOrSnake,
+	}
+	flagDecoder = Decoder{
+		SplitKey:  SplitOn("."),
+		SplitList: SplitComma,
+		Field:     ByNameOrSnake,
+	}
+)
+
+func TestDecodeStrings(t *testing.T) {
+	want := &testData.Complex{
+		Id:        2233,
+		NoOne:     "2233",
+		Simple:    &testData.Simple{Component: "5566"},
+		Simples:   []string{"3344", "5566"},
+		B:         true,
+		Sex:       testData.Sex_woman,
+		Age:       18,
+		A:         19,
+		Count:     3,
+		Price:     11.23,
+		D:         22.22,
+		Byte:      []byte("123"),
+		Timestamp: &timestamppb.Timestamp{Seconds: 20, Nanos: 2},
+		Duration:  &durationpb.Duration{Seconds: 120, Nanos: 22},
+		Field:     &fieldmaskpb.FieldMask{Paths: []string{"1", "2"}},
+		Double:    wrapperspb.Double(12.33),
+		Float:     wrapperspb.Float(12.34),
+		Int64:     wrapperspb.Int64(64),
+		Int32:     wrapperspb.Int32(32),
+		Uint64:    wrapperspb.UInt64(64),
+		Uint32:    wrapperspb.UInt32(32),
+		Bool:      wrapperspb.Bool(false),
+		String_:   wrapperspb.String("sraph"),
+		Bytes:     wrapperspb.Bytes([]byte("123")),
+		Map:       map[string]string{"sraph": "https://sraph.com/"},
+	}
+	values := map[string]string{
+		"id":        "2233",
+		"numberOne": "2233",
+		"component": "5566",
+		"simples":   "3344,5566",
+		"b":         "true",
+		"sex":       "woman",
+		"age":       "18",
+		"a":         "19",
+		"count":     "3",
+		"price":     "11.23",
+		"d":         "22.22",
+		"byte":      "MTIz",
+		"timestamp": "1970-01-01T00:00:20.000000002Z",
+		"duration":  "2m0.000000022s",
+		"field":     "1,2",
+		"double":    "12.33",
+		"float":     "12.34",
+		"int64":     "64",
+		"int32":     "32",
+		"uint64":    "64",
+		"uint32":    "32",
+		"bool":      "false",
+		"string":    "sraph",
+		"bytes":     "MTIz",
+		"map":       "",
+	}
+
+	for name, tt := range map[string]struct {
+		decoder Decoder
+		sep     string
+	}{
+		"env":  {envDecoder, "_"},
+		"flag": {flagDecoder, "."},
+	} {
+		t.Run(name, func(t *testing.T) {
+			vs := make(map[string]string, len(values))
+			for k, v := range values {
+				switch k {
+				case "component":
+					k = "verySimple" + tt.sep + k
+				case "map":
+					k, v = "map"+tt.sep+"sraph", "https://sraph.com/"
+				}
+				vs[k] = v
+			}
+			got := &testData.Complex{}
+			require.NoError(t, tt.decoder.DecodeStrings(got, vs))
+			assert.True(t, proto.Equal(want, got), "got %v", got)
+		})
+	}
+}
+
+func TestPopulate(t *testing.T) {
+	d := Decoder{}
+	m := &testData.Complex{}
+
+	require.NoError(t, d.Populate(m.ProtoReflect(), "simples", []string{"a", "b,c"}))
+	assert.Equal(t, []string{"a", "b,c"}, m.Simples)
+	require.NoError(t, d.Populate(m.ProtoReflect(), "simples[4]", []string{"e"}))
+	assert.Equal(t, []string{"a", "b,c", "", "", "e"}, m.Simples)
+	require.NoError(t, d.Populate(m.ProtoReflect(), "map[k]", []string{"v1", "v2"}))
+	require.NoError(t, d.Populate(m.ProtoReflect(), "map.x.ignored", []string{"y"}))
+	assert.Equal(t, map[string]string{"k": "v2", "x": "y"}, m.Map)
+
+	// unknown fields are ignored.
+	require.NoError(t, d.Populate(m.ProtoReflect(), "unknown.field", []string{"x"}))
+
+	assert.EqualError(t, d.Populate(m.ProtoReflect(), "age", []string{"1", "2"}), `too many values for field "age": 1, 2`)
+	assert.EqualError(t, d.Populate(m.ProtoReflect(), "age", nil), "no value provided")
+	assert.EqualError(t, d.Populate(m.ProtoReflect(), "age.x", []string{"1"}), `invalid path: "age" is not a message`)
+	assert.EqualError(t, d.Populate(m.ProtoReflect(), "age[1]", []string{"1"}), `invalid path: "age" is not a list or a map`)
+	assert.EqualError(t, d.Populate(m.ProtoReflect(), "simples[x]", []string{"1"}), `invalid path: "x" is not a list index of "simples"`)
+	assert.Error(t, d.Populate(m.ProtoReflect(), "age", []string{"x"}))
+
+	split := Decoder{SplitList: SplitListOn("|")}
+	m = &testData.Complex{}
+	require.NoError(t, split.Populate(m.ProtoReflect(), "simples", []string{"a|b", "c"}))
+	assert.Equal(t, []string{"a", "b", "c"}, m.Simples)
+}
+
+func TestPopulateMessageList(t *testing.T) {
+	d := Decoder{}
+	fds := &descriptorpb.FileDescriptorSet{}
+	require.NoError(t, d.Decode(fds, map[string][]string{
+		"file[1].name":    {"b.proto"},
+		"file[0].name":    {"a.proto"},
+		"file[0].package": {"pkg"},
+	}))
+	require.Len(t, fds.File, 2)
+	assert.Equal(t, "a.proto", fds.File[0].GetName())
+	assert.Equal(t, "pkg", fds.File[0].GetPackage())
+	assert.Equal(t, "b.proto", fds.File[1].GetName())
+
+	assert.EqualError(t, d.Decode(fds, map[string][]string{"file[100000].name": {"x"}}),
+		`list index 100000 of "file" exceeds 10000`)
+}
+
+func TestFieldAt(t *testing.T) {
+	md := (&testData.Complex{}).ProtoReflect().Descriptor()
+	for key, want := range map[string]protoreflect.Name{
+		"byte":                  "byte",
+		"bytes":                 "bytes",
+		"verySimple_component":  "component",
+		"very_simple_component": "",
+		"map_x":                 "value",
+		"unknown":               "",
+	} {
+		fd := envDecoder.FieldAt(md, key)
+		if want == "" {
+			assert.Nil(t, fd, key)
+			continue
+		}
+		require.NotNil(t, fd, key)
+		assert.Equal(t, want, fd.Name(), key)
+	}
+	assert.True(t, IsBytes(envDecoder.FieldAt(md, "byte")))
+	assert.True(t, IsBytes(envDecoder.FieldAt(md, "bytes")))
+	assert.False(t, IsBytes(envDecoder.FieldAt(md, "string")))
+	assert.Nil(t, envDecoder.FieldAt(nil, "byte"))
+}
+
+func TestResolveSecrets(t *testing.T) {
+	rs := secret.Resolvers{"secretref": secret.Memory{"key": []byte{0, 1, 2}}}
+	md := (&testData.Complex{}).ProtoReflect().Descriptor()
+
+	vs := map[string]string{"byte": "secretref://key", "string": "secretref://key", "id": "1"}
+	require.NoError(t, envDecoder.ResolveSecrets(rs, vs, md))
+	assert.Equal(t, map[string]string{
+		"byte":   base64.StdEncoding.EncodeToString([]byte{0, 1, 2}),
+		"string": string([]byte{0, 1, 2}),
+		"id":     "1",
+	}, vs)
+
+	vs = map[string]string{"byte": "secretref://key"}
+	require.NoError(t, envDecoder.ResolveSecrets(rs, vs, nil))
+	assert.Equal(t, string([]byte{0, 1, 2}), vs["byte"])
+
+	assert.Error(t, envDecoder.ResolveSecrets(rs, map[string]string{"a": "secretref://missing"}, md))
+}
+
+func TestFormatField(t *testing.T) {
+	m := &testData.Complex{
+		Sex:       testData.Sex_woman,
+		Timestamp: timestamppb.New(time.Unix(20, 2)),
+		Duration:  durationpb.New(2*time.Minute + 22),
+		Field:     &fieldmaskpb.FieldMask{Paths: []string{"very_simple", "id"}},
+		Bytes:     wrapperspb.Bytes([]byte("123")),
+		Int32:     wrapperspb.Int32(32),
+	}
+	fields := m.ProtoReflect().Descriptor().Fields()
+	for name, want := range map[protoreflect.Name]string{
+		"sex":       "woman",
+		"timestamp": "1970-01-01T00:00:20.000000002Z",
+		"duration":  "2m0.000000022s",
+		"field":     "verySimple,id",
+		"bytes":     "MTIz",
+		"int32":     "32",
+	} {
+		fd := fields.ByName(name)
+		s, err := Format{}.FormatField(fd, m.ProtoReflect().Get(fd))
+		require.NoError(t, err)
+		assert.Equal(t, want, s, name)
+
+		// the string form reads back.
+		v, err := Format{}.ParseField(fd, s)
+		require.NoError(t, err, name)
+		assert.True(t, v.Equal(m.ProtoReflect().Get(fd)), name)
+	}
+	// formatting does not change the message.
+	assert.Equal(t, []string{"very_simple", "id"}, m.Field.Paths)
+
+	f := Format{Timestamp: format.UnixMillis, Duration: format.ISO8601Duration}
+	s, err := f.FormatField(fields.ByName("timestamp"), m.ProtoReflect().Get(fields.ByName("timestamp")))
+	require.NoError(t, err)
+	assert.Equal(t, "20000", s)
+	s, err = f.FormatField(fields.ByName("duration"), m.ProtoReflect().Get(fields.ByName("duration")))
+	require.NoError(t, err)
+	assert.Equal(t, "PT2M0.000000022S", s)
+	v, err := f.ParseField(fields.ByName("duration"), "P1D")
+	require.NoError(t, err)
+	assert.Equal(t, int64(86400), v.Message().Interface().(*durationpb.Duration).GetSeconds())
+
+	_, err = Format{}.FormatMessage(fields.ByName("simple").Message(), m.ProtoReflect().Get(fields.ByName("simple")))
+	assert.Error(t, err)
+}
+
+func TestParseEnum(t *testing.T) {
+	md := statusDescriptor(t)
+	fd := md.Fields().ByName("status")
+	for s, want := range map[string]protoreflect.EnumNumber{
+		"STATUS_ACTIVE":      1,
+		"1":                  1,
+		"active":             1,
+		"ACTIVE":             1,
+		"Status_Active":      1,
+		"status-active":      1,
+		"activeUser":         2,
+		"ACTIVE_USER":        2,
+		"statusActiveUser":   2,
+		"status_unspecified": 0,
+	} {
+		v, err := Format{}.ParseField(fd, s)
+		require.NoError(t, err, s)
+		assert.Equal(t, want, v.Enum(), s)
+	}
+	for _, s := range []string{"", "inactive", "status", "3", "STATUS"} {
+		_, err := Format{}.ParseField(fd, s)
+		assert.Error(t, err, s)
+	}
+
+	// the message and its enum are not registered.
+	m := dynamicpb.NewMessage(md)
+	require.NoError(t, envDecoder.DecodeStrings(m, map[string]string{"status": "active", "statuses": "ACTIVE_USER,0"}))
+	assert.Equal(t, protoreflect.EnumNumber(1), m.Get(fd).Enum())
+	list := m.Get(md.Fields().ByName("statuses")).List()
+	require.Equal(t, 2, list.Len())
+	assert.Equal(t, protoreflect.EnumNumber(2), list.Get(0).Enum())
+	assert.Equal(t, protoreflect.EnumNumber(0), list.Get(1).Enum())
+
+	// a custom resolver has precedence over the descriptor of the field.
+	edp := protodesc.ToEnumDescriptorProto(fd.Enum())
+	edp.Value = append(edp.Value, &descriptorpb.EnumValueDescriptorProto{Name: proto.String("STATUS_RETIRED"), Number: proto.Int32(3)})
+	fdp := protodesc.ToFileDescriptorProto(md.ParentFile())
+	fdp.EnumType = []*descriptorpb.EnumDescriptorProto{edp}
+	file, err := protodesc.NewFile(fdp, nil)
+	require.NoError(t, err)
+	types := &protoregistry.Types{}
+	require.NoError(t, types.RegisterEnum(dynamicpb.NewEnumType(file.Enums().Get(0))))
+
+	_, err = Format{}.ParseField(fd, "retired")
+	assert.Error(t, err)
+	v, err := Format{Types: types}.ParseField(fd, "retired")
+	require.NoError(t, err)
+	assert.Equal(t, protoreflect.EnumNumber(3), v.Enum())
+}
+
+func statusDescriptor(t *testing.T) protoreflect.MessageDescriptor {
+	t.Helper()
+	value := func(name string, number int32) *descriptorpb.EnumValueDescriptorProto {
+		return &descriptorpb.EnumValueDescriptorProto{Name: proto.String(name), Number: proto.Int32(number)}
+	}
+	field := func(name string, number int32, label descriptorpb.FieldDescriptorProto_Label) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(number),
+			Type:     descriptorpb.FieldDescriptorProto_TYPE_ENUM.Enum(),
+			TypeName: proto.String(".protopath.test.Status"),
+			Label:    label.Enum(),
+		}
+	}
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("protopath_test.proto"),
+		Package: proto.String("protopath.test"),
+		Syntax:  proto.String("proto3"),
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name:  proto.String("Status"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{value("STATUS_UNSPECIFIED", 0), value("STATUS_ACTIVE", 1), value("STATUS_ACTIVE_USER", 2)},
+		}},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Account"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("status", 1, descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL),
+				field("statuses", 2, descriptorpb.FieldDescriptorProto_LABEL_REPEATED),
+			},
+		}},
+	}, nil)
+	require.NoError(t, err)
+	return fd.Messages().Get(0)
+}
+
+func TestMask(t *testing.T) {
+	md := (&testData.Complex{}).ProtoReflect().Descriptor()
+
+	mask, err := NewMask(md, &fieldmaskpb.FieldMask{Paths: []string{"simple.component", "numberOne", "map", "id", "id"}})
+	require.NoError(t, err)
+	assert.Equal(t, Mask{"simple": {"component": nil}, "no_one": nil, "map": nil, "id": nil}, mask)
+	mask, err = NewMask(md, &fieldmaskpb.FieldMask{Paths: []string{"very_simple", "simple.component", "noOne"}})
+	require.NoError(t, err)
+	assert.Equal(t, Mask{"simple": nil, "no_one": nil}, mask)
+	mask, err = NewMask(md, nil)
+	require.NoError(t, err)
+	assert.Nil(t, mask)
+
+	for path, msg := range map[string]string{
+		"unknown":   `invalid mask path "unknown": unknown field "unknown"`,
+		"id.x":      `invalid mask path "id.x": "id" is not a message`,
+		"simples.x": `invalid mask path "simples.x": "simples" is not a message`,
+		"simple.x":  `invalid mask path "simple.x": unknown field "x"`,
+	} {
+		_, err := NewMask(md, &fieldmaskpb.FieldMask{Paths: []string{path}})
+		assert.EqualError(t, err, msg)
+	}
+
+	doc := map[string]interface{}{
+		"id":          "1",
+		"numberOne":   "x",
+		"very_simple": map[string]interface{}{"component": "c"},
+		"timestamp":   "1970-01-01T00:00:20Z",
+		"map":         map[string]interface{}{"k": "v"},
+		"simples":     []interface{}{},
+	}
+	mask, err = NewMask(md, &fieldmaskpb.FieldMask{Paths: []string{"simple.component", "map", "timestamp"}})
+	require.NoError(t, err)
+	mask.FilterJSON(md, doc)
+	assert.Equal(t, map[string]interface{}{
+		"very_simple": map[string]interface{}{"component": "c"},
+		"timestamp":   "1970-01-01T00:00:20Z",
+		"map":         map[string]interface{}{"k": "v"},
+	}, doc)
+
+	doc["very_simple"] = map[string]interface{}{}
+	doc["unknown"] = "x"
+	assert.Equal(t, []string{"map", "simple", "timestamp"}, JSONMask(md, doc).GetPaths())
+	doc["very_simple"] = map[string]interface{}{"component": "c"}
+	assert.Equal(t, []string{"map", "simple.component", "timestamp"}, JSONMask(md, doc).GetPaths())
+
+	assert.Equal(t, []string{"map", "no_one", "simple.component", "simples"}, envDecoder.Mask(md, []string{
+		"verySimple_component", "numberOne", "map_k", "simples", "unknown_x", "map_j",
+	}).GetPaths())
+}
@@ -0,0 +1,29 @@
+package protopath
+
+import (
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/sraphs/encoding/secret"
+)
+
+// ResolveSecrets replaces the values of vs that are secret references by
+// their content. The content for the bytes fields of md is encoded in the
+// bytes format of the decoder; md may be nil for values not meant
+// for a message.
+func (d Decoder) ResolveSecrets(rs secret.Resolvers, vs map[string]string, md protoreflect.MessageDescriptor) error {
+	for k, v := range vs {
+		b, ok, err := rs.Resolve(v)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if fd := d.FieldAt(md, k); fd != nil && IsBytes(fd) {
+			vs[k] = d.Format.Bytes.Encode(b)
+		} else {
+			vs[k] = string(b)
+		}
+	}
+	return nil
+}
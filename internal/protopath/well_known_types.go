@@ -0,0 +1,191 @@
+package protopath
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/genproto/protobuf/field_mask"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	"github.com/sraphs/encoding/format"
+)
+
+// NullString is the string form of google.protobuf.NullValue, and of unset
+// Timestamp and Duration values.
+const NullString = "null"
+
+const (
+	// TimestampMessageFullname is the name of google.protobuf.Timestamp.
+	TimestampMessageFullname    protoreflect.FullName    = "google.protobuf.Timestamp"
+	maxTimestampSeconds                                  = 253402300799
+	minTimestampSeconds                                  = -6213559680013
+	timestampSecondsFieldNumber protoreflect.FieldNumber = 1
+	timestampNanosFieldNumber   protoreflect.FieldNumber = 2
+
+	// DurationMessageFullname is the name of google.protobuf.Duration.
+	DurationMessageFullname    protoreflect.FullName    = "google.protobuf.Duration"
+	secondsInNanos                                      = 999999999
+	durationSecondsFieldNumber protoreflect.FieldNumber = 1
+	durationNanosFieldNumber   protoreflect.FieldNumber = 2
+
+	// BytesMessageFullname is the name of google.protobuf.BytesValue.
+	BytesMessageFullname  protoreflect.FullName    = "google.protobuf.BytesValue"
+	bytesValueFieldNumber protoreflect.FieldNumber = 1
+
+	// google.protobuf.Struct.
+	structMessageFullname   protoreflect.FullName    = "google.protobuf.Struct"
+	structFieldsFieldNumber protoreflect.FieldNumber = 1
+)
+
+// Format gives the text forms of the values of proto fields. The zero value
+// writes bytes in standard base64, timestamps in RFC 3339 and durations in
+// Go syntax.
+type Format struct {
+	// Bytes is the encoding of bytes and google.protobuf.BytesValue values.
+	// Decoding accepts every base64 variant unless it is format.Hex.
+	Bytes format.Bytes
+	// Timestamp is the form of google.protobuf.Timestamp values. Decoding
+	// also accepts RFC 3339.
+	Timestamp format.Timestamp
+	// Duration is the form of google.protobuf.Duration values. Decoding
+	// accepts every form.
+	Duration format.Duration
+	// Types resolves the enums of the fields by name, falling back to the
+	// enum descriptors of the fields. protoregistry.GlobalTypes is used if
+	// nil.
+	Types *protoregistry.Types
+}
+
+// FormatField returns the text form of a value of the field fd, the one
+// ParseField reads.
+func (f Format) FormatField(fd protoreflect.FieldDescriptor, value protoreflect.Value) (string, error) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return strconv.FormatBool(value.Bool()), nil
+	case protoreflect.EnumKind:
+		if fd.Enum().FullName() == "google.protobuf.NullValue" {
+			return NullString, nil
+		}
+		desc := fd.Enum().Values().ByNumber(value.Enum())
+		if desc == nil {
+			return strconv.Itoa(int(value.Enum())), nil
+		}
+		return string(desc.Name()), nil
+	case protoreflect.StringKind:
+		return value.String(), nil
+	case protoreflect.BytesKind:
+		return f.Bytes.Encode(value.Bytes()), nil
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return f.FormatMessage(fd.Message(), value)
+	default:
+		return fmt.Sprintf("%v", value.Interface()), nil
+	}
+}
+
+// FormatMessage returns the text form of a well-known type value, or an
+// error for the other messages, which are written field by field.
+func (f Format) FormatMessage(md protoreflect.MessageDescriptor, value protoreflect.Value) (string, error) {
+	switch md.FullName() {
+	case TimestampMessageFullname:
+		return f.marshalTimestamp(value.Message())
+	case DurationMessageFullname:
+		return f.marshalDuration(value.Message())
+	case BytesMessageFullname:
+		return f.marshalBytes(value.Message())
+	case "google.protobuf.DoubleValue", "google.protobuf.FloatValue", "google.protobuf.Int64Value", "google.protobuf.Int32Value",
+		"google.protobuf.UInt64Value", "google.protobuf.UInt32Value", "google.protobuf.BoolValue", "google.protobuf.StringValue":
+		fd := md.Fields()
+		v := value.Message().Get(fd.ByName(protoreflect.Name("value")))
+		return fmt.Sprintf("%v", v.Interface()), nil
+	case "google.protobuf.FieldMask":
+		m, ok := value.Message().Interface().(*field_mask.FieldMask)
+		if !ok {
+			return "", nil
+		}
+		paths := make([]string, len(m.GetPaths()))
+		for i, v := range m.GetPaths() {
+			paths[i] = jsonCamelCase(v)
+		}
+		return strings.Join(paths, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported message type: %q", string(md.FullName()))
+	}
+}
+
+func (f Format) marshalTimestamp(m protoreflect.Message) (string, error) {
+	fds := m.Descriptor().Fields()
+	fdSeconds := fds.ByNumber(timestampSecondsFieldNumber)
+	fdNanos := fds.ByNumber(timestampNanosFieldNumber)
+
+	secsVal := m.Get(fdSeconds)
+	nanosVal := m.Get(fdNanos)
+	secs := secsVal.Int()
+	nanos := nanosVal.Int()
+	if secs < minTimestampSeconds || secs > maxTimestampSeconds {
+		return "", fmt.Errorf("%s: seconds out of range %v", TimestampMessageFullname, secs)
+	}
+	if nanos < 0 || nanos > secondsInNanos {
+		return "", fmt.Errorf("%s: nanos out of range %v", TimestampMessageFullname, nanos)
+	}
+	return f.Timestamp.Format(time.Unix(secs, nanos)), nil
+}
+
+func (f Format) marshalDuration(m protoreflect.Message) (string, error) {
+	fds := m.Descriptor().Fields()
+	fdSeconds := fds.ByNumber(durationSecondsFieldNumber)
+	fdNanos := fds.ByNumber(durationNanosFieldNumber)
+
+	secsVal := m.Get(fdSeconds)
+	nanosVal := m.Get(fdNanos)
+	secs := secsVal.Int()
+	nanos := nanosVal.Int()
+	d := time.Duration(secs) * time.Second
+	overflow := d/time.Second != time.Duration(secs)
+	d += time.Duration(nanos) * time.Nanosecond
+	overflow = overflow || (secs < 0 && nanos < 0 && d > 0)
+	overflow = overflow || (secs > 0 && nanos > 0 && d < 0)
+	if overflow {
+		switch {
+		case secs < 0:
+			d = time.Duration(math.MinInt64)
+		case secs > 0:
+			d = time.Duration(math.MaxInt64)
+		}
+	}
+	return f.Duration.Format(d), nil
+}
+
+func (f Format) marshalBytes(m protoreflect.Message) (string, error) {
+	fds := m.Descriptor().Fields()
+	fdBytes := fds.ByNumber(bytesValueFieldNumber)
+	bytesVal := m.Get(fdBytes)
+	val := bytesVal.Bytes()
+	return f.Bytes.Encode(val), nil
+}
+
+// jsonCamelCase converts a snake_case identifier to a camelCase identifier,
+// according to the protobuf JSON specification.
+// references: https://github.com/protocolbuffers/protobuf-go/blob/master/encoding/protojson/well_known_types.go#L842
+func jsonCamelCase(s string) string {
+	var b []byte
+	var wasUnderscore bool
+	for i := 0; i < len(s); i++ { // proto identifiers are always ASCII
+		c := s[i]
+		if c != '_' {
+			if wasUnderscore && isASCIILower(c) {
+				c -= 'a' - 'A' // convert to uppercase
+			}
+			b = append(b, c)
+		}
+		wasUnderscore = c == '_'
+	}
+	return string(b)
+}
+
+func isASCIILower(c byte) bool {
+	return 'a' <= c && c <= 'z'
+}
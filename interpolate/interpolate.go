@@ -0,0 +1,213 @@
+// Package interpolate expands shell style variable references in strings.
+//
+// The supported forms are:
+//
+//	$VAR, ${VAR}     the value of VAR, empty if unset
+//	${VAR:-default}  default if VAR is unset or empty
+//	${VAR-default}   default if VAR is unset
+//	${VAR:?message}  an error with message if VAR is unset or empty
+//	${VAR?message}   an error with message if VAR is unset
+//	$$, \$           a literal $
+//
+// Defaults and messages may themselves contain references.
+package interpolate
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// LookupFunc returns the value of the variable name and whether it is set.
+type LookupFunc func(name string) (string, bool)
+
+// Env looks variables up in the process environment.
+var Env LookupFunc = os.LookupEnv
+
+// Expand replaces the references in s with the values given by lookup, or by
+// Env if lookup is nil.
+func Expand(s string, lookup LookupFunc) (string, error) {
+	if lookup == nil {
+		lookup = Env
+	}
+	return expand(s, func(name string) (string, bool, error) {
+		v, ok := lookup(name)
+		return v, ok, nil
+	})
+}
+
+// ExpandMap returns the values of vars with their references expanded.
+// References to the variables of vars are resolved within vars, whatever
+// their order, and the other ones with lookup, or Env if lookup is nil.
+// Variables referencing themselves, directly or not, are an error.
+func ExpandMap(vars map[string]string, lookup LookupFunc) (map[string]string, error) {
+	if lookup == nil {
+		lookup = Env
+	}
+	r := &resolver{
+		vars:   vars,
+		lookup: lookup,
+		done:   make(map[string]string, len(vars)),
+		active: make(map[string]bool),
+	}
+	for name := range vars {
+		if _, err := r.resolve(name); err != nil {
+			return nil, err
+		}
+	}
+	return r.done, nil
+}
+
+type resolver struct {
+	vars   map[string]string
+	lookup LookupFunc
+	done   map[string]string
+	active map[string]bool
+	stack  []string
+}
+
+func (r *resolver) resolve(name string) (string, error) {
+	if v, ok := r.done[name]; ok {
+		return v, nil
+	}
+	if r.active[name] {
+		return "", fmt.Errorf("interpolate: cycle in variables: %s -> %s", strings.Join(r.stack, " -> "), name)
+	}
+	r.active[name] = true
+	r.stack = append(r.stack, name)
+	v, err := expand(r.vars[name], func(ref string) (string, bool, error) {
+		if _, ok := r.vars[ref]; !ok {
+			v, ok := r.lookup(ref)
+			return v, ok, nil
+		}
+		v, err := r.resolve(ref)
+		return v, true, err
+	})
+	r.stack = r.stack[:len(r.stack)-1]
+	delete(r.active, name)
+	if err != nil {
+		return "", err
+	}
+	r.done[name] = v
+	return v, nil
+}
+
+func expand(s string, lookup func(string) (string, bool, error)) (string, error) {
+	if !strings.ContainsRune(s, '$') {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && s[i+1] == '$':
+			b.WriteByte('$')
+			i++
+		case c != '$' || i+1 == len(s):
+			b.WriteByte(c)
+		case s[i+1] == '$':
+			b.WriteByte('$')
+			i++
+		case s[i+1] == '{':
+			end := closingBrace(s, i+2)
+			if end < 0 {
+				return "", fmt.Errorf("interpolate: unterminated reference in %q", s)
+			}
+			v, err := expandBraced(s[i+2:end], lookup)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(v)
+			i = end
+		default:
+			n := nameLen(s[i+1:])
+			if n == 0 {
+				b.WriteByte(c)
+				continue
+			}
+			v, _, err := lookup(s[i+1 : i+1+n])
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(v)
+			i += n
+		}
+	}
+	return b.String(), nil
+}
+
+// expandBraced expands the content of ${...}.
+func expandBraced(ref string, lookup func(string) (string, bool, error)) (string, error) {
+	n := nameLen(ref)
+	if n == 0 {
+		return "", fmt.Errorf("interpolate: invalid reference ${%s}", ref)
+	}
+	name, op := ref[:n], ref[n:]
+	value, ok, err := lookup(name)
+	if err != nil {
+		return "", err
+	}
+
+	colon := strings.HasPrefix(op, ":")
+	if colon {
+		op = op[1:]
+	}
+	if op == "" && !colon {
+		return value, nil
+	}
+	missing := !ok || colon && value == ""
+	switch {
+	case strings.HasPrefix(op, "-"):
+		if missing {
+			return expand(op[1:], lookup)
+		}
+		return value, nil
+	case strings.HasPrefix(op, "?"):
+		if missing {
+			msg, err := expand(op[1:], lookup)
+			if err != nil {
+				return "", err
+			}
+			if msg == "" {
+				msg = "not set"
+			}
+			return "", fmt.Errorf("interpolate: %s: %s", name, msg)
+		}
+		return value, nil
+	default:
+		return "", fmt.Errorf("interpolate: invalid reference ${%s}", ref)
+	}
+}
+
+// closingBrace returns the index of the brace closing the reference whose
+// content starts at i, skipping nested references.
+func closingBrace(s string, i int) int {
+	depth := 1
+	for ; i < len(s); i++ {
+		switch {
+		case s[i] == '$' && i+1 < len(s) && s[i+1] == '{':
+			depth++
+			i++
+		case s[i] == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// nameLen returns the length of the variable name at the start of s.
+func nameLen(s string) int {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '_', 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case '0' <= c && c <= '9' && i > 0:
+		default:
+			return i
+		}
+	}
+	return len(s)
+}
@@ -0,0 +1,83 @@
+package interpolate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func lookupMap(m map[string]string) LookupFunc {
+	return func(name string) (string, bool) {
+		v, ok := m[name]
+		return v, ok
+	}
+}
+
+func TestExpand(t *testing.T) {
+	lookup := lookupMap(map[string]string{
+		"HOST":  "db",
+		"PORT":  "5432",
+		"EMPTY": "",
+	})
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"plain", "plain"},
+		{"$HOST:$PORT", "db:5432"},
+		{"${HOST}_x", "db_x"},
+		{"${MISSING}", ""},
+		{"${MISSING:-def}", "def"},
+		{"${EMPTY:-def}", "def"},
+		{"${EMPTY-def}", ""},
+		{"${MISSING-def}", "def"},
+		{"${HOST:-def}", "db"},
+		{"${MISSING:-${HOST}:${PORT}}", "db:5432"},
+		{"${HOST:?required}", "db"},
+		{"$$HOST", "$HOST"},
+		{`\${HOST}`, "${HOST}"},
+		{"cost: 5$", "cost: 5$"},
+		{"$1", "$1"},
+	}
+	for _, tt := range tests {
+		got, err := Expand(tt.in, lookup)
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+
+	for _, in := range []string{"${MISSING:?required}", "${EMPTY:?}", "${MISSING?required}", "${HOST", "${}", "${HOST:+x}"} {
+		_, err := Expand(in, lookup)
+		assert.Error(t, err, in)
+	}
+	_, err := Expand("${EMPTY?required}", lookup)
+	assert.NoError(t, err)
+
+	_, err = Expand("${MISSING:?database host is required}", lookup)
+	assert.EqualError(t, err, "interpolate: MISSING: database host is required")
+}
+
+func TestExpandMap(t *testing.T) {
+	got, err := ExpandMap(map[string]string{
+		"DATABASE_URL": "postgres://${DB_HOST}:${DB_PORT:-5432}/${DB_NAME}",
+		"DB_HOST":      "${HOST}",
+		"DB_NAME":      "app",
+	}, lookupMap(map[string]string{"HOST": "localhost", "DB_NAME": "ignored"}))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"DATABASE_URL": "postgres://localhost:5432/app",
+		"DB_HOST":      "localhost",
+		"DB_NAME":      "app",
+	}, got)
+
+	_, err = ExpandMap(map[string]string{
+		"A": "${B}",
+		"B": "${C}",
+		"C": "x${A}",
+	}, lookupMap(nil))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cycle")
+
+	_, err = ExpandMap(map[string]string{"A": "$A"}, lookupMap(nil))
+	assert.Error(t, err)
+}
@@ -1,11 +1,22 @@
 package json
 
 import (
+	"bytes"
 	"encoding/json"
 	"reflect"
 
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+
+	"github.com/sraphs/encoding/internal/protopath"
+	"github.com/sraphs/encoding/interpolate"
+	"github.com/sraphs/encoding/merge"
+	"github.com/sraphs/encoding/redact"
+	"github.com/sraphs/encoding/schema"
+	"github.com/sraphs/encoding/secret"
+	"github.com/sraphs/encoding/validate"
 )
 
 // Name is the name registered for the json codec.
@@ -23,25 +34,87 @@ var (
 )
 
 // Codec is a Codec implementation with json.
-type Codec struct{}
+type Codec struct {
+	// Interpolate expands the ${VAR} references of the string values. See
+	// the interpolate package for the syntax.
+	Interpolate bool
+	// Lookup resolves the references, interpolate.Env if nil.
+	Lookup interpolate.LookupFunc
+	// Resolvers replaces the string values that are secret references, such
+	// as file:///run/secrets/db_password, by their content, base64 encoded
+	// for bytes fields. References are left as is if nil.
+	Resolvers secret.Resolvers
+	// Redact replaces the values of the sensitive fields, the struct fields
+	// tagged sensitive:"true" and the proto fields with the debug_redact
+	// option, by redact.Placeholder in the output of Marshal.
+	Redact bool
+	// Types resolves the google.protobuf.Any values and the extensions of
+	// proto messages, such as the types of a dynamic.Registry for dynamicpb
+	// messages, protoregistry.GlobalTypes if nil.
+	Types *protoregistry.Types
+	// Mask limits the output of Marshal for proto messages to the fields of
+	// its paths, such as server.port, all of them if nil.
+	Mask *fieldmaskpb.FieldMask
+	// Merge is how Unmarshal combines data with the fields of the proto
+	// message already set. If zero, the message is reset.
+	Merge merge.Mode
+	// Schema validates the documents read by Unmarshal before decoding them,
+	// failing with a *schema.Error telling the JSON Pointers and the lines
+	// and columns of the invalid values. No validation if nil.
+	Schema *schema.Validator
+	// Validate validates the values decoded by Unmarshal, such as with their
+	// Validate methods, failing with a *validate.Error. No validation if nil.
+	Validate *validate.Registry
+}
 
-func (Codec) Marshal(v interface{}) ([]byte, error) {
+func (c Codec) Marshal(v interface{}) ([]byte, error) {
+	if c.Redact {
+		v = redact.Copy(v)
+	}
 	switch m := v.(type) {
 	case json.Marshaler:
 		return m.MarshalJSON()
 	case proto.Message:
-		return MarshalOptions.Marshal(m)
+		if c.Mask != nil {
+			return c.marshalMasked(m)
+		}
+		return c.marshalOptions().Marshal(m)
 	default:
 		return json.Marshal(m)
 	}
 }
 
-func (Codec) Unmarshal(data []byte, v interface{}) error {
+func (c Codec) Unmarshal(data []byte, v interface{}) error {
+	if err := c.unmarshal(data, v); err != nil {
+		return err
+	}
+	return c.Validate.Validate(c.Name(), v)
+}
+
+func (c Codec) unmarshal(data []byte, v interface{}) error {
+	source := data
+	if c.Interpolate {
+		var err error
+		if data, err = interpolateJSON(data, c.Lookup); err != nil {
+			return err
+		}
+	}
+	if c.Resolvers != nil {
+		var err error
+		if data, err = resolveSecrets(data, v, c.Resolvers); err != nil {
+			return err
+		}
+	}
+	if c.Schema != nil {
+		if err := c.validateSchema(data, source); err != nil {
+			return err
+		}
+	}
 	switch m := v.(type) {
 	case json.Unmarshaler:
 		return m.UnmarshalJSON(data)
 	case proto.Message:
-		return UnmarshalOptions.Unmarshal(data, m)
+		return c.unmarshalProto(data, m)
 	default:
 		rv := reflect.ValueOf(v)
 		for rv := rv; rv.Kind() == reflect.Ptr; {
@@ -51,12 +124,112 @@ func (Codec) Unmarshal(data []byte, v interface{}) error {
 			rv = rv.Elem()
 		}
 		if m, ok := reflect.Indirect(rv).Interface().(proto.Message); ok {
-			return UnmarshalOptions.Unmarshal(data, m)
+			return c.unmarshalProto(data, m)
 		}
 		return json.Unmarshal(data, m)
 	}
 }
 
+// UnmarshalMask is Unmarshal for proto messages, also returning the mask of
+// the fields present in data, such as the fields to update of a PATCH
+// request.
+func (c Codec) UnmarshalMask(data []byte, m proto.Message) (*fieldmaskpb.FieldMask, error) {
+	if err := c.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	obj, _ := doc.(map[string]interface{})
+	return protopath.JSONMask(m.ProtoReflect().Descriptor(), obj), nil
+}
+
 func (Codec) Name() string {
 	return Name
 }
+
+// marshalMasked writes the fields of m masked by c.Mask.
+func (c Codec) marshalMasked(m proto.Message) ([]byte, error) {
+	md := m.ProtoReflect().Descriptor()
+	mask, err := protopath.NewMask(md, c.Mask)
+	if err != nil {
+		return nil, err
+	}
+	b, err := c.marshalOptions().Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var doc map[string]interface{}
+	if err := d.Decode(&doc); err != nil {
+		// well-known types are not objects, and have no fields to mask.
+		return b, nil
+	}
+	mask.FilterJSON(md, doc)
+	return json.Marshal(doc)
+}
+
+// unmarshalProto reads data into m according to c.Merge.
+func (c Codec) unmarshalProto(data []byte, m proto.Message) error {
+	return c.Merge.Decode(m, func(m proto.Message) error {
+		return c.unmarshalOptions().Unmarshal(data, m)
+	})
+}
+
+func (c Codec) marshalOptions() protojson.MarshalOptions {
+	opts := MarshalOptions
+	if c.Types != nil {
+		opts.Resolver = c.Types
+	}
+	return opts
+}
+
+func (c Codec) unmarshalOptions() protojson.UnmarshalOptions {
+	opts := UnmarshalOptions
+	if c.Types != nil {
+		opts.Resolver = c.Types
+	}
+	return opts
+}
+
+// interpolateJSON expands the references of the string values of the
+// document, leaving the object keys alone.
+func interpolateJSON(data []byte, lookup interpolate.LookupFunc) ([]byte, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var doc interface{}
+	if err := d.Decode(&doc); err != nil {
+		return nil, err
+	}
+	doc, err := interpolateValue(doc, lookup)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+func interpolateValue(v interface{}, lookup interpolate.LookupFunc) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		return interpolate.Expand(v, lookup)
+	case map[string]interface{}:
+		for k, e := range v {
+			e, err := interpolateValue(e, lookup)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = e
+		}
+	case []interface{}:
+		for i, e := range v {
+			e, err := interpolateValue(e, lookup)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = e
+		}
+	}
+	return v, nil
+}
@@ -2,11 +2,17 @@ package json
 
 import (
 	"encoding/json"
+	"errors"
 	"reflect"
 	"strings"
 	"testing"
 
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+
+	"github.com/sraphs/encoding/internal/testdata/complex"
 	testData "github.com/sraphs/encoding/internal/testdata/encoding"
+	"github.com/sraphs/encoding/schema"
+	"github.com/sraphs/encoding/secret"
 )
 
 type testEmbed struct {
@@ -145,3 +151,180 @@ func TestJSON_Unmarshal(t *testing.T) {
 		}
 	}
 }
+
+func TestJSON_Interpolate(t *testing.T) {
+	codec := Codec{Interpolate: true, Lookup: func(name string) (string, bool) {
+		if name == "NAME" {
+			return "sraph", true
+		}
+		return "", false
+	}}
+
+	var m testMessage
+	if err := codec.Unmarshal([]byte(`{"a":"${NAME}","b":"${MISSING:-b}","c":"$$NAME","embed":{"a":1}}`), &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Field1 != "sraph" || m.Field2 != "b" || m.Field3 != "$NAME" || m.Embed.Level1a != 1 {
+		t.Errorf("unexpected message: %+v", m)
+	}
+
+	p := &testData.TestModel{}
+	if err := codec.Unmarshal([]byte(`{"id":"1","name":"${NAME}","hobby":["$NAME"]}`), p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "sraph" || p.Id != 1 || !reflect.DeepEqual(p.Hobby, []string{"sraph"}) {
+		t.Errorf("unexpected proto message: %v", p)
+	}
+
+	if err := codec.Unmarshal([]byte(`{"a":"${MISSING:?a}"}`), &m); err == nil {
+		t.Error("expected an error for a missing required variable")
+	}
+}
+
+func TestJSON_Secrets(t *testing.T) {
+	codec := Codec{Resolvers: secret.Resolvers{
+		"secretref": secret.Memory{"password": []byte("hunter2"), "key": []byte{0, 1, 2}},
+	}}
+
+	var v struct {
+		Password string            `json:"password"`
+		Key      []byte            `json:"key"`
+		Keys     [][]byte          `json:"keys"`
+		Extra    map[string]string `json:"extra"`
+		Any      interface{}       `json:"any"`
+		Inner    *struct {
+			Token string
+		} `json:"inner"`
+	}
+	data := `{"password":"secretref://password","key":"secretref://key","keys":["secretref://key"],
+		"extra":{"a":"secretref://password"},"any":["secretref://password"],"inner":{"token":"secretref://password"}}`
+	if err := codec.Unmarshal([]byte(data), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Password != "hunter2" || !reflect.DeepEqual(v.Key, []byte{0, 1, 2}) || !reflect.DeepEqual(v.Keys, [][]byte{{0, 1, 2}}) {
+		t.Errorf("unexpected values: %+v", v)
+	}
+	if v.Extra["a"] != "hunter2" || !reflect.DeepEqual(v.Any, []interface{}{"hunter2"}) || v.Inner.Token != "hunter2" {
+		t.Errorf("unexpected nested values: %+v", v)
+	}
+
+	m := &complex.Complex{}
+	data = `{"numberOne":"secretref://password","byte":"secretref://key","bytes":"secretref://key",
+		"string":"secretref://password","simples":["secretref://password"],"map":{"k":"secretref://password"},
+		"very_simple":{"component":"secretref://password"}}`
+	if err := codec.Unmarshal([]byte(data), m); err != nil {
+		t.Fatal(err)
+	}
+	if m.NoOne != "hunter2" || !reflect.DeepEqual(m.Byte, []byte{0, 1, 2}) || !reflect.DeepEqual(m.Bytes.GetValue(), []byte{0, 1, 2}) {
+		t.Errorf("unexpected message: %v", m)
+	}
+	if m.String_.GetValue() != "hunter2" || m.Simples[0] != "hunter2" || m.Map["k"] != "hunter2" || m.Simple.Component != "hunter2" {
+		t.Errorf("unexpected nested message fields: %v", m)
+	}
+
+	if err := codec.Unmarshal([]byte(`{"password":"secretref://missing"}`), &v); err == nil {
+		t.Error("expected an error for a missing secret")
+	}
+}
+
+func TestJSON_Redact(t *testing.T) {
+	in := struct {
+		User     string `json:"user"`
+		Password string `json:"password" sensitive:"true"`
+		Port     int    `json:"port,omitempty" sensitive:"true"`
+	}{User: "sraph", Password: "hunter2", Port: 5432}
+
+	b, err := Codec{Redact: true}.Marshal(&in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"user":"sraph","password":"[REDACTED]"}`; string(b) != want {
+		t.Errorf("expected %s, got %s", want, b)
+	}
+	if in.Password != "hunter2" {
+		t.Errorf("the input was changed: %+v", in)
+	}
+}
+
+func TestJSON_Mask(t *testing.T) {
+	in := &complex.Complex{Id: 1, NoOne: "x", Simple: &complex.Simple{Component: "c"}, Age: 0}
+	c := Codec{Mask: &fieldmaskpb.FieldMask{Paths: []string{"simple.component", "age"}}}
+	b, err := c.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"age":0,"very_simple":{"component":"c"}}`; string(b) != want {
+		t.Errorf("expected %s, got %s", want, b)
+	}
+
+	if _, err := (Codec{Mask: &fieldmaskpb.FieldMask{Paths: []string{"unknown"}}}).Marshal(in); err == nil {
+		t.Error("expected an error for an unknown field")
+	}
+
+	out := &complex.Complex{Id: 2, Age: 3}
+	fm, err := Codec{}.UnmarshalMask([]byte(`{"age":0,"very_simple":{"component":"c"},"map":{"k":"v"},"x":1}`), out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"age", "map", "simple.component"}; !reflect.DeepEqual(want, fm.GetPaths()) {
+		t.Errorf("expected %v, got %v", want, fm.GetPaths())
+	}
+	if out.Id != 0 || out.Simple.GetComponent() != "c" {
+		t.Errorf("unexpected message %v", out)
+	}
+}
+
+func TestJSON_Schema(t *testing.T) {
+	v, err := schema.Compile([]byte(`{
+		"type": "object",
+		"properties": {"name": {"type": "string"}, "port": {"type": "integer", "minimum": 1}},
+		"required": ["name"]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := Codec{Schema: v}
+
+	var out map[string]interface{}
+	if err := c.Unmarshal([]byte(`{"name": "api", "port": 8080}`), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out["name"] != "api" {
+		t.Errorf("unexpected value %v", out)
+	}
+
+	out = nil
+	err = c.Unmarshal([]byte("{\n  \"tags\": [],\n  \"port\": 0\n}"), &out)
+	var serr *schema.Error
+	if !errors.As(err, &serr) {
+		t.Fatalf("expected a *schema.Error, got %v", err)
+	}
+	want := []schema.Violation{
+		{Pointer: "", Line: 1, Column: 1, Keyword: "/required"},
+		{Pointer: "/port", Line: 3, Column: 11, Keyword: "/properties/port/minimum"},
+	}
+	if len(serr.Violations) != len(want) {
+		t.Fatalf("expected %d violations, got %v", len(want), serr)
+	}
+	for i, w := range want {
+		got := serr.Violations[i]
+		got.Message = ""
+		if got != w {
+			t.Errorf("expected %+v, got %+v", w, serr.Violations[i])
+		}
+	}
+	if out != nil {
+		t.Errorf("an invalid document was decoded: %v", out)
+	}
+	if msg := err.Error(); !strings.HasPrefix(msg, "json: invalid document: (line 1, column 1): missing properties: 'name'; /port (line 3, column 11): ") {
+		t.Errorf("unexpected message %q", msg)
+	}
+
+	// the values are located in the input, before interpolation.
+	c.Interpolate = true
+	c.Lookup = func(string) (string, bool) { return "a long value", true }
+	err = c.Unmarshal([]byte("{\"name\": \"${NAME}\",\n\"port\": \"${PORT}\"}"), &out)
+	if !errors.As(err, &serr) || serr.Violations[0].Line != 2 || serr.Violations[0].Column != 9 {
+		t.Errorf("unexpected error %v", err)
+	}
+}
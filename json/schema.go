@@ -0,0 +1,82 @@
+package json
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+
+	"github.com/sraphs/encoding/schema"
+)
+
+// validateSchema validates the document data against c.Schema, locating
+// the invalid values in source, data before interpolation and resolution.
+func (c Codec) validateSchema(data, source []byte) error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var doc interface{}
+	if err := d.Decode(&doc); err != nil {
+		return err
+	}
+	var offsets map[string]int
+	return c.Schema.Validate(Name, doc, func(pointer string) (int, int) {
+		if offsets == nil {
+			offsets = valueOffsets(source)
+		}
+		offset, ok := offsets[pointer]
+		if !ok {
+			return 0, 0
+		}
+		return position(source, offset)
+	})
+}
+
+// valueOffsets returns the offsets in data of the values of the document,
+// keyed by JSON Pointer.
+func valueOffsets(data []byte) map[string]int {
+	offsets := make(map[string]int)
+	d := json.NewDecoder(bytes.NewReader(data))
+	var walk func(pointer string) error
+	walk = func(pointer string) error {
+		offset := int(d.InputOffset())
+		// skip the separators before the value.
+		for offset < len(data) && bytes.IndexByte([]byte(" \t\r\n:,"), data[offset]) >= 0 {
+			offset++
+		}
+		offsets[pointer] = offset
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'):
+			for d.More() {
+				key, err := d.Token()
+				if err != nil {
+					return err
+				}
+				if err := walk(pointer + "/" + schema.EscapeToken(key.(string))); err != nil {
+					return err
+				}
+			}
+			_, err = d.Token()
+		case json.Delim('['):
+			for i := 0; d.More(); i++ {
+				if err := walk(pointer + "/" + strconv.Itoa(i)); err != nil {
+					return err
+				}
+			}
+			_, err = d.Token()
+		}
+		return err
+	}
+	// the document is valid JSON already, the offsets read are complete.
+	_ = walk("")
+	return offsets
+}
+
+// position returns the line and the column of offset in data, starting at 1.
+// Columns count bytes.
+func position(data []byte, offset int) (int, int) {
+	line := 1 + bytes.Count(data[:offset], []byte("\n"))
+	return line, offset - bytes.LastIndexByte(data[:offset], '\n')
+}
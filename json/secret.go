@@ -0,0 +1,202 @@
+package json
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/sraphs/encoding/secret"
+)
+
+// resolveSecrets replaces the string values of the document that are secret
+// references by their content, base64 encoded where v expects bytes.
+func resolveSecrets(data []byte, v interface{}, rs secret.Resolvers) ([]byte, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var doc interface{}
+	if err := d.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	r := secretResolver{rs}
+	var err error
+	if m, ok := v.(proto.Message); ok {
+		doc, err = r.message(doc, m.ProtoReflect().Descriptor())
+	} else if m, ok := reflect.Indirect(reflect.ValueOf(v)).Interface().(proto.Message); ok {
+		doc, err = r.message(doc, m.ProtoReflect().Descriptor())
+	} else {
+		doc, err = r.value(doc, reflect.TypeOf(v))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+type secretResolver struct {
+	rs secret.Resolvers
+}
+
+// resolve returns the content referenced by s, base64 encoded if asBytes.
+func (r secretResolver) resolve(s string, asBytes bool) (interface{}, error) {
+	b, ok, err := r.rs.Resolve(s)
+	if err != nil || !ok {
+		return s, err
+	}
+	if asBytes {
+		return base64.StdEncoding.EncodeToString(b), nil
+	}
+	return string(b), nil
+}
+
+func (r secretResolver) message(doc interface{}, md protoreflect.MessageDescriptor) (interface{}, error) {
+	obj, ok := doc.(map[string]interface{})
+	if !ok {
+		return doc, nil
+	}
+	for k, e := range obj {
+		fd := md.Fields().ByJSONName(k)
+		if fd == nil {
+			fd = md.Fields().ByName(protoreflect.Name(k))
+		}
+		if fd == nil {
+			continue
+		}
+		var err error
+		switch {
+		case fd.IsMap():
+			if m, ok := e.(map[string]interface{}); ok {
+				for mk, me := range m {
+					if m[mk], err = r.field(me, fd.MapValue()); err != nil {
+						return nil, err
+					}
+				}
+			}
+		case fd.IsList():
+			if l, ok := e.([]interface{}); ok {
+				for i, le := range l {
+					if l[i], err = r.field(le, fd); err != nil {
+						return nil, err
+					}
+				}
+			}
+		default:
+			if obj[k], err = r.field(e, fd); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return obj, nil
+}
+
+// field resolves a single value of the field fd.
+func (r secretResolver) field(doc interface{}, fd protoreflect.FieldDescriptor) (interface{}, error) {
+	s, ok := doc.(string)
+	if !ok {
+		if md := fd.Message(); md != nil {
+			return r.message(doc, md)
+		}
+		return doc, nil
+	}
+	switch {
+	case fd.Kind() == protoreflect.StringKind:
+		return r.resolve(s, false)
+	case fd.Kind() == protoreflect.BytesKind:
+		return r.resolve(s, true)
+	case fd.Message() != nil && fd.Message().FullName() == "google.protobuf.StringValue":
+		return r.resolve(s, false)
+	case fd.Message() != nil && fd.Message().FullName() == "google.protobuf.BytesValue":
+		return r.resolve(s, true)
+	}
+	return doc, nil
+}
+
+// value resolves the document decoded into a value of type t, nil if unknown.
+func (r secretResolver) value(doc interface{}, t reflect.Type) (interface{}, error) {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	var err error
+	switch e := doc.(type) {
+	case string:
+		switch {
+		case t == nil || t.Kind() == reflect.String || t.Kind() == reflect.Interface:
+			return r.resolve(e, false)
+		case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+			return r.resolve(e, true)
+		}
+	case map[string]interface{}:
+		for k, v := range e {
+			var ft reflect.Type
+			switch {
+			case t == nil || t.Kind() == reflect.Interface:
+			case t.Kind() == reflect.Map:
+				ft = t.Elem()
+			case t.Kind() == reflect.Struct:
+				var ok bool
+				if ft, ok = jsonFieldType(t, k); !ok {
+					continue
+				}
+			default:
+				continue
+			}
+			if e[k], err = r.value(v, ft); err != nil {
+				return nil, err
+			}
+		}
+	case []interface{}:
+		var et reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			et = t.Elem()
+		} else if t != nil && t.Kind() != reflect.Interface {
+			return doc, nil
+		}
+		for i, v := range e {
+			if e[i], err = r.value(v, et); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return doc, nil
+}
+
+// jsonFieldType returns the type of the field of the struct t that
+// encoding/json decodes the key into.
+func jsonFieldType(t reflect.Type, key string) (reflect.Type, bool) {
+	var fold reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name := strings.Split(sf.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		ft := sf.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			if et, ok := jsonFieldType(ft, key); ok {
+				return et, true
+			}
+			continue
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		if name == key {
+			return sf.Type, true
+		}
+		if fold == nil && strings.EqualFold(name, key) {
+			fold = sf.Type
+		}
+	}
+	return fold, fold != nil
+}
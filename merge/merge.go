@@ -0,0 +1,102 @@
+// Package merge defines how the codecs decode into proto messages that
+// already have fields set, such as a configuration read from several layers
+// of files, environment variables and flags.
+//
+// Without a mode each codec keeps its own behavior: json, toml, proto and
+// prototext reset the message, and so does yaml in the protojson form, while
+// env, flag, form, multipart, cbor and msgpack set the fields of the input,
+// appending to lists, yaml sets them in the Go structs of the generated
+// messages, replacing lists, and csv appends the rows to the repeated message
+// field. A mode makes the result the
+// same whatever the codec. Go structs are decoded as before.
+package merge
+
+import (
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Mode is how Unmarshal combines the decoded input with the message.
+type Mode int
+
+const (
+	// Default keeps the behavior of the codec.
+	Default Mode = iota
+	// Replace resets the message, which only holds the input afterwards.
+	Replace
+	// Proto merges the input into the message as proto.Merge does: the
+	// fields set in the input overwrite the scalars, lists are appended to,
+	// maps are merged by key and messages are merged recursively.
+	Proto
+	// Overlay is Proto replacing the lists instead of appending to them,
+	// and replacing the well-known types, such as Timestamp, as a whole.
+	Overlay
+)
+
+// Decode decodes into m with decode, combining the input with the fields of
+// m already set according to mode. decode is given a new empty message of
+// the type of m, or m itself with Default.
+func (mode Mode) Decode(m proto.Message, decode func(proto.Message) error) error {
+	if mode == Default {
+		return decode(m)
+	}
+	in := m.ProtoReflect().New().Interface()
+	if err := decode(in); err != nil {
+		return err
+	}
+	switch mode {
+	case Replace:
+		proto.Reset(m)
+		proto.Merge(m, in)
+	case Overlay:
+		overlay(m.ProtoReflect(), in.ProtoReflect())
+	default:
+		proto.Merge(m, in)
+	}
+	return nil
+}
+
+// overlay sets the fields of src in dst, replacing its lists.
+func overlay(dst, src protoreflect.Message) {
+	src.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsList():
+			dst.Clear(fd)
+			list, in := dst.Mutable(fd).List(), v.List()
+			for i := 0; i < in.Len(); i++ {
+				list.Append(copyValue(in.Get(i), list.NewElement))
+			}
+		case fd.IsMap():
+			m := dst.Mutable(fd).Map()
+			v.Map().Range(func(k protoreflect.MapKey, mv protoreflect.Value) bool {
+				m.Set(k, copyValue(mv, m.NewValue))
+				return true
+			})
+		case fd.Message() != nil && !isWellKnown(fd.Message()):
+			overlay(dst.Mutable(fd).Message(), v.Message())
+		default:
+			dst.Set(fd, copyValue(v, func() protoreflect.Value { return dst.NewField(fd) }))
+		}
+		return true
+	})
+}
+
+// copyValue returns a copy of v, using newValue for messages.
+func copyValue(v protoreflect.Value, newValue func() protoreflect.Value) protoreflect.Value {
+	switch x := v.Interface().(type) {
+	case protoreflect.Message:
+		nv := newValue()
+		proto.Merge(nv.Message().Interface(), x.Interface())
+		return nv
+	case []byte:
+		return protoreflect.ValueOfBytes(append([]byte(nil), x...))
+	default:
+		return v
+	}
+}
+
+// isWellKnown reports whether md is one of the well-known types, which have
+// a single value.
+func isWellKnown(md protoreflect.MessageDescriptor) bool {
+	return md.ParentFile().Package() == "google.protobuf" && md.FullName() != "google.protobuf.Struct"
+}
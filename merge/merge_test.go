@@ -0,0 +1,151 @@
+package merge_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/sraphs/encoding/cbor"
+	"github.com/sraphs/encoding/csv"
+	"github.com/sraphs/encoding/env"
+	"github.com/sraphs/encoding/flag"
+	"github.com/sraphs/encoding/form"
+	"github.com/sraphs/encoding/internal/testdata/complex"
+	"github.com/sraphs/encoding/json"
+	"github.com/sraphs/encoding/merge"
+	"github.com/sraphs/encoding/msgpack"
+	eproto "github.com/sraphs/encoding/proto"
+	"github.com/sraphs/encoding/prototext"
+	"github.com/sraphs/encoding/toml"
+	"github.com/sraphs/encoding/yaml"
+)
+
+type codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+func base() *complex.Complex {
+	return &complex.Complex{
+		Id:        1,
+		NoOne:     "base",
+		Simple:    &complex.Simple{Component: "c"},
+		Simples:   []string{"a"},
+		Timestamp: &timestamppb.Timestamp{Seconds: 1, Nanos: 5},
+		Map:       map[string]string{"k1": "v1", "k2": "v2"},
+	}
+}
+
+// layer is the message of the inputs of TestModes.
+func layer() *complex.Complex {
+	return &complex.Complex{
+		Id:        2,
+		Simples:   []string{"b"},
+		Timestamp: &timestamppb.Timestamp{Seconds: 2},
+		Map:       map[string]string{"k2": "x"},
+	}
+}
+
+func TestModes(t *testing.T) {
+	want := map[merge.Mode]*complex.Complex{
+		merge.Replace: layer(),
+		merge.Proto: {
+			Id:        2,
+			NoOne:     "base",
+			Simple:    &complex.Simple{Component: "c"},
+			Simples:   []string{"a", "b"},
+			Timestamp: &timestamppb.Timestamp{Seconds: 2, Nanos: 5},
+			Map:       map[string]string{"k1": "v1", "k2": "x"},
+		},
+		merge.Overlay: {
+			Id:        2,
+			NoOne:     "base",
+			Simple:    &complex.Simple{Component: "c"},
+			Simples:   []string{"b"},
+			Timestamp: &timestamppb.Timestamp{Seconds: 2},
+			Map:       map[string]string{"k1": "v1", "k2": "x"},
+		},
+	}
+
+	for name, tt := range map[string]struct {
+		codec func(merge.Mode) codec
+		input string
+	}{
+		"json": {func(m merge.Mode) codec { return json.Codec{Merge: m} },
+			`{"id":"2","simples":["b"],"map":{"k2":"x"},"timestamp":"1970-01-01T00:00:02Z"}`},
+		"yaml": {func(m merge.Mode) codec { return yaml.Codec{Merge: m} },
+			"id: 2\nsimples: [b]\nmap:\n  k2: x\ntimestamp: {seconds: 2}\n"},
+		"yaml protojson": {func(m merge.Mode) codec { return yaml.Codec{Merge: m, ProtoJSON: true} },
+			"id: 2\nsimples: [b]\nmap:\n  k2: x\ntimestamp: 1970-01-01T00:00:02Z\n"},
+		"toml": {func(m merge.Mode) codec { return toml.Codec{Merge: m} },
+			"id = 2\nsimples = [\"b\"]\ntimestamp = \"1970-01-01T00:00:02Z\"\n\n[map]\nk2 = \"x\"\n"},
+		"prototext": {func(m merge.Mode) codec { return prototext.Codec{Merge: m} },
+			`id: 2 simples: "b" map {key: "k2" value: "x"} timestamp {seconds: 2}`},
+		"env": {func(m merge.Mode) codec { return env.Codec{Merge: m} },
+			"ID=2\nSIMPLES=b\nMAP_k2=x\nTIMESTAMP=1970-01-01T00:00:02Z"},
+		"flag": {func(m merge.Mode) codec { return flag.Codec{Merge: m} },
+			"--id=2 --simples=b --map.k2=x --timestamp=1970-01-01T00:00:02Z"},
+		"form": {func(m merge.Mode) codec { return form.Codec{Merge: m} },
+			"id=2&simples=b&map[k2]=x&timestamp=1970-01-01T00:00:02Z"},
+		"multipart": {codec: func(m merge.Mode) codec { return form.MultipartCodec{Merge: m} }},
+		"proto":     {codec: func(m merge.Mode) codec { return eproto.Codec{Merge: m} }},
+		"cbor":      {codec: func(m merge.Mode) codec { return cbor.Codec{Merge: m} }},
+		"msgpack":   {codec: func(m merge.Mode) codec { return msgpack.Codec{Merge: m} }},
+	} {
+		t.Run(name, func(t *testing.T) {
+			input := []byte(tt.input)
+			if tt.input == "" {
+				var err error
+				input, err = tt.codec(merge.Default).Marshal(layer())
+				require.NoError(t, err)
+			}
+			for mode, want := range want {
+				got := base()
+				require.NoError(t, tt.codec(mode).Unmarshal(input, got))
+				assert.True(t, proto.Equal(want, got), "mode %d: got %v", mode, got)
+			}
+		})
+	}
+
+	// csv decodes the rows into the only repeated message field.
+	t.Run("csv", func(t *testing.T) {
+		files := func(names ...string) *descriptorpb.FileDescriptorSet {
+			set := &descriptorpb.FileDescriptorSet{}
+			for _, name := range names {
+				set.File = append(set.File, &descriptorpb.FileDescriptorProto{Name: proto.String(name)})
+			}
+			return set
+		}
+		want := map[merge.Mode]*descriptorpb.FileDescriptorSet{
+			merge.Default: files("a.proto", "b.proto"),
+			merge.Replace: files("b.proto"),
+			merge.Proto:   files("a.proto", "b.proto"),
+			merge.Overlay: files("b.proto"),
+		}
+		for mode, want := range want {
+			got := files("a.proto")
+			require.NoError(t, csv.Codec{Merge: mode}.Unmarshal([]byte("name\nb.proto\n"), got))
+			assert.True(t, proto.Equal(want, got), "mode %d: got %v", mode, got)
+		}
+	})
+}
+
+func TestDecode(t *testing.T) {
+	got := base()
+	err := merge.Overlay.Decode(got, func(m proto.Message) error {
+		m.(*complex.Complex).NoOne = "other"
+		return assert.AnError
+	})
+	assert.Equal(t, assert.AnError, err)
+	assert.True(t, proto.Equal(base(), got), "the message is left as is on error")
+
+	err = merge.Default.Decode(got, func(m proto.Message) error {
+		assert.Same(t, got, m)
+		return nil
+	})
+	assert.NoError(t, err)
+}
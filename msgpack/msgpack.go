@@ -0,0 +1,150 @@
+// Package msgpack defines the MessagePack codec.
+package msgpack
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/sraphs/encoding/internal/protomap"
+	"github.com/sraphs/encoding/merge"
+	"github.com/sraphs/encoding/validate"
+)
+
+// Name is the name registered for the msgpack codec.
+const Name = "msgpack"
+
+// Codec is a Codec implementation with MessagePack. Go structs are mapped
+// using their msgpack struct tags, proto messages are mapped to maps keyed by
+// JSON name and timestamps use the MessagePack timestamp extension.
+type Codec struct {
+	// UseProtoNames keys proto message fields by their proto name instead of their JSON name.
+	UseProtoNames bool
+	// UseFieldNumbers keys proto message fields by their field number.
+	UseFieldNumbers bool
+	// Merge is how Unmarshal combines data with the fields of the proto
+	// message already set. If zero, the fields of data are set in the
+	// message, appending to its lists.
+	Merge merge.Mode
+	// Validate validates the values decoded by Unmarshal, such as with their
+	// Validate methods, failing with a *validate.Error. No validation if nil.
+	Validate *validate.Registry
+}
+
+func (c Codec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.UseProtoNames(c.UseProtoNames)
+	enc.UseFieldNumbers(c.UseFieldNumbers)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c Codec) Unmarshal(data []byte, v interface{}) error {
+	if err := c.unmarshal(data, v); err != nil {
+		return err
+	}
+	return c.Validate.Validate(c.Name(), v)
+}
+
+func (c Codec) unmarshal(data []byte, v interface{}) error {
+	dec := NewDecoder(bytes.NewReader(data))
+	dec.Merge(c.Merge)
+	return dec.Decode(v)
+}
+
+func (Codec) Name() string {
+	return Name
+}
+
+// Encoder writes MessagePack values to an output stream.
+type Encoder struct {
+	enc  *msgpack.Encoder
+	opts protomap.Options
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{enc: msgpack.NewEncoder(w)}
+}
+
+// UseProtoNames causes the Encoder to key proto message fields by their proto name.
+func (e *Encoder) UseProtoNames(on bool) {
+	e.opts.UseProtoNames = on
+}
+
+// UseFieldNumbers causes the Encoder to key proto message fields by their field number.
+func (e *Encoder) UseFieldNumbers(on bool) {
+	e.opts.UseFieldNumbers = on
+}
+
+// Encode writes the MessagePack encoding of v to the stream.
+func (e *Encoder) Encode(v interface{}) error {
+	if m, ok := v.(proto.Message); ok {
+		pv, err := e.opts.Marshal(m.ProtoReflect())
+		if err != nil {
+			return err
+		}
+		return e.enc.Encode(pv)
+	}
+	return e.enc.Encode(v)
+}
+
+// Decoder reads MessagePack values from an input stream.
+type Decoder struct {
+	dec  *msgpack.Decoder
+	mode merge.Mode
+}
+
+// NewDecoder returns a new decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{dec: msgpack.NewDecoder(r)}
+}
+
+// Merge sets how the Decoder combines the decoded values with the fields of
+// the proto messages already set.
+func (d *Decoder) Merge(mode merge.Mode) {
+	d.mode = mode
+}
+
+// Decode reads the next MessagePack value from its input and stores it in v.
+// Proto message fields may be keyed by JSON name, proto name or field number.
+func (d *Decoder) Decode(v interface{}) error {
+	if m, ok := v.(proto.Message); ok {
+		return d.decodeProto(m)
+	}
+	rv := reflect.ValueOf(v)
+	for rv := rv; rv.Kind() == reflect.Ptr; {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		rv = rv.Elem()
+	}
+	if m, ok := reflect.Indirect(rv).Interface().(proto.Message); ok {
+		return d.decodeProto(m)
+	}
+	return d.dec.Decode(v)
+}
+
+func (d *Decoder) decodeProto(m proto.Message) error {
+	// field numbers are not valid keys of the default map[string]interface{}.
+	d.dec.SetMapDecoder(untypedMap)
+	defer d.dec.SetMapDecoder(nil)
+
+	pv, err := d.dec.DecodeInterface()
+	if err != nil {
+		return err
+	}
+	return d.mode.Decode(m, func(m proto.Message) error {
+		return protomap.Options{}.Unmarshal(pv, m.ProtoReflect())
+	})
+}
+
+func untypedMap(d *msgpack.Decoder) (interface{}, error) {
+	return d.DecodeUntypedMap()
+}
@@ -0,0 +1,124 @@
+package msgpack
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	testData "github.com/sraphs/encoding/internal/testdata/complex"
+)
+
+type testMessage struct {
+	Name    string    `msgpack:"name"`
+	Tags    []string  `msgpack:"tags"`
+	Created time.Time `msgpack:"created"`
+}
+
+func newComplex() *testData.Complex {
+	return &testData.Complex{
+		Id:        2233,
+		NoOne:     "2233",
+		Simple:    &testData.Simple{Component: "5566"},
+		Simples:   []string{"3344", "5566"},
+		B:         true,
+		Sex:       testData.Sex_woman,
+		Age:       18,
+		A:         19,
+		Count:     3,
+		Price:     11.23,
+		D:         22.22,
+		Byte:      []byte("123"),
+		Map:       map[string]string{"sraph": "https://sraph.com/"},
+		Timestamp: &timestamppb.Timestamp{Seconds: 20, Nanos: 2},
+		Duration:  &durationpb.Duration{Seconds: 120, Nanos: 22},
+		Field:     &fieldmaskpb.FieldMask{Paths: []string{"a.b", "b.c"}},
+		Double:    &wrapperspb.DoubleValue{Value: 12.33},
+		Int64:     &wrapperspb.Int64Value{Value: 64},
+		Uint32:    &wrapperspb.UInt32Value{Value: 32},
+		Bool:      &wrapperspb.BoolValue{Value: false},
+		String_:   &wrapperspb.StringValue{Value: "sraph"},
+		Bytes:     &wrapperspb.BytesValue{Value: []byte("123")},
+	}
+}
+
+func TestCodec_Struct(t *testing.T) {
+	in := &testMessage{Name: "sraph", Tags: []string{"a", "b"}, Created: time.Unix(20, 2).UTC()}
+
+	b, err := (Codec{}).Marshal(in)
+	require.NoError(t, err)
+
+	out := &testMessage{}
+	require.NoError(t, (Codec{}).Unmarshal(b, out))
+	assert.Equal(t, in.Name, out.Name)
+	assert.Equal(t, in.Tags, out.Tags)
+	assert.True(t, in.Created.Equal(out.Created))
+}
+
+func TestCodec_Proto(t *testing.T) {
+	tests := []struct {
+		name  string
+		codec Codec
+		key   interface{}
+	}{
+		{name: "json names", codec: Codec{}, key: "numberOne"},
+		{name: "proto names", codec: Codec{UseProtoNames: true}, key: "no_one"},
+		{name: "field numbers", codec: Codec{UseFieldNumbers: true}, key: int8(2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := newComplex()
+			b, err := tt.codec.Marshal(in)
+			require.NoError(t, err)
+
+			var raw map[interface{}]interface{}
+			dec := msgpack.NewDecoder(bytes.NewReader(b))
+			dec.SetMapDecoder(func(d *msgpack.Decoder) (interface{}, error) {
+				return d.DecodeUntypedMap()
+			})
+			require.NoError(t, dec.Decode(&raw))
+			assert.Equal(t, "2233", raw[tt.key])
+
+			out := &testData.Complex{}
+			require.NoError(t, tt.codec.Unmarshal(b, out))
+			assert.True(t, proto.Equal(in, out), "got %v", out)
+		})
+	}
+}
+
+func TestCodec_Timestamp(t *testing.T) {
+	b, err := (Codec{}).Marshal(&testData.Complex{Timestamp: &timestamppb.Timestamp{Seconds: 20}})
+	require.NoError(t, err)
+	// fixext 4 with the timestamp extension type -1.
+	assert.True(t, bytes.Contains(b, []byte{0xd6, 0xff, 0, 0, 0, 20}), "got %x", b)
+
+	var m map[string]interface{}
+	require.NoError(t, (Codec{}).Unmarshal(b, &m))
+	assert.Equal(t, int64(20), m["timestamp"].(time.Time).Unix())
+}
+
+func TestEncoderDecoder(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, enc.Encode(&testData.Complex{Id: int64(i), Simples: []string{"a"}}))
+	}
+
+	dec := NewDecoder(&buf)
+	for i := 0; i < 3; i++ {
+		out := &testData.Complex{}
+		require.NoError(t, dec.Decode(out))
+		assert.Equal(t, int64(i), out.Id)
+		assert.Equal(t, []string{"a"}, out.Simples)
+	}
+	assert.Equal(t, io.EOF, dec.Decode(&testData.Complex{}))
+}
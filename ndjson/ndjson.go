@@ -0,0 +1,150 @@
+// Package ndjson defines the newline delimited JSON (JSON Lines) codec.
+package ndjson
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/sraphs/encoding/json"
+	"github.com/sraphs/encoding/validate"
+)
+
+// Name is the name registered for the ndjson codec.
+const Name = "x-ndjson"
+
+// Codec is a Codec implementation with newline delimited JSON. Every element
+// is encoded with the json codec, so proto messages use protojson.
+type Codec struct {
+	// Validate validates the values decoded by Unmarshal, such as with their
+	// Validate methods, failing with a *validate.Error. No validation if nil.
+	Validate *validate.Registry
+}
+
+// Marshal writes one line per element of v, which must be a slice, an array
+// or a channel. The channel is read until it is closed.
+func (Codec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if err := w.Write(rv.Index(i).Interface()); err != nil {
+				return nil, err
+			}
+		}
+	case reflect.Chan:
+		for {
+			e, ok := rv.Recv()
+			if !ok {
+				break
+			}
+			if err := w.Write(e.Interface()); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		if err := w.Write(v); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal appends one element per line of data to the slice v points to.
+func (c Codec) Unmarshal(data []byte, v interface{}) error {
+	if err := c.unmarshal(data, v); err != nil {
+		return err
+	}
+	return c.Validate.Validate(c.Name(), v)
+}
+
+func (Codec) unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("ndjson: cannot unmarshal into %T, want a pointer to a slice", v)
+	}
+	slice := rv.Elem()
+	r := NewReader(bytes.NewReader(data))
+	for {
+		ev := reflect.New(slice.Type().Elem())
+		if err := r.Read(ev.Interface()); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		slice.Set(reflect.Append(slice, ev.Elem()))
+	}
+}
+
+func (Codec) Name() string {
+	return Name
+}
+
+// Writer writes values as newline delimited JSON.
+type Writer struct {
+	w io.Writer
+}
+
+// NewWriter returns a Writer writing to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Write writes v on its own line.
+func (w *Writer) Write(v interface{}) error {
+	b, err := json.Codec{}.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if bytes.ContainsAny(b, "\r\n") {
+		return errors.New("ndjson: encoded value contains a line break")
+	}
+	_, err = w.w.Write(append(b, '\n'))
+	return err
+}
+
+// Reader reads values from newline delimited JSON, one line at a time.
+type Reader struct {
+	r    *bufio.Reader
+	line int
+}
+
+// NewReader returns a Reader reading from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Read decodes the next non-empty line into v. It returns io.EOF when no
+// lines are left; decoding errors report the line number.
+func (r *Reader) Read(v interface{}) error {
+	for {
+		line, err := r.r.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			return err
+		}
+		r.line++
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if derr := (json.Codec{}).Unmarshal(line, v); derr != nil {
+			return fmt.Errorf("ndjson: line %d: %w", r.line, derr)
+		}
+		return nil
+	}
+}
+
+// Line returns the number of the last line read.
+func (r *Reader) Line() int {
+	return r.line
+}
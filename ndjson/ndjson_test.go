@@ -0,0 +1,75 @@
+package ndjson
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	testData "github.com/sraphs/encoding/internal/testdata/encoding"
+)
+
+type event struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestCodec_Struct(t *testing.T) {
+	in := []event{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}}
+
+	b, err := (Codec{}).Marshal(in)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"id\":1,\"name\":\"a\"}\n{\"id\":2,\"name\":\"b\"}\n", string(b))
+
+	var out []event
+	require.NoError(t, (Codec{}).Unmarshal(b, &out))
+	assert.Equal(t, in, out)
+}
+
+func TestCodec_Channel(t *testing.T) {
+	ch := make(chan *testData.TestModel, 2)
+	ch <- &testData.TestModel{Id: 1, Name: "sraph"}
+	ch <- &testData.TestModel{Id: 2, Hobby: []string{"eat"}}
+	close(ch)
+
+	b, err := (Codec{}).Marshal(ch)
+	require.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(string(b), "\n"))
+
+	var out []*testData.TestModel
+	require.NoError(t, (Codec{}).Unmarshal(b, &out))
+	require.Len(t, out, 2)
+	assert.True(t, proto.Equal(&testData.TestModel{Id: 1, Name: "sraph", Attrs: map[string]string{}}, out[0]), "got %v", out[0])
+	assert.Equal(t, []string{"eat"}, out[1].Hobby)
+}
+
+func TestReader(t *testing.T) {
+	r := NewReader(strings.NewReader("{\"id\":\"1\"}\n\n{\"id\":\"2\"}\r\n{\"id\":x}\n{\"id\":\"4\"}"))
+
+	var m testData.TestModel
+	require.NoError(t, r.Read(&m))
+	assert.Equal(t, int64(1), m.Id)
+	require.NoError(t, r.Read(&m))
+	assert.Equal(t, int64(2), m.Id)
+	assert.Equal(t, 3, r.Line())
+
+	err := r.Read(&m)
+	require.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "ndjson: line 4: "), err.Error())
+
+	require.NoError(t, r.Read(&m))
+	assert.Equal(t, int64(4), m.Id)
+	assert.Equal(t, io.EOF, r.Read(&m))
+}
+
+func TestCodec_UnmarshalError(t *testing.T) {
+	var out []event
+	err := (Codec{}).Unmarshal([]byte("{\"id\":1}\n{\"id\":\"x\"}\n"), &out)
+	require.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "ndjson: line 2: "), err.Error())
+
+	assert.Error(t, (Codec{}).Unmarshal([]byte("{}"), out))
+}
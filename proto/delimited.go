@@ -0,0 +1,119 @@
+package proto
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// DefaultMaxMessageSize is the default upper bound for a single message read
+// by a DelimitedReader or FrameReader.
+const DefaultMaxMessageSize = 4 << 20
+
+// ErrMessageTooLarge is returned when a message exceeds the configured maximum size.
+var ErrMessageTooLarge = errors.New("proto: message too large")
+
+// DelimitedWriter writes messages prefixed with their varint encoded length.
+// The format is compatible with Java's writeDelimitedTo and protodelim.
+type DelimitedWriter struct {
+	w   io.Writer
+	buf []byte
+}
+
+// NewDelimitedWriter returns a DelimitedWriter writing to w.
+func NewDelimitedWriter(w io.Writer) *DelimitedWriter {
+	return &DelimitedWriter{w: w}
+}
+
+// WriteMsg writes the length-delimited wire format of m.
+func (d *DelimitedWriter) WriteMsg(m proto.Message) error {
+	b, err := proto.MarshalOptions{}.MarshalAppend(d.buf[:0], m)
+	if err != nil {
+		return err
+	}
+	d.buf = b
+
+	var hdr [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(hdr[:], uint64(len(b)))
+	if _, err := d.w.Write(hdr[:n]); err != nil {
+		return err
+	}
+	_, err = d.w.Write(b)
+	return err
+}
+
+// DelimitedReader reads messages prefixed with their varint encoded length.
+type DelimitedReader struct {
+	r       *bufio.Reader
+	maxSize int
+	buf     []byte
+}
+
+// NewDelimitedReader returns a DelimitedReader reading from r. Messages larger
+// than maxSize bytes are rejected with ErrMessageTooLarge; a maxSize <= 0 uses
+// DefaultMaxMessageSize.
+func NewDelimitedReader(r io.Reader, maxSize int) *DelimitedReader {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxMessageSize
+	}
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &DelimitedReader{r: br, maxSize: maxSize}
+}
+
+// ReadMsg reads the next message into m. It returns io.EOF when no more
+// messages are available and io.ErrUnexpectedEOF on a truncated message.
+func (d *DelimitedReader) ReadMsg(m proto.Message) error {
+	size, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return err
+	}
+	if size > uint64(d.maxSize) {
+		return fmt.Errorf("%w: %d > %d", ErrMessageTooLarge, size, d.maxSize)
+	}
+	if cap(d.buf) < int(size) {
+		d.buf = make([]byte, size)
+	}
+	d.buf = d.buf[:size]
+	if _, err := io.ReadFull(d.r, d.buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return proto.Unmarshal(d.buf, m)
+}
+
+// MarshalDelimited returns the length-delimited wire format of m.
+func MarshalDelimited(m proto.Message) ([]byte, error) {
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	out := protowire.AppendVarint(make([]byte, 0, protowire.SizeVarint(uint64(len(b)))+len(b)), uint64(len(b)))
+	return append(out, b...), nil
+}
+
+// UnmarshalDelimited parses the first length-delimited message in data into m
+// and returns the number of bytes consumed.
+func UnmarshalDelimited(data []byte, m proto.Message) (int, error) {
+	size, n := protowire.ConsumeVarint(data)
+	if n < 0 {
+		return 0, protowire.ParseError(n)
+	}
+	if size > uint64(len(data)-n) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	end := n + int(size)
+	if err := proto.Unmarshal(data[n:end], m); err != nil {
+		return 0, err
+	}
+	return end, nil
+}
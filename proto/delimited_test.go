@@ -0,0 +1,92 @@
+package proto
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+
+	testData "github.com/sraphs/encoding/internal/testdata/encoding"
+)
+
+func TestDelimited(t *testing.T) {
+	models := []*testData.TestModel{
+		{Id: 1, Name: "sraph", Hobby: []string{"study"}},
+		{},
+		{Id: 3, Name: "kratos", Attrs: map[string]string{"a": "b"}},
+	}
+
+	var buf bytes.Buffer
+	w := NewDelimitedWriter(&buf)
+	for _, m := range models {
+		if err := w.WriteMsg(m); err != nil {
+			t.Fatalf("WriteMsg() should be nil, but got %s", err)
+		}
+	}
+
+	// the first message must be readable with the plain varint framing.
+	size, n := protowire.ConsumeVarint(buf.Bytes())
+	var first testData.TestModel
+	if err := proto.Unmarshal(buf.Bytes()[n:n+int(size)], &first); err != nil {
+		t.Fatalf("Unmarshal() should be nil, but got %s", err)
+	}
+	if !proto.Equal(&first, models[0]) {
+		t.Errorf("first message should be %v, but got %v", models[0], &first)
+	}
+
+	r := NewDelimitedReader(&buf, 0)
+	for i, want := range models {
+		var got testData.TestModel
+		if err := r.ReadMsg(&got); err != nil {
+			t.Fatalf("ReadMsg() #%d should be nil, but got %s", i, err)
+		}
+		if !proto.Equal(&got, want) {
+			t.Errorf("message #%d should be %v, but got %v", i, want, &got)
+		}
+	}
+	if err := r.ReadMsg(&testData.TestModel{}); err != io.EOF {
+		t.Errorf("ReadMsg() should be io.EOF, but got %v", err)
+	}
+}
+
+func TestDelimitedMaxSize(t *testing.T) {
+	b, err := MarshalDelimited(&testData.TestModel{Name: "a long enough name"})
+	if err != nil {
+		t.Fatalf("MarshalDelimited() should be nil, but got %s", err)
+	}
+
+	err = NewDelimitedReader(bytes.NewReader(b), 4).ReadMsg(&testData.TestModel{})
+	if !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("ReadMsg() should be ErrMessageTooLarge, but got %v", err)
+	}
+
+	err = NewDelimitedReader(bytes.NewReader(b[:len(b)-1]), 0).ReadMsg(&testData.TestModel{})
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("ReadMsg() should be io.ErrUnexpectedEOF, but got %v", err)
+	}
+}
+
+func TestMarshalDelimited(t *testing.T) {
+	in := &testData.TestModel{Id: 1, Name: "sraph"}
+	b, err := MarshalDelimited(in)
+	if err != nil {
+		t.Fatalf("MarshalDelimited() should be nil, but got %s", err)
+	}
+	b = append(b, 0xff)
+
+	var out testData.TestModel
+	n, err := UnmarshalDelimited(b, &out)
+	if err != nil {
+		t.Fatalf("UnmarshalDelimited() should be nil, but got %s", err)
+	}
+	if n != len(b)-1 {
+		t.Errorf("consumed should be %d, but got %d", len(b)-1, n)
+	}
+	if !reflect.DeepEqual(out.Name, in.Name) {
+		t.Errorf("Name should be %s, but got %s", in.Name, out.Name)
+	}
+}
@@ -0,0 +1,145 @@
+package proto
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// frameHeaderLen is the size of the gRPC style frame header: one byte of
+// compression flag followed by a four bytes big-endian length.
+const frameHeaderLen = 5
+
+// ErrNoCompressor is returned when a compressed frame is read without a Compressor.
+var ErrNoCompressor = errors.New("proto: compressed frame received but no compressor configured")
+
+// Compressor compresses and decompresses frame payloads.
+type Compressor interface {
+	Compress(w io.Writer) (io.WriteCloser, error)
+	Decompress(r io.Reader) (io.Reader, error)
+}
+
+// GzipCompressor is a Compressor implementation with gzip.
+type GzipCompressor struct{}
+
+func (GzipCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(w), nil
+}
+
+func (GzipCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	return gzip.NewReader(r)
+}
+
+// FrameWriter writes messages using the gRPC length-prefixed message framing.
+type FrameWriter struct {
+	w io.Writer
+	// Compressor, when set, compresses every payload and sets the compression flag.
+	Compressor Compressor
+}
+
+// NewFrameWriter returns a FrameWriter writing to w.
+func NewFrameWriter(w io.Writer) *FrameWriter {
+	return &FrameWriter{w: w}
+}
+
+// WriteMsg writes m as a single frame.
+func (f *FrameWriter) WriteMsg(m proto.Message) error {
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	var flag byte
+	if f.Compressor != nil {
+		var buf bytes.Buffer
+		cw, err := f.Compressor.Compress(&buf)
+		if err != nil {
+			return err
+		}
+		if _, err := cw.Write(b); err != nil {
+			return err
+		}
+		if err := cw.Close(); err != nil {
+			return err
+		}
+		b, flag = buf.Bytes(), 1
+	}
+	if uint64(len(b)) > uint64(^uint32(0)) {
+		return fmt.Errorf("%w: %d", ErrMessageTooLarge, len(b))
+	}
+
+	var hdr [frameHeaderLen]byte
+	hdr[0] = flag
+	binary.BigEndian.PutUint32(hdr[1:], uint32(len(b)))
+	if _, err := f.w.Write(hdr[:]); err != nil {
+		return err
+	}
+	_, err = f.w.Write(b)
+	return err
+}
+
+// FrameReader reads messages using the gRPC length-prefixed message framing.
+type FrameReader struct {
+	r       io.Reader
+	maxSize int
+	// Compressor is used to decompress frames with the compression flag set.
+	Compressor Compressor
+}
+
+// NewFrameReader returns a FrameReader reading from r. Frames larger than
+// maxSize bytes, before or after decompression, are rejected with
+// ErrMessageTooLarge; a maxSize <= 0 uses DefaultMaxMessageSize.
+func NewFrameReader(r io.Reader, maxSize int) *FrameReader {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxMessageSize
+	}
+	return &FrameReader{r: r, maxSize: maxSize}
+}
+
+// ReadMsg reads the next frame into m. It returns io.EOF when no more frames
+// are available and io.ErrUnexpectedEOF on a truncated frame.
+func (f *FrameReader) ReadMsg(m proto.Message) error {
+	var hdr [frameHeaderLen]byte
+	if _, err := io.ReadFull(f.r, hdr[:]); err != nil {
+		return err
+	}
+	size := binary.BigEndian.Uint32(hdr[1:])
+	if uint64(size) > uint64(f.maxSize) {
+		return fmt.Errorf("%w: %d > %d", ErrMessageTooLarge, size, f.maxSize)
+	}
+	b := make([]byte, size)
+	if _, err := io.ReadFull(f.r, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+
+	switch hdr[0] {
+	case 0:
+	case 1:
+		if f.Compressor == nil {
+			return ErrNoCompressor
+		}
+		dr, err := f.Compressor.Decompress(bytes.NewReader(b))
+		if err != nil {
+			return err
+		}
+		// read one extra byte to detect payloads over the limit.
+		b, err = io.ReadAll(io.LimitReader(dr, int64(f.maxSize)+1))
+		if err != nil {
+			return err
+		}
+		if len(b) > f.maxSize {
+			return fmt.Errorf("%w: decompressed size exceeds %d", ErrMessageTooLarge, f.maxSize)
+		}
+	default:
+		return fmt.Errorf("proto: invalid frame compression flag %d", hdr[0])
+	}
+	return proto.Unmarshal(b, m)
+}
@@ -0,0 +1,82 @@
+package proto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	testData "github.com/sraphs/encoding/internal/testdata/encoding"
+)
+
+func TestFrame(t *testing.T) {
+	tests := []struct {
+		name       string
+		compressor Compressor
+		flag       byte
+	}{
+		{name: "identity", flag: 0},
+		{name: "gzip", compressor: GzipCompressor{}, flag: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := &testData.TestModel{Id: 1, Name: "sraph", Hobby: []string{"study", "eat", "play"}}
+
+			var buf bytes.Buffer
+			w := NewFrameWriter(&buf)
+			w.Compressor = tt.compressor
+			for i := 0; i < 2; i++ {
+				if err := w.WriteMsg(in); err != nil {
+					t.Fatalf("WriteMsg() should be nil, but got %s", err)
+				}
+			}
+
+			raw := buf.Bytes()
+			if raw[0] != tt.flag {
+				t.Errorf("compression flag should be %d, but got %d", tt.flag, raw[0])
+			}
+			size := binary.BigEndian.Uint32(raw[1:5])
+			if int(size) != len(raw)/2-5 {
+				t.Errorf("frame length should be %d, but got %d", len(raw)/2-5, size)
+			}
+
+			r := NewFrameReader(&buf, 0)
+			r.Compressor = tt.compressor
+			for i := 0; i < 2; i++ {
+				var out testData.TestModel
+				if err := r.ReadMsg(&out); err != nil {
+					t.Fatalf("ReadMsg() should be nil, but got %s", err)
+				}
+				if !proto.Equal(in, &out) {
+					t.Errorf("message should be %v, but got %v", in, &out)
+				}
+			}
+			if err := r.ReadMsg(&testData.TestModel{}); err != io.EOF {
+				t.Errorf("ReadMsg() should be io.EOF, but got %v", err)
+			}
+		})
+	}
+}
+
+func TestFrameErrors(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewFrameWriter(&buf)
+	w.Compressor = GzipCompressor{}
+	if err := w.WriteMsg(&testData.TestModel{Name: "sraph"}); err != nil {
+		t.Fatalf("WriteMsg() should be nil, but got %s", err)
+	}
+	raw := buf.Bytes()
+
+	if err := NewFrameReader(bytes.NewReader(raw), 0).ReadMsg(&testData.TestModel{}); err != ErrNoCompressor {
+		t.Errorf("ReadMsg() should be ErrNoCompressor, but got %v", err)
+	}
+	if err := NewFrameReader(bytes.NewReader(raw), 2).ReadMsg(&testData.TestModel{}); !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("ReadMsg() should be ErrMessageTooLarge, but got %v", err)
+	}
+	if err := NewFrameReader(bytes.NewReader(raw[:3]), 0).ReadMsg(&testData.TestModel{}); err != io.ErrUnexpectedEOF {
+		t.Errorf("ReadMsg() should be io.ErrUnexpectedEOF, but got %v", err)
+	}
+}
@@ -4,20 +4,47 @@ package proto
 
 import (
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	"github.com/sraphs/encoding/merge"
+	"github.com/sraphs/encoding/validate"
 )
 
 // Name is the name registered for the proto compressor.
 const Name = "proto"
 
 // Codec is a Codec implementation with protobuf. It is the default Codec for Transport.
-type Codec struct{}
+type Codec struct {
+	// Types resolves the extensions of the messages, such as the types of a
+	// dynamic.Registry for dynamicpb messages, protoregistry.GlobalTypes if
+	// nil.
+	Types *protoregistry.Types
+	// Merge is how Unmarshal combines data with the fields of the message
+	// already set. If zero, the message is reset.
+	Merge merge.Mode
+	// Validate validates the values decoded by Unmarshal, such as with their
+	// Validate methods, failing with a *validate.Error. No validation if nil.
+	Validate *validate.Registry
+}
 
 func (Codec) Marshal(v interface{}) ([]byte, error) {
 	return proto.Marshal(v.(proto.Message))
 }
 
-func (Codec) Unmarshal(data []byte, v interface{}) error {
-	return proto.Unmarshal(data, v.(proto.Message))
+func (c Codec) Unmarshal(data []byte, v interface{}) error {
+	if err := c.unmarshal(data, v); err != nil {
+		return err
+	}
+	return c.Validate.Validate(c.Name(), v)
+}
+
+func (c Codec) unmarshal(data []byte, v interface{}) error {
+	return c.Merge.Decode(v.(proto.Message), func(m proto.Message) error {
+		if c.Types != nil {
+			return proto.UnmarshalOptions{Resolver: c.Types}.Unmarshal(data, m)
+		}
+		return proto.Unmarshal(data, m)
+	})
 }
 
 func (Codec) Name() string {
@@ -0,0 +1,157 @@
+// Package prototext defines the protobuf text format codec.
+package prototext
+
+import (
+	"fmt"
+	"reflect"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/sraphs/encoding/merge"
+	"github.com/sraphs/encoding/validate"
+)
+
+// Name is the name registered for the prototext codec.
+const Name = "textproto"
+
+// Mode is the layout of the text format output.
+type Mode int
+
+const (
+	// MultiLine writes every field on its own indented line.
+	MultiLine Mode = iota
+	// SingleLine writes the whole message on one line.
+	SingleLine
+	// Compact writes the whole message on one line without optional whitespace.
+	Compact
+)
+
+var (
+	// MarshalOptions is a configurable text format marshaller.
+	MarshalOptions = prototext.MarshalOptions{
+		Indent: "  ",
+	}
+	// UnmarshalOptions is a configurable text format parser.
+	UnmarshalOptions = prototext.UnmarshalOptions{
+		DiscardUnknown: true,
+	}
+)
+
+// Codec is a Codec implementation with protobuf text format.
+type Codec struct {
+	// Mode is the output layout, MultiLine by default.
+	Mode Mode
+	// Merge is how Unmarshal combines data with the fields of the message
+	// already set. If zero, the message is reset.
+	Merge merge.Mode
+	// Validate validates the values decoded by Unmarshal, such as with their
+	// Validate methods, failing with a *validate.Error. No validation if nil.
+	Validate *validate.Registry
+}
+
+func (c Codec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("prototext: %T is not a proto.Message", v)
+	}
+
+	opts := MarshalOptions
+	switch c.Mode {
+	case MultiLine:
+		opts.Multiline = true
+		if opts.Indent == "" {
+			opts.Indent = "  "
+		}
+	case SingleLine, Compact:
+		opts.Multiline = false
+		opts.Indent = ""
+	default:
+		return nil, fmt.Errorf("prototext: unknown mode %d", c.Mode)
+	}
+
+	b, err := opts.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	if c.Mode == Compact {
+		b = compact(b)
+	}
+	return b, nil
+}
+
+func (c Codec) Unmarshal(data []byte, v interface{}) error {
+	if err := c.unmarshal(data, v); err != nil {
+		return err
+	}
+	return c.Validate.Validate(c.Name(), v)
+}
+
+func (c Codec) unmarshal(data []byte, v interface{}) error {
+	if m, ok := v.(proto.Message); ok {
+		return c.Merge.Decode(m, func(m proto.Message) error {
+			return UnmarshalOptions.Unmarshal(data, m)
+		})
+	}
+	rv := reflect.ValueOf(v)
+	for rv := rv; rv.Kind() == reflect.Ptr; {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		rv = rv.Elem()
+	}
+	if m, ok := reflect.Indirect(rv).Interface().(proto.Message); ok {
+		return c.Merge.Decode(m, func(m proto.Message) error {
+			return UnmarshalOptions.Unmarshal(data, m)
+		})
+	}
+	return fmt.Errorf("prototext: %T is not a proto.Message", v)
+}
+
+func (Codec) Name() string {
+	return Name
+}
+
+// compact removes the whitespace that is not required to separate tokens,
+// leaving string literals untouched.
+func compact(b []byte) []byte {
+	out := make([]byte, 0, len(b))
+	var quote byte
+	space := false
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		if quote != 0 {
+			out = append(out, c)
+			switch {
+			case c == '\\' && i+1 < len(b):
+				i++
+				out = append(out, b[i])
+			case c == quote:
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case ' ', '\t', '\n', '\r':
+			space = true
+			continue
+		}
+		if space && len(out) > 0 && !isDelimiter(out[len(out)-1]) && !isDelimiter(c) {
+			out = append(out, ' ')
+		}
+		space = false
+		if c == '"' || c == '\'' {
+			quote = c
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+func isDelimiter(c byte) bool {
+	switch c {
+	case ':', '{', '}', '[', ']', '<', '>', ',', ';':
+		return true
+	}
+	return false
+}
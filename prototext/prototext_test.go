@@ -0,0 +1,69 @@
+package prototext
+
+import (
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	testData "github.com/sraphs/encoding/internal/testdata/encoding"
+)
+
+func TestName(t *testing.T) {
+	if got := (Codec{}).Name(); got != "textproto" {
+		t.Errorf("Name() should be textproto, but got %s", got)
+	}
+}
+
+func TestCodec(t *testing.T) {
+	in := &testData.TestModel{
+		Id:    1,
+		Name:  "sraph { }",
+		Hobby: []string{"study", "eat"},
+		Attrs: map[string]string{"k": "v"},
+	}
+
+	tests := []struct {
+		mode      Mode
+		multiline bool
+		expect    string
+	}{
+		{mode: MultiLine, multiline: true},
+		{mode: SingleLine},
+		{
+			mode:   Compact,
+			expect: `id:1 name:"sraph { }" hobby:"study" hobby:"eat" attrs:{key:"k" value:"v"}`,
+		},
+	}
+	for _, tt := range tests {
+		c := Codec{Mode: tt.mode}
+		b, err := c.Marshal(in)
+		if err != nil {
+			t.Fatalf("Marshal() should be nil, but got %s", err)
+		}
+		if got := strings.Count(strings.TrimSpace(string(b)), "\n") > 0; got != tt.multiline {
+			t.Errorf("mode %d multiline should be %v, but got %q", tt.mode, tt.multiline, b)
+		}
+		if tt.expect != "" && string(b) != tt.expect {
+			t.Errorf("mode %d should be %q, but got %q", tt.mode, tt.expect, b)
+		}
+
+		var out testData.TestModel
+		if err := c.Unmarshal(b, &out); err != nil {
+			t.Fatalf("Unmarshal() should be nil, but got %s", err)
+		}
+		if !proto.Equal(in, &out) {
+			t.Errorf("mode %d message should be %v, but got %v", tt.mode, in, &out)
+		}
+	}
+}
+
+func TestCodecNotProto(t *testing.T) {
+	if _, err := (Codec{}).Marshal(struct{}{}); err == nil {
+		t.Errorf("Marshal() should return err")
+	}
+	var s struct{}
+	if err := (Codec{}).Unmarshal([]byte(""), &s); err == nil {
+		t.Errorf("Unmarshal() should return err")
+	}
+}
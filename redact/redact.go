@@ -0,0 +1,386 @@
+// Package redact replaces the values of sensitive fields, so that encoded
+// configurations can be printed or logged without leaking secrets.
+//
+// Sensitive fields are the Go struct fields tagged sensitive:"true" and the
+// proto fields with the debug_redact option.
+package redact
+
+import (
+	"reflect"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// Placeholder replaces the values of the sensitive string and bytes fields.
+const Placeholder = "[REDACTED]"
+
+// Copy returns a copy of v, a Go value or a proto message, with the values of
+// its sensitive fields replaced. Strings and bytes, and their lists, are set
+// to Placeholder; the other fields are set to their zero value, or cleared.
+//
+// Only the parts of v holding sensitive fields are copied, and v is returned
+// as is if there are none. The embedded pointers to structs of unexported
+// types cannot be set, and are left as is: their sensitive fields, promoted
+// by encoding/json, are not redacted.
+func Copy(v interface{}) interface{} {
+	if m, ok := v.(proto.Message); ok {
+		return Message(m)
+	}
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return v
+	}
+	r := redactor{seen: make(map[uintptr]bool)}
+	if c, ok := r.value(rv); ok {
+		return c.Interface()
+	}
+	return v
+}
+
+// Message returns a copy of m with the values of its fields with the
+// debug_redact option replaced, or m if there are none.
+func Message(m proto.Message) proto.Message {
+	if m == nil || !m.ProtoReflect().IsValid() || !hasRedacted(m.ProtoReflect().Descriptor()) {
+		return m
+	}
+	c := proto.Clone(m)
+	redactMessage(c.ProtoReflect())
+	return c
+}
+
+// IsRedacted reports whether fd has the debug_redact option.
+func IsRedacted(fd protoreflect.FieldDescriptor) bool {
+	opts, ok := fd.Options().(*descriptorpb.FieldOptions)
+	return ok && opts.GetDebugRedact()
+}
+
+// IsSensitive reports whether the struct field is tagged sensitive:"true".
+func IsSensitive(sf reflect.StructField) bool {
+	return sf.Tag.Get("sensitive") == "true"
+}
+
+var redactedMessages sync.Map // protoreflect.FullName -> bool
+
+// hasRedacted reports whether md or one of the messages it holds has a field
+// with the debug_redact option.
+func hasRedacted(md protoreflect.MessageDescriptor) bool {
+	if v, ok := redactedMessages.Load(md.FullName()); ok {
+		return v.(bool)
+	}
+	has := walkMessage(md, make(map[protoreflect.FullName]bool))
+	redactedMessages.Store(md.FullName(), has)
+	return has
+}
+
+func walkMessage(md protoreflect.MessageDescriptor, seen map[protoreflect.FullName]bool) bool {
+	if seen[md.FullName()] {
+		return false
+	}
+	seen[md.FullName()] = true
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if IsRedacted(fd) {
+			return true
+		}
+		if fd.IsMap() {
+			fd = fd.MapValue()
+		}
+		if fd.Message() != nil && walkMessage(fd.Message(), seen) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactMessage(m protoreflect.Message) {
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case IsRedacted(fd):
+			redactField(m, fd, v)
+		case fd.IsMap():
+			if md := fd.MapValue().Message(); md != nil && hasRedacted(md) {
+				v.Map().Range(func(_ protoreflect.MapKey, e protoreflect.Value) bool {
+					redactMessage(e.Message())
+					return true
+				})
+			}
+		case fd.IsList():
+			if md := fd.Message(); md != nil && hasRedacted(md) {
+				for i := 0; i < v.List().Len(); i++ {
+					redactMessage(v.List().Get(i).Message())
+				}
+			}
+		case fd.Message() != nil:
+			if hasRedacted(fd.Message()) {
+				redactMessage(v.Message())
+			}
+		}
+		return true
+	})
+}
+
+// redactField replaces the value v of the redacted field fd of m.
+func redactField(m protoreflect.Message, fd protoreflect.FieldDescriptor, v protoreflect.Value) {
+	vd := fd
+	if fd.IsMap() {
+		vd = fd.MapValue()
+	}
+	if !hasPlaceholder(vd) {
+		m.Clear(fd)
+		return
+	}
+	switch {
+	case fd.IsMap():
+		mv := v.Map()
+		mv.Range(func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+			mv.Set(k, placeholder(vd, mv.NewValue))
+			return true
+		})
+	case fd.IsList():
+		lv := v.List()
+		for i := 0; i < lv.Len(); i++ {
+			lv.Set(i, placeholder(vd, lv.NewElement))
+		}
+	default:
+		m.Set(fd, placeholder(vd, func() protoreflect.Value { return m.NewField(fd) }))
+	}
+}
+
+// hasPlaceholder reports whether fd is a string or bytes field, or a wrapper
+// of them, whose values are replaced by Placeholder.
+func hasPlaceholder(fd protoreflect.FieldDescriptor) bool {
+	switch fd.Kind() {
+	case protoreflect.StringKind, protoreflect.BytesKind:
+		return true
+	case protoreflect.MessageKind:
+		name := fd.Message().FullName()
+		return name == "google.protobuf.StringValue" || name == "google.protobuf.BytesValue"
+	}
+	return false
+}
+
+// placeholder returns the placeholder value of fd, making wrapper messages
+// with newValue.
+func placeholder(fd protoreflect.FieldDescriptor, newValue func() protoreflect.Value) protoreflect.Value {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(Placeholder)
+	case protoreflect.BytesKind:
+		return protoreflect.ValueOfBytes([]byte(Placeholder))
+	}
+	w := newValue()
+	value := fd.Message().Fields().ByName("value")
+	w.Message().Set(value, placeholder(value, nil))
+	return w
+}
+
+var sensitiveTypes sync.Map // reflect.Type -> bool
+
+// mayBeSensitive reports whether values of type t may hold sensitive fields.
+func mayBeSensitive(t reflect.Type) bool {
+	if v, ok := sensitiveTypes.Load(t); ok {
+		return v.(bool)
+	}
+	may := walkType(t, make(map[reflect.Type]bool))
+	sensitiveTypes.Store(t, may)
+	return may
+}
+
+func walkType(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	if t.Implements(protoMessageType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return walkType(t.Elem(), seen)
+	case reflect.Map:
+		return walkType(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if sf.PkgPath != "" && !isEmbeddedStruct(sf) {
+				continue
+			}
+			if IsSensitive(sf) || walkType(sf.Type, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var protoMessageType = reflect.TypeOf((*proto.Message)(nil)).Elem()
+
+type redactor struct {
+	seen map[uintptr]bool
+}
+
+// value returns a redacted copy of v and true, or false if v has nothing to
+// redact.
+func (r redactor) value(v reflect.Value) (reflect.Value, bool) {
+	if !mayBeSensitive(v.Type()) {
+		return v, false
+	}
+	if m, ok := v.Interface().(proto.Message); ok && (v.Kind() != reflect.Ptr || !v.IsNil()) {
+		c := Message(m)
+		if c == m {
+			return v, false
+		}
+		return reflect.ValueOf(c), true
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() || r.seen[v.Pointer()] {
+			return v, false
+		}
+		// seen only holds the pointers of the current path, to stop at
+		// cycles: the values pointed to several times are redacted each time.
+		r.seen[v.Pointer()] = true
+		e, ok := r.value(v.Elem())
+		delete(r.seen, v.Pointer())
+		if !ok {
+			return v, false
+		}
+		p := reflect.New(v.Type().Elem())
+		p.Elem().Set(e)
+		return p, true
+	case reflect.Interface:
+		if v.IsNil() {
+			return v, false
+		}
+		e, ok := r.value(v.Elem())
+		if !ok {
+			return v, false
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(e)
+		return c, true
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		if r.fields(v, c) {
+			return c, true
+		}
+		return v, false
+	case reflect.Slice, reflect.Array:
+		var c reflect.Value
+		for i := 0; i < v.Len(); i++ {
+			e, ok := r.value(v.Index(i))
+			if !ok {
+				continue
+			}
+			if !c.IsValid() {
+				if v.Kind() == reflect.Slice {
+					c = reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+				} else {
+					c = reflect.New(v.Type()).Elem()
+				}
+				reflect.Copy(c, v)
+			}
+			c.Index(i).Set(e)
+		}
+		return c, c.IsValid()
+	case reflect.Map:
+		var changed map[int]reflect.Value
+		keys := v.MapKeys()
+		for i, k := range keys {
+			if e, ok := r.value(v.MapIndex(k)); ok {
+				if changed == nil {
+					changed = make(map[int]reflect.Value)
+				}
+				changed[i] = e
+			}
+		}
+		if changed == nil {
+			return v, false
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for i, k := range keys {
+			if e, ok := changed[i]; ok {
+				c.SetMapIndex(k, e)
+			} else {
+				c.SetMapIndex(k, v.MapIndex(k))
+			}
+		}
+		return c, true
+	}
+	return v, false
+}
+
+// fields redacts the fields of c, an addressable copy of the struct v, and
+// reports whether it changed any. The embedded structs of unexported types
+// cannot be set, but their exported fields, which encoding/json and yaml
+// promote, can.
+func (r redactor) fields(v, c reflect.Value) bool {
+	changed := false
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			if isEmbeddedStruct(sf) && r.fields(v.Field(i), c.Field(i)) {
+				changed = true
+			}
+			continue
+		}
+		var f reflect.Value
+		if IsSensitive(sf) {
+			f = placeholderValue(v.Field(i))
+		} else if e, ok := r.value(v.Field(i)); ok {
+			f = e
+		} else {
+			continue
+		}
+		c.Field(i).Set(f)
+		changed = true
+	}
+	return changed
+}
+
+// isEmbeddedStruct reports whether sf is an embedded struct, not a pointer.
+func isEmbeddedStruct(sf reflect.StructField) bool {
+	return sf.Anonymous && sf.Type.Kind() == reflect.Struct
+}
+
+// placeholderValue returns the redacted value of a sensitive field whose
+// value is v.
+func placeholderValue(v reflect.Value) reflect.Value {
+	t := v.Type()
+	switch {
+	case t.Kind() == reflect.String:
+		return reflect.ValueOf(Placeholder).Convert(t)
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return reflect.ValueOf([]byte(Placeholder)).Convert(t)
+	case t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.String:
+		if v.IsNil() {
+			return v
+		}
+		p := reflect.New(t.Elem())
+		p.Elem().Set(placeholderValue(v.Elem()))
+		return p
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String:
+		c := reflect.MakeSlice(t, v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(placeholderValue(v.Index(i)))
+		}
+		return c
+	case t.Kind() == reflect.Map && t.Elem().Kind() == reflect.String:
+		c := reflect.MakeMapWithSize(t, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), placeholderValue(iter.Value()))
+		}
+		return c
+	}
+	return reflect.Zero(t)
+}
@@ -0,0 +1,284 @@
+package redact
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type database struct {
+	Host     string
+	Password string            `sensitive:"true"`
+	Key      []byte            `sensitive:"true"`
+	Port     int               `sensitive:"true"`
+	Token    *string           `sensitive:"true"`
+	Tokens   []string          `sensitive:"true"`
+	Headers  map[string]string `sensitive:"true"`
+	password string
+}
+
+type config struct {
+	Name      string
+	Primary   database
+	Replicas  []*database
+	Databases map[string]database
+	Extra     interface{}
+}
+
+func TestCopy(t *testing.T) {
+	token := "t0k"
+	in := &config{
+		Name: "app",
+		Primary: database{
+			Host:     "db",
+			Password: "hunter2",
+			Key:      []byte("k"),
+			Port:     5432,
+			Token:    &token,
+			Tokens:   []string{"a", "b"},
+			Headers:  map[string]string{"X-Key": "v"},
+			password: "kept",
+		},
+		Replicas:  []*database{{Host: "r1", Password: "p1"}, nil},
+		Databases: map[string]database{"main": {Host: "m", Password: "p"}},
+		Extra:     database{Password: "x"},
+	}
+
+	out, ok := Copy(in).(*config)
+	require.True(t, ok)
+
+	assert.Equal(t, "app", out.Name)
+	assert.Equal(t, "db", out.Primary.Host)
+	assert.Equal(t, Placeholder, out.Primary.Password)
+	assert.Equal(t, []byte(Placeholder), out.Primary.Key)
+	assert.Zero(t, out.Primary.Port)
+	assert.Equal(t, Placeholder, *out.Primary.Token)
+	assert.Equal(t, []string{Placeholder, Placeholder}, out.Primary.Tokens)
+	assert.Equal(t, map[string]string{"X-Key": Placeholder}, out.Primary.Headers)
+	assert.Equal(t, "kept", out.Primary.password)
+	assert.Equal(t, "r1", out.Replicas[0].Host)
+	assert.Equal(t, Placeholder, out.Replicas[0].Password)
+	assert.Nil(t, out.Replicas[1])
+	assert.Equal(t, Placeholder, out.Databases["main"].Password)
+	assert.Equal(t, Placeholder, out.Extra.(database).Password)
+
+	// the input is left as is.
+	assert.Equal(t, "hunter2", in.Primary.Password)
+	assert.Equal(t, "t0k", token)
+	assert.Equal(t, "p1", in.Replicas[0].Password)
+	assert.Equal(t, "p", in.Databases["main"].Password)
+	assert.Equal(t, "v", in.Primary.Headers["X-Key"])
+}
+
+type credentials struct {
+	User     string `json:"user"`
+	Password string `json:"password" sensitive:"true"`
+}
+
+type options struct {
+	Timeout int
+}
+
+type endpoint struct {
+	credentials
+	options
+	*database
+	Host string
+}
+
+func TestCopyEmbeddedUnexported(t *testing.T) {
+	db := &database{Host: "db", Password: "p"}
+	in := endpoint{
+		credentials: credentials{User: "sraph", Password: "hunter2"},
+		options:     options{Timeout: 3},
+		database:    db,
+		Host:        "h",
+	}
+
+	out := Copy(in).(endpoint)
+	assert.Equal(t, "sraph", out.User)
+	assert.Equal(t, Placeholder, out.credentials.Password)
+	assert.Equal(t, 3, out.Timeout)
+	assert.Equal(t, "h", out.Host)
+	// embedded pointers to unexported types are left as is.
+	assert.Same(t, db, out.database)
+
+	outp := Copy(&in).(*endpoint)
+	assert.Equal(t, Placeholder, outp.credentials.Password)
+
+	b, err := json.Marshal(Copy(&in))
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), "hunter2")
+
+	// the input is left as is.
+	assert.Equal(t, "hunter2", in.credentials.Password)
+}
+
+func TestCopySharedPointer(t *testing.T) {
+	type cluster struct {
+		Primary *database
+		Replica *database
+	}
+	db := &database{Host: "db", Password: "hunter2"}
+
+	out := Copy(cluster{Primary: db, Replica: db}).(cluster)
+	assert.Equal(t, Placeholder, out.Primary.Password)
+	assert.Equal(t, Placeholder, out.Replica.Password)
+
+	list := Copy([]*database{db, db}).([]*database)
+	assert.Equal(t, Placeholder, list[0].Password)
+	assert.Equal(t, Placeholder, list[1].Password)
+
+	b, err := json.Marshal(Copy(&cluster{Primary: db, Replica: db}))
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), "hunter2")
+	assert.Equal(t, "hunter2", db.Password)
+}
+
+func TestCopyNothingToRedact(t *testing.T) {
+	type plain struct{ Name string }
+	in := &plain{Name: "x"}
+	assert.Same(t, in, Copy(in))
+	assert.Nil(t, Copy(nil))
+	assert.Equal(t, 1, Copy(1))
+
+	m := wrapperspb.String("x")
+	assert.Same(t, m, Copy(m))
+}
+
+func TestCopyCycle(t *testing.T) {
+	type node struct {
+		Secret string `sensitive:"true"`
+		Next   *node
+	}
+	n := &node{Secret: "s"}
+	n.Next = n
+	out := Copy(n).(*node)
+	assert.Equal(t, Placeholder, out.Secret)
+	assert.Equal(t, "s", n.Secret)
+}
+
+func TestMessage(t *testing.T) {
+	md := redactedDescriptor(t)
+	fields := md.Fields()
+	child := md.Messages().ByName("Child")
+
+	m := dynamicpb.NewMessage(md)
+	m.Set(fields.ByName("name"), protoreflect.ValueOfString("app"))
+	m.Set(fields.ByName("password"), protoreflect.ValueOfString("hunter2"))
+	m.Set(fields.ByName("key"), protoreflect.ValueOfBytes([]byte("k")))
+	m.Set(fields.ByName("port"), protoreflect.ValueOfInt32(5432))
+	tokens := m.Mutable(fields.ByName("tokens")).List()
+	tokens.Append(protoreflect.ValueOfString("a"))
+	tokens.Append(protoreflect.ValueOfString("b"))
+	headers := m.Mutable(fields.ByName("headers")).Map()
+	headers.Set(protoreflect.ValueOfString("X-Key").MapKey(), protoreflect.ValueOfString("v"))
+	m.Set(fields.ByName("wrapped"), protoreflect.ValueOfMessage(wrapperspb.String("w").ProtoReflect()))
+	c := dynamicpb.NewMessage(child)
+	c.Set(child.Fields().ByName("secret"), protoreflect.ValueOfString("s"))
+	c.Set(child.Fields().ByName("note"), protoreflect.ValueOfString("n"))
+	m.Set(fields.ByName("child"), protoreflect.ValueOfMessage(c))
+	children := m.Mutable(fields.ByName("children")).List()
+	children.Append(protoreflect.ValueOfMessage(proto.Clone(c).ProtoReflect()))
+
+	in := proto.Clone(m)
+	out := Message(m).ProtoReflect()
+
+	assert.Equal(t, "app", out.Get(fields.ByName("name")).String())
+	assert.Equal(t, Placeholder, out.Get(fields.ByName("password")).String())
+	assert.Equal(t, []byte(Placeholder), out.Get(fields.ByName("key")).Bytes())
+	assert.False(t, out.Has(fields.ByName("port")))
+	assert.Equal(t, Placeholder, out.Get(fields.ByName("tokens")).List().Get(1).String())
+	assert.Equal(t, Placeholder, out.Get(fields.ByName("headers")).Map().Get(protoreflect.ValueOfString("X-Key").MapKey()).String())
+	wrapped := out.Get(fields.ByName("wrapped")).Message()
+	assert.Equal(t, Placeholder, wrapped.Get(wrapped.Descriptor().Fields().ByName("value")).String())
+	outChild := out.Get(fields.ByName("child")).Message()
+	assert.Equal(t, Placeholder, outChild.Get(child.Fields().ByName("secret")).String())
+	assert.Equal(t, "n", outChild.Get(child.Fields().ByName("note")).String())
+	outChild = out.Get(fields.ByName("children")).List().Get(0).Message()
+	assert.Equal(t, Placeholder, outChild.Get(child.Fields().ByName("secret")).String())
+
+	// the input is left as is.
+	assert.True(t, proto.Equal(in, m))
+}
+
+// redactedDescriptor returns the descriptor of a message with debug_redact
+// fields of every shape.
+func redactedDescriptor(t *testing.T) protoreflect.MessageDescriptor {
+	t.Helper()
+	redacted := &descriptorpb.FieldOptions{DebugRedact: proto.Bool(true)}
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, opts *descriptorpb.FieldOptions) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(number),
+			Type:     typ.Enum(),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Options:  opts,
+		}
+	}
+	message := func(f *descriptorpb.FieldDescriptorProto, typeName string) *descriptorpb.FieldDescriptorProto {
+		f.TypeName = proto.String(typeName)
+		return f
+	}
+	repeated := func(f *descriptorpb.FieldDescriptorProto) *descriptorpb.FieldDescriptorProto {
+		f.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+		return f
+	}
+	const (
+		tString  = descriptorpb.FieldDescriptorProto_TYPE_STRING
+		tBytes   = descriptorpb.FieldDescriptorProto_TYPE_BYTES
+		tInt32   = descriptorpb.FieldDescriptorProto_TYPE_INT32
+		tMessage = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+	)
+
+	fd := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("redact_test.proto"),
+		Package:    proto.String("redact.test"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/wrappers.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Config"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("name", 1, tString, nil),
+				field("password", 2, tString, redacted),
+				field("key", 3, tBytes, redacted),
+				field("port", 4, tInt32, redacted),
+				repeated(field("tokens", 5, tString, redacted)),
+				repeated(message(field("headers", 6, tMessage, redacted), ".redact.test.Config.HeadersEntry")),
+				message(field("wrapped", 7, tMessage, redacted), ".google.protobuf.StringValue"),
+				message(field("child", 8, tMessage, nil), ".redact.test.Config.Child"),
+				repeated(message(field("children", 9, tMessage, nil), ".redact.test.Config.Child")),
+			},
+			NestedType: []*descriptorpb.DescriptorProto{
+				{
+					Name: proto.String("HeadersEntry"),
+					Field: []*descriptorpb.FieldDescriptorProto{
+						field("key", 1, tString, nil),
+						field("value", 2, tString, nil),
+					},
+					Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+				},
+				{
+					Name: proto.String("Child"),
+					Field: []*descriptorpb.FieldDescriptorProto{
+						field("secret", 1, tString, redacted),
+						field("note", 2, tString, nil),
+					},
+				},
+			},
+		}},
+	}
+	file, err := protodesc.NewFile(fd, protoregistry.GlobalFiles)
+	require.NoError(t, err)
+	return file.Messages().ByName("Config")
+}
@@ -0,0 +1,170 @@
+package schema
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	durationType      = reflect.TypeOf(time.Duration(0))
+	protoMessageType  = reflect.TypeOf((*proto.Message)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// goType returns the schema of the values of t.
+func (s *state) goType(t reflect.Type) (*Schema, error) {
+	for t.Kind() == reflect.Ptr {
+		if t.Implements(protoMessageType) {
+			return s.message(reflect.Zero(t).Interface().(proto.Message).ProtoReflect().Descriptor()), nil
+		}
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return &Schema{Type: Types{"string"}, Format: "date-time"}, nil
+	case t == durationType:
+		// encoding/json writes nanoseconds, the other codecs Go syntax.
+		return &Schema{Type: Types{"integer", "string"}}, nil
+	case implements(t, jsonMarshalerType):
+		return &Schema{}, nil
+	case implements(t, textMarshalerType):
+		return &Schema{Type: Types{"string"}}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: Types{"boolean"}}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: Types{"integer"}}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &Schema{Type: Types{"integer"}, Minimum: float(0)}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: Types{"number"}}, nil
+	case reflect.String:
+		return &Schema{Type: Types{"string"}}, nil
+	case reflect.Interface:
+		return &Schema{}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 && s.tagName() == "json" {
+			return &Schema{Type: Types{"string"}, ContentEncoding: "base64"}, nil
+		}
+		items, err := s.goType(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: Types{"array"}, Items: items}, nil
+	case reflect.Map:
+		values, err := s.goType(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: Types{"object"}, AdditionalProperties: values}, nil
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.structDef(t)
+		}
+		return s.ref(t.String(), func() (*Schema, error) {
+			return s.structDef(t)
+		})
+	default:
+		return nil, fmt.Errorf("schema: unsupported type %s", t)
+	}
+}
+
+func implements(t, iface reflect.Type) bool {
+	return t.Implements(iface) || reflect.PtrTo(t).Implements(iface)
+}
+
+func (s *state) tagName() string {
+	if s.g.TagName == "" {
+		return "json"
+	}
+	return s.g.TagName
+}
+
+func (s *state) structDef(t reflect.Type) (*Schema, error) {
+	def := &Schema{Type: Types{"object"}, Properties: make(map[string]*Schema)}
+	if t.Name() != "" {
+		def.Title = t.Name()
+	}
+	if err := s.structFields(t, def); err != nil {
+		return nil, err
+	}
+	return def, nil
+}
+
+// structFields adds the fields of t to the properties of def, the fields of
+// the embedded and inlined structs included.
+func (s *state) structFields(t reflect.Type, def *Schema) error {
+	var inlined []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts := parseTag(f.Tag.Get(s.tagName()))
+		if name == "-" && opts == "" {
+			continue
+		}
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		// yaml only inlines the embedded structs with the inline option.
+		embedded := f.Anonymous && name == "" && s.tagName() != "yaml"
+		if (embedded || hasOption(opts, "inline")) && ft.Kind() == reflect.Struct {
+			inlined = append(inlined, ft)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+			if s.tagName() == "yaml" {
+				name = strings.ToLower(name)
+			}
+		}
+		if _, ok := def.Properties[name]; ok {
+			continue
+		}
+		fs, err := s.goType(f.Type)
+		if err != nil {
+			return fmt.Errorf("%w of field %s.%s", err, t, f.Name)
+		}
+		if hasOption(opts, "string") && s.tagName() == "json" && fs.Ref == "" {
+			fs = &Schema{Type: Types{"string"}}
+		}
+		def.Properties[name] = fs
+	}
+	// the fields of t hide the fields of the inlined structs.
+	for _, it := range inlined {
+		if err := s.structFields(it, def); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseTag(tag string) (string, string) {
+	if i := strings.IndexByte(tag, ','); i >= 0 {
+		return tag[:i], tag[i+1:]
+	}
+	return tag, ""
+}
+
+func hasOption(opts, option string) bool {
+	for opts != "" {
+		var o string
+		o, opts, _ = strings.Cut(opts, ",")
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,216 @@
+package schema
+
+import (
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	int64Pattern    = `^-?[0-9]+$`
+	uint64Pattern   = `^[0-9]+$`
+	durationPattern = `^-?[0-9]+(\.[0-9]{1,9})?s$`
+)
+
+// message returns a reference to the schema of the messages of md.
+func (s *state) message(md protoreflect.MessageDescriptor) *Schema {
+	if wkt := wellKnown(md); wkt != nil {
+		return wkt
+	}
+	ref, _ := s.ref(string(md.FullName()), func() (*Schema, error) {
+		return s.messageDef(md), nil
+	})
+	return ref
+}
+
+func (s *state) messageDef(md protoreflect.MessageDescriptor) *Schema {
+	def := &Schema{
+		Type:        Types{"object"},
+		Title:       string(md.Name()),
+		Description: comments(md),
+		Properties:  make(map[string]*Schema),
+	}
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		fs := s.field(fd)
+		if c := comments(fd); c != "" {
+			fs.Description = c
+		}
+		def.Properties[s.fieldName(fd)] = fs
+	}
+	oneofs := md.Oneofs()
+	for i := 0; i < oneofs.Len(); i++ {
+		if od := oneofs.Get(i); !od.IsSynthetic() {
+			def.AllOf = append(def.AllOf, s.oneof(od))
+		}
+	}
+	if len(def.AllOf) == 1 {
+		def.OneOf, def.AllOf = def.AllOf[0].OneOf, nil
+	}
+	return def
+}
+
+// oneof returns the schema allowing at most one of the fields of od: either
+// one of them alone, or none.
+func (s *state) oneof(od protoreflect.OneofDescriptor) *Schema {
+	var one, none []*Schema
+	fields := od.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		required := &Schema{Required: []string{s.fieldName(fields.Get(i))}}
+		one = append(one, required)
+		none = append(none, required)
+	}
+	return &Schema{OneOf: append(one, &Schema{Not: &Schema{AnyOf: none}})}
+}
+
+func (s *state) fieldName(fd protoreflect.FieldDescriptor) string {
+	if s.g.UseProtoNames {
+		return string(fd.Name())
+	}
+	return fd.JSONName()
+}
+
+// field returns the schema of the values of fd.
+func (s *state) field(fd protoreflect.FieldDescriptor) *Schema {
+	switch {
+	case fd.IsMap():
+		return &Schema{
+			Type:                 Types{"object"},
+			PropertyNames:        mapKey(fd.MapKey()),
+			AdditionalProperties: s.singular(fd.MapValue()),
+		}
+	case fd.IsList():
+		return &Schema{Type: Types{"array"}, Items: s.singular(fd)}
+	case fd.Message() != nil || fd.Enum() != nil:
+		// protojson writes the unset messages as null, and reads null as
+		// unset.
+		return nullable(s.singular(fd))
+	default:
+		return s.singular(fd)
+	}
+}
+
+// nullable returns fs also allowing null.
+func nullable(fs *Schema) *Schema {
+	switch {
+	case fs.Ref != "":
+		return &Schema{AnyOf: []*Schema{fs, {Type: Types{"null"}}}}
+	case fs.AnyOf != nil:
+		fs.AnyOf = append(fs.AnyOf, &Schema{Type: Types{"null"}})
+	case len(fs.Type) > 0 && fs.Type[0] != "null":
+		fs.Type = append(fs.Type, "null")
+		if fs.Enum != nil {
+			fs.Enum = append(fs.Enum, nil)
+		}
+	}
+	return fs
+}
+
+// singular returns the schema of a single value of fd.
+func (s *state) singular(fd protoreflect.FieldDescriptor) *Schema {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return &Schema{Type: Types{"boolean"}}
+	case protoreflect.StringKind:
+		return &Schema{Type: Types{"string"}}
+	case protoreflect.BytesKind:
+		return &Schema{Type: Types{"string"}, ContentEncoding: "base64"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return &Schema{Type: Types{"integer"}, Format: "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return &Schema{Type: Types{"integer"}, Format: "uint32", Minimum: float(0)}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		// protojson writes 64-bit integers as strings, and reads both.
+		return &Schema{Type: Types{"integer", "string"}, Format: "int64", Pattern: int64Pattern}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return &Schema{Type: Types{"integer", "string"}, Format: "uint64", Pattern: uint64Pattern, Minimum: float(0)}
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return floatSchema()
+	case protoreflect.EnumKind:
+		return enum(fd.Enum())
+	default:
+		return s.message(fd.Message())
+	}
+}
+
+func floatSchema() *Schema {
+	return &Schema{AnyOf: []*Schema{
+		{Type: Types{"number"}},
+		{Enum: []interface{}{"NaN", "Infinity", "-Infinity"}},
+	}}
+}
+
+// enum returns the schema of the values of ed: their names, or their
+// numbers.
+func enum(ed protoreflect.EnumDescriptor) *Schema {
+	if ed.FullName() == "google.protobuf.NullValue" {
+		return &Schema{Type: Types{"null"}}
+	}
+	values := ed.Values()
+	es := &Schema{Type: Types{"string", "integer"}, Description: comments(ed)}
+	for i := 0; i < values.Len(); i++ {
+		es.Enum = append(es.Enum, string(values.Get(i).Name()))
+	}
+	for i := 0; i < values.Len(); i++ {
+		es.Enum = append(es.Enum, int32(values.Get(i).Number()))
+	}
+	return es
+}
+
+// mapKey returns the schema of the keys of a map, nil for string keys.
+func mapKey(fd protoreflect.FieldDescriptor) *Schema {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return &Schema{Enum: []interface{}{"true", "false"}}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return &Schema{Pattern: int64Pattern}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return &Schema{Pattern: uint64Pattern}
+	default:
+		return nil
+	}
+}
+
+// wellKnown returns the schema of the protojson form of the well-known type
+// md, nil if md is not one.
+func wellKnown(md protoreflect.MessageDescriptor) *Schema {
+	if md.ParentFile().Package() != "google.protobuf" {
+		return nil
+	}
+	switch md.Name() {
+	case "Timestamp":
+		return &Schema{Type: Types{"string"}, Format: "date-time"}
+	case "Duration":
+		return &Schema{Type: Types{"string"}, Pattern: durationPattern}
+	case "FieldMask":
+		return &Schema{Type: Types{"string"}}
+	case "Struct":
+		return &Schema{Type: Types{"object"}}
+	case "ListValue":
+		return &Schema{Type: Types{"array"}}
+	case "Value":
+		return &Schema{}
+	case "Empty":
+		return &Schema{Type: Types{"object"}}
+	case "Any":
+		return &Schema{
+			Type:       Types{"object"},
+			Properties: map[string]*Schema{"@type": {Type: Types{"string"}}},
+			Required:   []string{"@type"},
+		}
+	case "DoubleValue", "FloatValue", "Int64Value", "UInt64Value", "Int32Value", "UInt32Value",
+		"BoolValue", "StringValue", "BytesValue":
+		// the wrappers are written as the value they wrap.
+		return (&state{}).singular(md.Fields().ByName("value"))
+	}
+	return nil
+}
+
+// comments returns the comments of d in its .proto file, if the descriptor
+// has them.
+func comments(d protoreflect.Descriptor) string {
+	loc := d.ParentFile().SourceLocations().ByDescriptor(d)
+	return strings.TrimSpace(loc.LeadingComments)
+}
@@ -0,0 +1,138 @@
+// Package schema generates the JSON Schema (draft 2020-12) of the values the
+// codecs decode, proto messages and Go types, such as to complete and check
+// the YAML configuration files in editors.
+//
+// Proto messages are described in their protojson form, the form of the json
+// codec and of the yaml codec with its ProtoJSON option, and Go types in their
+// encoding/json form, or the form of the yaml codec with the yaml struct tags. The messages and the named struct
+// types are described once, in $defs, and referred to by name.
+//
+// A Validator validates documents against a schema, generated or not, such
+// as the json and yaml codecs do before decoding their input.
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Draft is the URI of the JSON Schema dialect of the generated schemas.
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is a JSON Schema, with the keywords used by the generator.
+type Schema struct {
+	Schema      string             `json:"$schema,omitempty"`
+	Ref         string             `json:"$ref,omitempty"`
+	Defs        map[string]*Schema `json:"$defs,omitempty"`
+	Title       string             `json:"title,omitempty"`
+	Description string             `json:"description,omitempty"`
+
+	Type            Types         `json:"type,omitempty"`
+	Enum            []interface{} `json:"enum,omitempty"`
+	Format          string        `json:"format,omitempty"`
+	Pattern         string        `json:"pattern,omitempty"`
+	ContentEncoding string        `json:"contentEncoding,omitempty"`
+	Minimum         *float64      `json:"minimum,omitempty"`
+
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	PropertyNames        *Schema            `json:"propertyNames,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+
+	AllOf []*Schema `json:"allOf,omitempty"`
+	AnyOf []*Schema `json:"anyOf,omitempty"`
+	OneOf []*Schema `json:"oneOf,omitempty"`
+	Not   *Schema   `json:"not,omitempty"`
+}
+
+// Types is the type keyword, written as a string if it has a single type.
+type Types []string
+
+func (t Types) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+// Generator generates schemas.
+type Generator struct {
+	// TagName is the struct tag naming the fields of Go structs, "json" if
+	// empty, such as "yaml". Tag options such as omitempty and inline are
+	// honored.
+	TagName string
+	// UseProtoNames names the fields of proto messages by their proto names
+	// instead of their JSON names.
+	UseProtoNames bool
+}
+
+// Generate returns the schema of v, which may be a proto message, a message
+// descriptor, a reflect.Type or a Go value.
+func (g Generator) Generate(v interface{}) (*Schema, error) {
+	s := &state{g: g, defs: make(map[string]*Schema)}
+	var root *Schema
+	var err error
+	switch v := v.(type) {
+	case proto.Message:
+		root = s.message(v.ProtoReflect().Descriptor())
+	case protoreflect.MessageDescriptor:
+		root = s.message(v)
+	case reflect.Type:
+		root, err = s.goType(v)
+	default:
+		if v == nil {
+			return nil, fmt.Errorf("schema: no type")
+		}
+		root, err = s.goType(reflect.TypeOf(v))
+	}
+	if err != nil {
+		return nil, err
+	}
+	root.Schema = Draft
+	if len(s.defs) > 0 {
+		root.Defs = s.defs
+	}
+	return root, nil
+}
+
+// Generate returns the schema of v with the default Generator.
+func Generate(v interface{}) (*Schema, error) {
+	return Generator{}.Generate(v)
+}
+
+// state holds the definitions of a schema being generated.
+type state struct {
+	g    Generator
+	defs map[string]*Schema
+}
+
+// ref returns a reference to the definition named name, calling define the
+// first time.
+func (s *state) ref(name string, define func() (*Schema, error)) (*Schema, error) {
+	if _, ok := s.defs[name]; !ok {
+		// a placeholder for the recursive references.
+		s.defs[name] = nil
+		def, err := define()
+		if err != nil {
+			delete(s.defs, name)
+			return nil, err
+		}
+		s.defs[name] = def
+	}
+	return &Schema{Ref: "#/$defs/" + EscapeToken(name)}, nil
+}
+
+// EscapeToken escapes name as a reference token of a JSON Pointer.
+func EscapeToken(name string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+}
+
+func float(f float64) *float64 {
+	return &f
+}
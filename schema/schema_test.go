@@ -0,0 +1,267 @@
+package schema_test
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/sraphs/encoding/internal/testdata/complex"
+	ejson "github.com/sraphs/encoding/json"
+	"github.com/sraphs/encoding/schema"
+	"github.com/sraphs/encoding/yaml"
+)
+
+func marshal(t *testing.T, s *schema.Schema) string {
+	t.Helper()
+	b, err := json.Marshal(s)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestMessage(t *testing.T) {
+	s, err := schema.Generate(&complex.Complex{})
+	require.NoError(t, err)
+	assert.Equal(t, schema.Draft, s.Schema)
+	assert.Equal(t, "#/$defs/testdata.complex.Complex", s.Ref)
+
+	def := s.Defs["testdata.complex.Complex"]
+	require.NotNil(t, def)
+	for name, want := range map[string]string{
+		"numberOne":   `{"type":"string"}`,
+		"very_simple": `{"anyOf":[{"$ref":"#/$defs/testdata.complex.Simple"},{"type":"null"}]}`,
+		"simples":     `{"type":"array","items":{"type":"string"}}`,
+		"id":          `{"type":["integer","string"],"format":"int64","pattern":"^-?[0-9]+$"}`,
+		"a":           `{"type":"integer","format":"uint32","minimum":0}`,
+		"sex":         `{"type":["string","integer","null"],"enum":["man","woman",0,1,null]}`,
+		"price":       `{"anyOf":[{"type":"number"},{"enum":["NaN","Infinity","-Infinity"]}]}`,
+		"byte":        `{"type":"string","contentEncoding":"base64"}`,
+		"timestamp":   `{"type":["string","null"],"format":"date-time"}`,
+		"duration":    `{"type":["string","null"],"pattern":"^-?[0-9]+(\\.[0-9]{1,9})?s$"}`,
+		"field":       `{"type":["string","null"]}`,
+		"int32":       `{"type":["integer","null"],"format":"int32"}`,
+		"bool":        `{"type":["boolean","null"]}`,
+		"map":         `{"type":"object","additionalProperties":{"type":"string"}}`,
+	} {
+		require.Contains(t, def.Properties, name)
+		assert.JSONEq(t, want, marshal(t, def.Properties[name]), name)
+	}
+	assert.NotContains(t, def.Properties, "no_one")
+	assert.JSONEq(t, `{"type":"object","title":"Simple","properties":{"component":{"type":"string"}}}`,
+		marshal(t, s.Defs["testdata.complex.Simple"]))
+
+	s, err = schema.Generator{UseProtoNames: true}.Generate((&complex.Complex{}).ProtoReflect().Descriptor())
+	require.NoError(t, err)
+	assert.Contains(t, s.Defs["testdata.complex.Complex"].Properties, "no_one")
+}
+
+func TestOneof(t *testing.T) {
+	md := nodeDescriptor(t)
+	s, err := schema.Generate(md)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$ref": "#/$defs/tree.Node",
+		"$defs": {
+			"tree.Node": {
+				"type": "object",
+				"title": "Node",
+				"properties": {
+					"name": {"type": "string"},
+					"children": {"type": "object", "propertyNames": {"pattern": "^-?[0-9]+$"},
+						"additionalProperties": {"$ref": "#/$defs/tree.Node"}},
+					"text": {"type": "string"},
+					"count": {"type": "integer", "format": "int32"}
+				},
+				"oneOf": [
+					{"required": ["text"]},
+					{"required": ["count"]},
+					{"not": {"anyOf": [{"required": ["text"]}, {"required": ["count"]}]}}
+				]
+			}
+		}
+	}`, marshal(t, s))
+}
+
+type Address struct {
+	City string `json:"city" yaml:"city"`
+}
+
+type Base struct {
+	ID   int64 `json:"id" yaml:"id"`
+	Name int   `json:"name" yaml:"name"` // hidden by Person.Name
+}
+
+type Person struct {
+	Base
+	Name     string            `json:"name,omitempty" yaml:"full_name"`
+	Age      uint8             `json:"age,string" yaml:"age"`
+	Tags     []string          `json:"tags" yaml:"tags"`
+	Address  *Address          `json:"address" yaml:"address"`
+	Friends  []*Person         `json:"friends" yaml:"friends"`
+	Labels   map[string]string `json:"labels" yaml:"labels"`
+	Born     time.Time         `json:"born" yaml:"born"`
+	Avatar   []byte            `json:"avatar" yaml:"avatar"`
+	Extra    interface{}       `json:"extra" yaml:"extra"`
+	Secret   string            `json:"-" yaml:"-"`
+	internal string
+}
+
+func TestGoType(t *testing.T) {
+	s, err := schema.Generate(Person{})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$ref": "#/$defs/schema_test.Person",
+		"$defs": {
+			"schema_test.Address": {"type": "object", "title": "Address", "properties": {"city": {"type": "string"}}},
+			"schema_test.Person": {
+				"type": "object",
+				"title": "Person",
+				"properties": {
+					"id": {"type": "integer"},
+					"name": {"type": "string"},
+					"age": {"type": "string"},
+					"tags": {"type": "array", "items": {"type": "string"}},
+					"address": {"$ref": "#/$defs/schema_test.Address"},
+					"friends": {"type": "array", "items": {"$ref": "#/$defs/schema_test.Person"}},
+					"labels": {"type": "object", "additionalProperties": {"type": "string"}},
+					"born": {"type": "string", "format": "date-time"},
+					"avatar": {"type": "string", "contentEncoding": "base64"},
+					"extra": {}
+				}
+			}
+		}
+	}`, marshal(t, s))
+
+	s, err = schema.Generator{TagName: "yaml"}.Generate(reflect.TypeOf(&Person{}))
+	require.NoError(t, err)
+	props := s.Defs["schema_test.Person"].Properties
+	assert.Contains(t, props, "full_name")
+	assert.Contains(t, props, "base", "yaml only inlines with the inline option")
+	assert.JSONEq(t, `{"type":"integer","minimum":0}`, marshal(t, props["age"]))
+	assert.JSONEq(t, `{"type":"array","items":{"type":"integer","minimum":0}}`, marshal(t, props["avatar"]))
+
+	s, err = schema.Generate(&complex.Complex{})
+	require.NoError(t, err)
+	assert.Equal(t, "#/$defs/testdata.complex.Complex", s.Ref, "proto messages are described in their protojson form")
+
+	_, err = schema.Generate(struct{ C chan int }{})
+	assert.EqualError(t, err, "schema: unsupported type chan int of field struct { C chan int }.C")
+}
+
+// nodeDescriptor returns the descriptor of a message with a oneof, referring
+// to itself in a map.
+func nodeDescriptor(t *testing.T) protoreflect.MessageDescriptor {
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(number),
+			Type:     typ.Enum(),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		}
+	}
+	children := field("children", 2, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE)
+	children.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	children.TypeName = proto.String(".tree.Node.ChildrenEntry")
+	text := field("text", 3, descriptorpb.FieldDescriptorProto_TYPE_STRING)
+	text.OneofIndex = proto.Int32(0)
+	count := field("count", 4, descriptorpb.FieldDescriptorProto_TYPE_INT32)
+	count.OneofIndex = proto.Int32(0)
+	value := field("value", 2, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE)
+	value.TypeName = proto.String(".tree.Node")
+
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("tree.proto"),
+		Package: proto.String("tree"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name:      proto.String("Node"),
+			Field:     []*descriptorpb.FieldDescriptorProto{field("name", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING), children, text, count},
+			OneofDecl: []*descriptorpb.OneofDescriptorProto{{Name: proto.String("value")}},
+			NestedType: []*descriptorpb.DescriptorProto{{
+				Name:    proto.String("ChildrenEntry"),
+				Field:   []*descriptorpb.FieldDescriptorProto{field("key", 1, descriptorpb.FieldDescriptorProto_TYPE_INT32), value},
+				Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+			}},
+		}},
+	}, nil)
+	require.NoError(t, err)
+	return fd.Messages().Get(0)
+}
+
+func TestValidator(t *testing.T) {
+	s, err := schema.Generate(&complex.Complex{})
+	require.NoError(t, err)
+	v, err := schema.NewValidator(s)
+	require.NoError(t, err)
+
+	valid := map[string]interface{}{
+		"id": "12", "numberOne": "a", "very_simple": map[string]interface{}{"component": "c"},
+		"sex": "woman", "timestamp": "2020-01-02T03:04:05Z", "duration": "1.5s", "price": "NaN",
+		"map": map[string]interface{}{"k": "v"}, "int32": json.Number("3"),
+	}
+	assert.NoError(t, v.Validate("json", valid, nil))
+
+	err = v.Validate("json", map[string]interface{}{
+		"id": "x", "sex": "other", "timestamp": "yesterday", "simples": []interface{}{"a", json.Number("1")},
+	}, func(pointer string) (int, int) { return len(pointer), 1 })
+	var serr *schema.Error
+	require.True(t, errors.As(err, &serr))
+	assert.Equal(t, "json", serr.Codec)
+	got := map[string]int{}
+	for _, vi := range serr.Violations {
+		assert.NotEmpty(t, vi.Message)
+		assert.NotEmpty(t, vi.Keyword)
+		got[vi.Pointer] = vi.Line
+	}
+	assert.Equal(t, map[string]int{"/id": 3, "/sex": 4, "/timestamp": 10, "/simples/1": 10}, got)
+
+	assert.NoError(t, (*schema.Validator)(nil).Validate("json", "anything", nil))
+
+	_, err = schema.Compile([]byte(`{"type": 1}`))
+	assert.Error(t, err)
+	_, err = schema.Compile([]byte(`{`))
+	assert.Error(t, err)
+}
+
+func TestCodecOutput(t *testing.T) {
+	s, err := schema.Generate(&complex.Complex{})
+	require.NoError(t, err)
+	v, err := schema.NewValidator(s)
+	require.NoError(t, err)
+
+	for name, c := range map[string]interface {
+		Marshal(v interface{}) ([]byte, error)
+		Unmarshal(data []byte, v interface{}) error
+	}{
+		"json": ejson.Codec{Schema: v},
+		"yaml": yaml.Codec{Schema: v, ProtoJSON: true},
+	} {
+		// the unset messages and enums are written as null.
+		for _, in := range []*complex.Complex{
+			{Id: 1},
+			{Id: 2, Simple: &complex.Simple{Component: "c"}, Sex: complex.Sex_woman, Timestamp: timestamppb.Now(),
+				Duration: durationpb.New(time.Second), String_: wrapperspb.String("s"), Price: float32(math.Inf(1))},
+		} {
+			b, err := c.Marshal(in)
+			require.NoError(t, err, name)
+			out := &complex.Complex{}
+			assert.NoError(t, c.Unmarshal(b, out), "%s: %s", name, b)
+			assert.True(t, proto.Equal(in, out), name)
+		}
+	}
+}
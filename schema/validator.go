@@ -0,0 +1,128 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// Validator validates documents against a JSON Schema, such as the documents
+// of the json and yaml codecs before they are decoded.
+type Validator struct {
+	s *jsonschema.Schema
+}
+
+// schemaURL names the compiled documents, which have no URL.
+const schemaURL = "mem:///schema.json"
+
+// Compile returns the validator of the JSON Schema document doc, of draft
+// 2020-12 if it has no $schema keyword.
+func Compile(doc []byte) (*Validator, error) {
+	c := jsonschema.NewCompiler()
+	c.Draft = jsonschema.Draft2020
+	c.AssertFormat = true
+	if err := c.AddResource(schemaURL, bytes.NewReader(doc)); err != nil {
+		return nil, fmt.Errorf("schema: %w", err)
+	}
+	s, err := c.Compile(schemaURL)
+	if err != nil {
+		return nil, fmt.Errorf("schema: %w", err)
+	}
+	return &Validator{s: s}, nil
+}
+
+// NewValidator returns the validator of s, such as a generated schema.
+func NewValidator(s *Schema) (*Validator, error) {
+	doc, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return Compile(doc)
+}
+
+// Position returns the line and the column, starting at 1, of the value at
+// the JSON Pointer pointer in the source of a document, zero if unknown.
+type Position func(pointer string) (line, column int)
+
+// Error is the error of a document failing validation.
+type Error struct {
+	// Codec is the name of the codec that read the document, such as json.
+	Codec string
+	// Violations are the failures, at least one.
+	Violations []Violation
+}
+
+// Violation is a validation failure.
+type Violation struct {
+	// Pointer is the JSON Pointer of the invalid value, such as
+	// /servers/0/port, empty for the document as a whole.
+	Pointer string
+	// Line and Column are the position of the invalid value in the source,
+	// starting at 1, zero if unknown.
+	Line, Column int
+	// Keyword is the location of the failing keyword in the schema, such as
+	// /properties/servers/items/properties/port/minimum.
+	Keyword string
+	// Message tells why the value is invalid.
+	Message string
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Codec)
+	b.WriteString(": invalid document: ")
+	for i, v := range e.Violations {
+		if i > 0 {
+			b.WriteString("; ")
+		}
+		loc := v.Pointer
+		if v.Line > 0 {
+			if loc != "" {
+				loc += " "
+			}
+			loc += fmt.Sprintf("(line %d, column %d)", v.Line, v.Column)
+		}
+		if loc != "" {
+			b.WriteString(loc)
+			b.WriteString(": ")
+		}
+		b.WriteString(v.Message)
+	}
+	return b.String()
+}
+
+// Validate validates doc, a document decoded by encoding/json with UseNumber
+// or made of the same types, returning nil or an *Error. pos locates the
+// invalid values in the source of the document read by the codec named
+// codec; it may be nil.
+func (v *Validator) Validate(codec string, doc interface{}, pos Position) error {
+	if v == nil {
+		return nil
+	}
+	err := v.s.Validate(doc)
+	var verr *jsonschema.ValidationError
+	if !errors.As(err, &verr) {
+		return err
+	}
+	e := &Error{Codec: codec}
+	var leaves func(*jsonschema.ValidationError)
+	leaves = func(ve *jsonschema.ValidationError) {
+		if len(ve.Causes) > 0 {
+			for _, c := range ve.Causes {
+				leaves(c)
+			}
+			return
+		}
+		vi := Violation{Pointer: ve.InstanceLocation, Keyword: ve.KeywordLocation, Message: ve.Message}
+		if pos != nil {
+			vi.Line, vi.Column = pos(vi.Pointer)
+		}
+		e.Violations = append(e.Violations, vi)
+	}
+	leaves(verr)
+	return e
+}
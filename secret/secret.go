@@ -0,0 +1,111 @@
+// Package secret resolves secret references, values such as
+// file:///run/secrets/db_password or secretref://db/password that stand for
+// the content they point to.
+//
+// The codecs supporting references have a Resolvers option; values that are
+// references to one of its schemes are replaced by their content before being
+// set on the decoded fields.
+package secret
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotFound is returned by the resolvers for references to missing secrets.
+var ErrNotFound = errors.New("secret: not found")
+
+// Resolver returns the content of the secret a reference points to.
+type Resolver interface {
+	Resolve(ref *url.URL) ([]byte, error)
+}
+
+// ResolverFunc is a function implementing Resolver.
+type ResolverFunc func(ref *url.URL) ([]byte, error)
+
+// Resolve calls f(ref).
+func (f ResolverFunc) Resolve(ref *url.URL) ([]byte, error) {
+	return f(ref)
+}
+
+// Resolvers maps the schemes of references to their resolver.
+type Resolvers map[string]Resolver
+
+// Resolve returns the content referenced by value, and false if value is not
+// a reference to one of the schemes of rs.
+func (rs Resolvers) Resolve(value string) ([]byte, bool, error) {
+	i := strings.IndexByte(value, ':')
+	if len(rs) == 0 || i <= 0 {
+		return nil, false, nil
+	}
+	r, ok := rs[strings.ToLower(value[:i])]
+	if !ok {
+		return nil, false, nil
+	}
+	ref, err := url.Parse(value)
+	if err != nil {
+		return nil, false, fmt.Errorf("secret: invalid reference %q: %w", value, err)
+	}
+	b, err := r.Resolve(ref)
+	if err != nil {
+		return nil, false, fmt.Errorf("secret: resolving %q: %w", value, err)
+	}
+	return b, true, nil
+}
+
+// File resolves file:// references by reading the files they name.
+type File struct {
+	// Root, if set, is the directory the paths of the references are
+	// relative to; references cannot point outside of it.
+	Root string
+	// TrimNewline removes a trailing newline from the content.
+	TrimNewline bool
+}
+
+// Resolve reads the file named by ref.
+func (f File) Resolve(ref *url.URL) ([]byte, error) {
+	name := ref.Path
+	if ref.Opaque != "" {
+		name = ref.Opaque
+	} else if ref.Host != "" && ref.Host != "localhost" {
+		name = ref.Host + ref.Path
+	}
+	if f.Root != "" {
+		rel := filepath.Clean(string(filepath.Separator) + filepath.FromSlash(name))
+		name = filepath.Join(f.Root, rel)
+	}
+
+	b, err := os.ReadFile(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if f.TrimNewline {
+		b = []byte(strings.TrimSuffix(strings.TrimSuffix(string(b), "\n"), "\r"))
+	}
+	return b, nil
+}
+
+// Memory resolves references by name from memory, the name of
+// secretref://db/password being db/password. It is meant for tests and for
+// secrets fetched ahead of decoding.
+type Memory map[string][]byte
+
+// Resolve returns the secret named by ref.
+func (m Memory) Resolve(ref *url.URL) ([]byte, error) {
+	name := ref.Opaque
+	if name == "" {
+		name = strings.TrimPrefix(ref.Host+ref.Path, "/")
+	}
+	b, ok := m[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return b, nil
+}
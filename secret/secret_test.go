@@ -0,0 +1,65 @@
+package secret
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolvers(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "db_password"), []byte("s3cret\n"), 0o600))
+
+	rs := Resolvers{
+		"file":      File{Root: dir, TrimNewline: true},
+		"secretref": Memory{"db/password": []byte("hunter2"), "token": []byte("t0k")},
+		"upper": ResolverFunc(func(ref *url.URL) ([]byte, error) {
+			return []byte(ref.Opaque + "!"), nil
+		}),
+	}
+
+	tests := []struct {
+		value string
+		want  string
+		ok    bool
+	}{
+		{"plain", "", false},
+		{"https://sraph.com/", "", false},
+		{"file:///db_password", "s3cret", true},
+		{"FILE:///db_password", "s3cret", true},
+		{"file:///../../db_password", "s3cret", true},
+		{"secretref://db/password", "hunter2", true},
+		{"secretref://token", "t0k", true},
+		{"secretref:token", "t0k", true},
+		{"upper:x", "x!", true},
+	}
+	for _, tt := range tests {
+		b, ok, err := rs.Resolve(tt.value)
+		require.NoError(t, err, tt.value)
+		assert.Equal(t, tt.ok, ok, tt.value)
+		assert.Equal(t, tt.want, string(b), tt.value)
+	}
+
+	_, _, err := rs.Resolve("secretref://missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, _, err = rs.Resolve("file:///missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	var none Resolvers
+	_, ok, err := none.Resolve("secretref://token")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "key")
+	require.NoError(t, os.WriteFile(name, []byte("line\n"), 0o600))
+
+	b, err := File{}.Resolve(&url.URL{Scheme: "file", Path: filepath.ToSlash(name)})
+	require.NoError(t, err)
+	assert.Equal(t, "line\n", string(b))
+}
@@ -0,0 +1,129 @@
+package toml
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+
+	"github.com/pelletier/go-toml/v2"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/sraphs/encoding/merge"
+	"github.com/sraphs/encoding/validate"
+)
+
+// Name is the name registered for the toml codec.
+const Name = "toml"
+
+var (
+	// MarshalOptions is a configurable JSON format marshaller used for proto messages.
+	MarshalOptions = protojson.MarshalOptions{}
+	// UnmarshalOptions is a configurable JSON format parser used for proto messages.
+	UnmarshalOptions = protojson.UnmarshalOptions{
+		DiscardUnknown: true,
+	}
+)
+
+// Codec is a Codec implementation with toml.
+type Codec struct {
+	// Merge is how Unmarshal combines data with the fields of the proto
+	// message already set. If zero, the message is reset.
+	Merge merge.Mode
+	// Validate validates the values decoded by Unmarshal, such as with their
+	// Validate methods, failing with a *validate.Error. No validation if nil.
+	Validate *validate.Registry
+}
+
+func (Codec) Marshal(v interface{}) ([]byte, error) {
+	switch m := v.(type) {
+	case proto.Message:
+		return marshalProto(m)
+	default:
+		return toml.Marshal(m)
+	}
+}
+
+func (c Codec) Unmarshal(data []byte, v interface{}) error {
+	if err := c.unmarshal(data, v); err != nil {
+		return err
+	}
+	return c.Validate.Validate(c.Name(), v)
+}
+
+func (c Codec) unmarshal(data []byte, v interface{}) error {
+	switch m := v.(type) {
+	case proto.Message:
+		return c.Merge.Decode(m, func(m proto.Message) error {
+			return unmarshalProto(data, m)
+		})
+	default:
+		rv := reflect.ValueOf(v)
+		for rv := rv; rv.Kind() == reflect.Ptr; {
+			if rv.IsNil() {
+				rv.Set(reflect.New(rv.Type().Elem()))
+			}
+			rv = rv.Elem()
+		}
+		if m, ok := reflect.Indirect(rv).Interface().(proto.Message); ok {
+			return c.Merge.Decode(m, func(m proto.Message) error {
+				return unmarshalProto(data, m)
+			})
+		}
+		return toml.Unmarshal(data, m)
+	}
+}
+
+func (Codec) Name() string {
+	return Name
+}
+
+// marshalProto writes m through its JSON mapping, so fields are keyed by their
+// JSON names and well-known types use their JSON string forms.
+func marshalProto(m proto.Message) ([]byte, error) {
+	b, err := MarshalOptions.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var doc map[string]interface{}
+	if err := d.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return toml.Marshal(normalizeNumbers(doc))
+}
+
+func unmarshalProto(data []byte, m proto.Message) error {
+	var doc map[string]interface{}
+	if err := toml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return UnmarshalOptions.Unmarshal(b, m)
+}
+
+// normalizeNumbers replaces json.Number values with int64 or float64 so that
+// integers are not written as TOML floats.
+func normalizeNumbers(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, e := range x {
+			x[k] = normalizeNumbers(e)
+		}
+	case []interface{}:
+		for i, e := range x {
+			x[i] = normalizeNumbers(e)
+		}
+	case json.Number:
+		if i, err := x.Int64(); err == nil {
+			return i
+		}
+		f, _ := x.Float64()
+		return f
+	}
+	return v
+}
@@ -0,0 +1,85 @@
+package toml
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	testData "github.com/sraphs/encoding/internal/testdata/complex"
+)
+
+type testEmbed struct {
+	Level1a int `toml:"a"`
+	Level1b int `toml:"b"`
+}
+
+type testMessage struct {
+	Field1 string     `toml:"a"`
+	Field2 []string   `toml:"b"`
+	Embed  *testEmbed `toml:"embed,omitempty"`
+}
+
+func TestCodec_Struct(t *testing.T) {
+	in := &testMessage{Field1: "a", Field2: []string{"b", "c"}, Embed: &testEmbed{Level1a: 1, Level1b: 2}}
+
+	b, err := (Codec{}).Marshal(in)
+	require.NoError(t, err)
+	assert.Equal(t, "a = 'a'\nb = ['b', 'c']\n[embed]\na = 1\nb = 2\n\n", string(b))
+
+	out := &testMessage{}
+	require.NoError(t, (Codec{}).Unmarshal(b, out))
+	assert.True(t, reflect.DeepEqual(in, out))
+
+	var m map[string]interface{}
+	require.NoError(t, (Codec{}).Unmarshal(b, &m))
+	assert.Equal(t, "a", m["a"])
+}
+
+func TestCodec_Proto(t *testing.T) {
+	in := &testData.Complex{
+		Id:        2233,
+		NoOne:     "2233",
+		Simple:    &testData.Simple{Component: "5566"},
+		Simples:   []string{"3344", "5566"},
+		B:         true,
+		Sex:       testData.Sex_woman,
+		Age:       18,
+		Price:     11.25,
+		Byte:      []byte("123"),
+		Map:       map[string]string{"sraph": "https://sraph.com/"},
+		Timestamp: &timestamppb.Timestamp{Seconds: 20, Nanos: 2},
+		Duration:  &durationpb.Duration{Seconds: 120, Nanos: 22},
+		Field:     &fieldmaskpb.FieldMask{Paths: []string{"a.b", "b.c"}},
+		Int32:     &wrapperspb.Int32Value{Value: 32},
+		String_:   &wrapperspb.StringValue{Value: "sraph"},
+	}
+
+	b, err := (Codec{}).Marshal(in)
+	require.NoError(t, err)
+	s := string(b)
+	assert.Contains(t, s, "numberOne = '2233'")
+	assert.Contains(t, s, "age = 18")
+	assert.Contains(t, s, "sex = 'woman'")
+	assert.Contains(t, s, "duration = '120.000000022s'")
+	assert.Contains(t, s, "timestamp = '1970-01-01T00:00:20.000000002Z'")
+	assert.Contains(t, s, "[very_simple]\ncomponent = '5566'")
+
+	out := &testData.Complex{}
+	require.NoError(t, (Codec{}).Unmarshal(b, out))
+	assert.True(t, proto.Equal(in, out), "got %v", out)
+
+	doc := []byte("id = 1\nsex = 'woman'\ntimestamp = 1970-01-01T00:00:20Z\n[very_simple]\ncomponent = 'x'\n")
+	out2 := &testData.Complex{}
+	require.NoError(t, (Codec{}).Unmarshal(doc, &out2))
+	assert.Equal(t, int64(1), out2.Id)
+	assert.Equal(t, testData.Sex_woman, out2.Sex)
+	assert.Equal(t, int64(20), out2.Timestamp.GetSeconds())
+	assert.Equal(t, "x", out2.Simple.GetComponent())
+}
@@ -0,0 +1,271 @@
+// Package validate runs the validation of the values decoded by the codecs,
+// so that invalid input never reaches the code using them.
+//
+// A value is validated by the function registered for its type, then by its
+// ValidateAll or Validate method, such as the methods generated by
+// protoc-gen-validate. The elements of slices without their own validation
+// are validated one by one. The failures are returned as an *Error telling
+// the paths of the fields, read from the errors of protoc-gen-validate and
+// from the errors with the same Field, Reason and Cause methods.
+package validate
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Func validates a decoded value of the type it is registered for.
+type Func func(v interface{}) error
+
+// Registry is a set of validation functions keyed by type. A nil *Registry
+// validates nothing, leaving the validation to the caller; New returns a
+// registry calling the validation methods only.
+//
+// Registering is not safe for concurrent use with the codecs using the
+// registry; register the functions before.
+type Registry struct {
+	funcs map[reflect.Type]Func
+}
+
+// New returns a registry without functions.
+func New() *Registry {
+	return &Registry{funcs: make(map[reflect.Type]Func)}
+}
+
+// Register registers fn for the type of typ, replacing any previous one.
+// Functions registered for a type also apply to pointers to it, and the
+// other way around.
+func (r *Registry) Register(typ interface{}, fn Func) {
+	r.funcs[reflect.TypeOf(typ)] = fn
+}
+
+// Error is the error of a decoded value failing validation.
+type Error struct {
+	// Codec is the name of the codec that decoded the value, such as json.
+	Codec string
+	// Violations are the failures, at least one.
+	Violations []Violation
+	err        error
+}
+
+// Violation is a validation failure.
+type Violation struct {
+	// Field is the path of the field, such as server.port or tags[0], empty
+	// for the value as a whole. The fields of proto messages are named by
+	// their proto names.
+	Field string
+	// Reason tells why the value is invalid.
+	Reason string
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Codec)
+	b.WriteString(": invalid value: ")
+	for i, v := range e.Violations {
+		if i > 0 {
+			b.WriteString("; ")
+		}
+		if v.Field != "" {
+			b.WriteString(v.Field)
+			b.WriteString(": ")
+		}
+		b.WriteString(v.Reason)
+	}
+	return b.String()
+}
+
+// Unwrap returns the error of the validation.
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// fieldError is the error of a field, as generated by protoc-gen-validate.
+type fieldError interface {
+	Field() string
+	Reason() string
+	Cause() error
+}
+
+// multiError holds the errors of ValidateAll, as generated by
+// protoc-gen-validate.
+type multiError interface {
+	AllErrors() []error
+}
+
+type validator interface {
+	Validate() error
+}
+
+type allValidator interface {
+	ValidateAll() error
+}
+
+// Validate validates v, the value decoded by the codec named codec, returning
+// nil or an *Error.
+func (r *Registry) Validate(codec string, v interface{}) error {
+	if r == nil || v == nil {
+		return nil
+	}
+	var vs []Violation
+	var errs []error
+	r.validate(reflect.ValueOf(v), "", func(field string, md protoreflect.MessageDescriptor, err error) {
+		vs = violations(vs, field, md, err)
+		errs = append(errs, err)
+	})
+	if len(vs) == 0 {
+		return nil
+	}
+	return &Error{Codec: codec, Violations: vs, err: errs[0]}
+}
+
+// validate validates rv, at the path field, calling fail with its errors.
+func (r *Registry) validate(rv reflect.Value, field string, fail func(string, protoreflect.MessageDescriptor, error)) {
+	for rv.Kind() == reflect.Interface || rv.Kind() == reflect.Ptr && !r.validates(rv.Type()) {
+		if rv.IsNil() {
+			return
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return
+	}
+	if !r.validates(rv.Type()) {
+		if (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array) && rv.Type().Elem().Kind() != reflect.Uint8 {
+			for i := 0; i < rv.Len(); i++ {
+				r.validate(rv.Index(i), fmt.Sprintf("%s[%d]", field, i), fail)
+			}
+		}
+		return
+	}
+	if rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return
+	}
+	p := pointer(rv)
+	var md protoreflect.MessageDescriptor
+	if m, ok := p.Interface().(proto.Message); ok {
+		md = m.ProtoReflect().Descriptor()
+	}
+	if fn, ok := r.funcs[p.Type()]; ok {
+		if err := fn(p.Interface()); err != nil {
+			fail(field, md, err)
+		}
+	} else if fn, ok := r.funcs[p.Type().Elem()]; ok {
+		if err := fn(p.Elem().Interface()); err != nil {
+			fail(field, md, err)
+		}
+	}
+	switch x := p.Interface().(type) {
+	case allValidator:
+		if err := x.ValidateAll(); err != nil {
+			fail(field, md, err)
+		}
+	case validator:
+		if err := x.Validate(); err != nil {
+			fail(field, md, err)
+		}
+	}
+}
+
+// validates reports whether the values of t have their own validation.
+func (r *Registry) validates(t reflect.Type) bool {
+	pt := t
+	if t.Kind() != reflect.Ptr {
+		pt = reflect.PtrTo(t)
+	}
+	_, ok := r.funcs[pt]
+	_, okElem := r.funcs[pt.Elem()]
+	return ok || okElem || pt.Implements(allValidatorType) || pt.Implements(validatorType)
+}
+
+var (
+	validatorType    = reflect.TypeOf((*validator)(nil)).Elem()
+	allValidatorType = reflect.TypeOf((*allValidator)(nil)).Elem()
+)
+
+// pointer returns a pointer to the value of rv, rv itself if a pointer.
+func pointer(rv reflect.Value) reflect.Value {
+	if rv.Kind() == reflect.Ptr {
+		return rv
+	}
+	if rv.CanAddr() {
+		return rv.Addr()
+	}
+	p := reflect.New(rv.Type())
+	p.Elem().Set(rv)
+	return p
+}
+
+// violations adds to vs the violations of err, at the path field of a value
+// of the message md, nil if not a proto message.
+func violations(vs []Violation, field string, md protoreflect.MessageDescriptor, err error) []Violation {
+	switch e := err.(type) {
+	case multiError:
+		for _, err := range e.AllErrors() {
+			vs = violations(vs, field, md, err)
+		}
+		return vs
+	case interface{ Unwrap() []error }:
+		for _, err := range e.Unwrap() {
+			vs = violations(vs, field, md, err)
+		}
+		return vs
+	case fieldError:
+		path, fd := fieldPath(md, e.Field())
+		path = joinPath(field, path)
+		switch cause := e.Cause(); cause.(type) {
+		case nil:
+		case fieldError, multiError:
+			var sub protoreflect.MessageDescriptor
+			if fd != nil {
+				sub = fd.Message()
+				if fd.IsMap() {
+					sub = fd.MapValue().Message()
+				}
+			}
+			return violations(vs, path, sub, cause)
+		default:
+			return append(vs, Violation{Field: path, Reason: e.Reason() + ": " + cause.Error()})
+		}
+		return append(vs, Violation{Field: path, Reason: e.Reason()})
+	default:
+		return append(vs, Violation{Field: field, Reason: err.Error()})
+	}
+}
+
+// fieldPath returns the path of the field named name in an error of md,
+// with its proto name, and the field. protoc-gen-validate names the fields by
+// their Go names, followed by the index or the key of the element for lists
+// and maps, such as Tags[0].
+func fieldPath(md protoreflect.MessageDescriptor, name string) (string, protoreflect.FieldDescriptor) {
+	if md == nil {
+		return name, nil
+	}
+	elem, suffix := name, ""
+	if i := strings.IndexByte(name, '['); i >= 0 {
+		elem, suffix = name[:i], name[i:]
+	}
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if strings.EqualFold(strings.ReplaceAll(string(fd.Name()), "_", ""), elem) {
+			return string(fd.Name()) + suffix, fd
+		}
+	}
+	return name, nil
+}
+
+func joinPath(prefix, path string) string {
+	switch {
+	case prefix == "":
+		return path
+	case path == "":
+		return prefix
+	default:
+		return prefix + "." + path
+	}
+}
@@ -0,0 +1,148 @@
+package validate_test
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sraphs/encoding/env"
+	"github.com/sraphs/encoding/internal/testdata/complex"
+	"github.com/sraphs/encoding/json"
+	"github.com/sraphs/encoding/ndjson"
+	"github.com/sraphs/encoding/validate"
+	"github.com/sraphs/encoding/yaml"
+)
+
+// fieldError is an error as generated by protoc-gen-validate.
+type fieldError struct {
+	field  string
+	reason string
+	cause  error
+}
+
+func (e fieldError) Field() string  { return e.field }
+func (e fieldError) Reason() string { return e.reason }
+func (e fieldError) Cause() error   { return e.cause }
+func (e fieldError) Error() string  { return "invalid " + e.field + ": " + e.reason }
+
+type multiError []error
+
+func (m multiError) Error() string      { return m[0].Error() }
+func (m multiError) AllErrors() []error { return m }
+
+type server struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+}
+
+func (s *server) Validate() error {
+	if s.Port <= 0 {
+		return fieldError{field: "port", reason: "must be positive"}
+	}
+	return nil
+}
+
+type config struct {
+	Name    string   `json:"name"`
+	Servers []server `json:"servers"`
+}
+
+func TestRegistry(t *testing.T) {
+	r := validate.New()
+	r.Register(config{}, func(v interface{}) error {
+		if v.(config).Name == "" {
+			return errors.New("no name")
+		}
+		return nil
+	})
+
+	assert.NoError(t, r.Validate("json", &config{Name: "a", Servers: []server{{Port: 1}}}))
+
+	err := r.Validate("json", &config{Servers: []server{{Port: 1}, {Port: 0}}})
+	var verr *validate.Error
+	require.True(t, errors.As(err, &verr))
+	assert.Equal(t, []validate.Violation{{Reason: "no name"}}, verr.Violations,
+		"the registered function replaces the walk of the fields")
+	assert.EqualError(t, err, "json: invalid value: no name")
+
+	err = r.Validate("ndjson", &[]server{{Port: 1}, {Port: 0}})
+	assert.EqualError(t, err, "ndjson: invalid value: [1].port: must be positive")
+
+	assert.NoError(t, (*validate.Registry)(nil).Validate("json", &server{}), "nil validates nothing")
+}
+
+func TestProto(t *testing.T) {
+	r := validate.New()
+	r.Register(&complex.Complex{}, func(v interface{}) error {
+		m := v.(*complex.Complex)
+		var errs multiError
+		if m.NoOne == "" {
+			errs = append(errs, fieldError{field: "NoOne", reason: "value length must be at least 1 runes"})
+		}
+		if m.GetSimple().GetComponent() == "" {
+			errs = append(errs, fieldError{field: "Simple", reason: "embedded message failed validation",
+				cause: fieldError{field: "Component", reason: "value is required"}})
+		}
+		for i, s := range m.Simples {
+			if s == "" {
+				errs = append(errs, fieldError{field: "Simples[" + strconv.Itoa(i) + "]", reason: "value is empty",
+					cause: errors.New("empty")})
+			}
+		}
+		if len(errs) == 0 {
+			return nil
+		}
+		return errs
+	})
+
+	err := r.Validate("json", &complex.Complex{Simples: []string{"a", ""}, Simple: &complex.Simple{}})
+	var verr *validate.Error
+	require.True(t, errors.As(err, &verr))
+	assert.Equal(t, []validate.Violation{
+		{Field: "no_one", Reason: "value length must be at least 1 runes"},
+		{Field: "simple.component", Reason: "value is required"},
+		{Field: "simples[1]", Reason: "value is empty: empty"},
+	}, verr.Violations)
+	var merr multiError
+	assert.True(t, errors.As(err, &merr), "the error of the validation is wrapped")
+
+	assert.NoError(t, r.Validate("json", &complex.Complex{NoOne: "a", Simple: &complex.Simple{Component: "c"}}))
+}
+
+func TestCodecs(t *testing.T) {
+	r := validate.New()
+	r.Register(&complex.Complex{}, func(v interface{}) error {
+		if v.(*complex.Complex).Age < 0 {
+			return fieldError{field: "Age", reason: "value must be greater than or equal to 0"}
+		}
+		return nil
+	})
+
+	for name, tt := range map[string]struct {
+		unmarshal func([]byte, interface{}) error
+		valid     string
+		invalid   string
+	}{
+		"json": {json.Codec{Validate: r}.Unmarshal, `{"age":1}`, `{"age":-1}`},
+		"yaml": {yaml.Codec{Validate: r}.Unmarshal, "age: 1", "age: -1"},
+		"env":  {env.Codec{Validate: r}.Unmarshal, "AGE=1", "AGE=-1"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.NoError(t, tt.unmarshal([]byte(tt.valid), &complex.Complex{}))
+
+			err := tt.unmarshal([]byte(tt.invalid), &complex.Complex{})
+			var verr *validate.Error
+			require.True(t, errors.As(err, &verr), "got %v", err)
+			assert.Equal(t, name, verr.Codec)
+			assert.Equal(t, []validate.Violation{{Field: "age", Reason: "value must be greater than or equal to 0"}}, verr.Violations)
+		})
+	}
+
+	var servers []server
+	err := ndjson.Codec{Validate: validate.New()}.Unmarshal([]byte("{\"port\":1}\n{\"port\":0}\n"), &servers)
+	assert.EqualError(t, err, "x-ndjson: invalid value: [1].port: must be positive")
+	assert.Len(t, servers, 2)
+}
@@ -2,20 +2,37 @@ package xml
 
 import (
 	"encoding/xml"
+
+	"github.com/sraphs/encoding/redact"
+	"github.com/sraphs/encoding/validate"
 )
 
 // Name is the name registered for the xml codec.
 const Name = "xml"
 
 // Codec is a Codec implementation with xml.
-type Codec struct{}
+type Codec struct {
+	// Redact replaces the values of the sensitive fields, the struct fields
+	// tagged sensitive:"true" and the proto fields with the debug_redact
+	// option, by redact.Placeholder in the output of Marshal.
+	Redact bool
+	// Validate validates the values decoded by Unmarshal, such as with their
+	// Validate methods, failing with a *validate.Error. No validation if nil.
+	Validate *validate.Registry
+}
 
-func (Codec) Marshal(v interface{}) ([]byte, error) {
+func (c Codec) Marshal(v interface{}) ([]byte, error) {
+	if c.Redact {
+		v = redact.Copy(v)
+	}
 	return xml.Marshal(v)
 }
 
-func (Codec) Unmarshal(data []byte, v interface{}) error {
-	return xml.Unmarshal(data, v)
+func (c Codec) Unmarshal(data []byte, v interface{}) error {
+	if err := xml.Unmarshal(data, v); err != nil {
+		return err
+	}
+	return c.Validate.Validate(c.Name(), v)
 }
 
 func (Codec) Name() string {
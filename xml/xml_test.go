@@ -115,3 +115,19 @@ func TestCodec_NilUnmarshal(t *testing.T) {
 		}
 	}
 }
+
+func TestCodec_Redact(t *testing.T) {
+	in := &struct {
+		XMLName  struct{} `xml:"login"`
+		User     string   `xml:"user"`
+		Password string   `xml:"password" sensitive:"true"`
+	}{User: "sraph", Password: "hunter2"}
+
+	b, err := (Codec{Redact: true}).Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "<login><user>sraph</user><password>[REDACTED]</password></login>"; string(b) != want {
+		t.Errorf("expected %s, got %s", want, b)
+	}
+}
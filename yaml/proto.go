@@ -0,0 +1,339 @@
+package yaml
+
+import (
+	"math"
+	"reflect"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"gopkg.in/yaml.v3"
+
+	"github.com/sraphs/encoding/internal/protopath"
+	"github.com/sraphs/encoding/json"
+)
+
+// protoMessage returns the proto message v points to, allocating the nil
+// pointers on the way.
+func protoMessage(v interface{}) (proto.Message, bool) {
+	if m, ok := v.(proto.Message); ok {
+		return m, true
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return nil, false
+	}
+	if _, ok := reflect.Zero(rv.Type().Elem()).Interface().(proto.Message); !ok {
+		return nil, false
+	}
+	if rv.Elem().IsNil() {
+		rv.Elem().Set(reflect.New(rv.Type().Elem().Elem()))
+	}
+	m, ok := rv.Elem().Interface().(proto.Message)
+	return m, ok
+}
+
+// protoJSON reports whether m is written and read in its protojson form:
+// the messages without Go fields are, and the others with the ProtoJSON and
+// Types options.
+func (c Codec) protoJSON(m proto.Message) bool {
+	_, dynamic := m.(*dynamicpb.Message)
+	return dynamic || c.ProtoJSON || c.Types != nil
+}
+
+// marshalMasked writes the Go struct of m, with the keys of the fields c.Mask
+// does not mask removed.
+func (c Codec) marshalMasked(m proto.Message) ([]byte, error) {
+	mask, err := protopath.NewMask(m.ProtoReflect().Descriptor(), c.Mask)
+	if err != nil {
+		return nil, err
+	}
+	var n yaml.Node
+	if err := n.Encode(m); err != nil {
+		return nil, err
+	}
+	filterNode(&n, mask, reflect.TypeOf(m))
+	return yaml.Marshal(&n)
+}
+
+// filterNode removes from n, the mapping of the Go struct t of a message, the
+// keys of the fields mask does not mask.
+func filterNode(n *yaml.Node, mask protopath.Mask, t reflect.Type) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if mask == nil || n.Kind != yaml.MappingNode || t.Kind() != reflect.Struct {
+		return
+	}
+	content := n.Content[:0]
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := n.Content[i], n.Content[i+1]
+		sf, ok := yamlField(t, k.Value)
+		if !ok {
+			continue
+		}
+		sub, masked := mask.Field(protoName(sf))
+		if !masked {
+			continue
+		}
+		filterNode(v, sub, sf.Type)
+		content = append(content, k, v)
+	}
+	n.Content = content
+}
+
+// protoName returns the proto name of the field of a message of its protobuf
+// struct tag, "" if it has none.
+func protoName(sf reflect.StructField) protoreflect.Name {
+	for _, opt := range strings.Split(sf.Tag.Get("protobuf"), ",") {
+		if strings.HasPrefix(opt, "name=") {
+			return protoreflect.Name(strings.TrimPrefix(opt, "name="))
+		}
+	}
+	return ""
+}
+
+// marshalProto writes m in its protojson form, as block style YAML.
+func (c Codec) marshalProto(m proto.Message) ([]byte, error) {
+	b, err := json.Codec{Types: c.Types, Mask: c.Mask}.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	var n yaml.Node
+	if err := yaml.Unmarshal(b, &n); err != nil {
+		return nil, err
+	}
+	blockStyle(&n)
+	return yaml.Marshal(&n)
+}
+
+// unmarshalProto reads the document n into m as its protojson form.
+func (c Codec) unmarshalProto(n *yaml.Node, m proto.Message) error {
+	if n.Kind == 0 {
+		return nil
+	}
+	doc, err := jsonValue(n, nil, m.ProtoReflect().Descriptor())
+	if err != nil {
+		return err
+	}
+	b, err := json.Codec{}.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return json.Codec{Types: c.Types, Merge: c.Merge}.Unmarshal(b, m)
+}
+
+// UnmarshalMask is Unmarshal for proto messages, also returning the mask of
+// the fields present in data, such as the fields to update of a PATCH
+// request.
+func (c Codec) UnmarshalMask(data []byte, m proto.Message) (*fieldmaskpb.FieldMask, error) {
+	if err := c.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	var n yaml.Node
+	if err := yaml.Unmarshal(data, &n); err != nil {
+		return nil, err
+	}
+	md := m.ProtoReflect().Descriptor()
+	var doc interface{}
+	if c.protoJSON(m) {
+		var err error
+		if doc, err = jsonValue(&n, nil, md); err != nil {
+			return nil, err
+		}
+	} else {
+		doc = structDoc(&n, reflect.TypeOf(m))
+	}
+	obj, _ := doc.(map[string]interface{})
+	return protopath.JSONMask(md, obj), nil
+}
+
+// structDoc returns the document n, read into the Go struct t of a message,
+// as a protojson document for protopath.JSONMask: its mappings are keyed by
+// the proto names of the fields, and the other values are left out.
+func structDoc(n *yaml.Node, t reflect.Type) interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return nil
+		}
+		return structDoc(n.Content[0], t)
+	case yaml.AliasNode:
+		return structDoc(n.Alias, t)
+	case yaml.MappingNode:
+		if t.Kind() != reflect.Struct {
+			return nil
+		}
+		out := make(map[string]interface{}, len(n.Content)/2)
+		// merged keys come first, the keys of n override them.
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].ShortTag() == "!!merge" {
+				mergeValue(out, structDoc(n.Content[i+1], t))
+			}
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			if k.ShortTag() == "!!merge" {
+				continue
+			}
+			if sf, ok := yamlField(t, k.Value); ok && protoName(sf) != "" {
+				out[string(protoName(sf))] = structDoc(v, sf.Type)
+			}
+		}
+		return out
+	case yaml.SequenceNode:
+		out := make([]interface{}, len(n.Content))
+		for i, c := range n.Content {
+			out[i] = structDoc(c, t)
+		}
+		return out
+	}
+	return nil
+}
+
+// blockStyle clears the flow and quoting styles of the nodes under n, the
+// encoder quoting the strings that need it.
+func blockStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, c := range n.Content {
+		blockStyle(c)
+	}
+}
+
+// jsonValue returns the value of n for encoding/json. fd is the field n is
+// the value of, or md the message n is, nil if unknown: the scalars of
+// string fields are taken as strings, whatever they look like.
+func jsonValue(n *yaml.Node, fd protoreflect.FieldDescriptor, md protoreflect.MessageDescriptor) (interface{}, error) {
+	if fd != nil && !fd.IsMap() {
+		md = fd.Message()
+	}
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return nil, nil
+		}
+		return jsonValue(n.Content[0], fd, md)
+	case yaml.AliasNode:
+		return jsonValue(n.Alias, fd, md)
+	case yaml.SequenceNode:
+		out := make([]interface{}, len(n.Content))
+		for i, c := range n.Content {
+			v, err := jsonValue(c, fd, md)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = v
+		}
+		return out, nil
+	case yaml.MappingNode:
+		out := make(map[string]interface{}, len(n.Content)/2)
+		// merged keys come first, the keys of n override them.
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].ShortTag() != "!!merge" {
+				continue
+			}
+			merged, err := jsonValue(n.Content[i+1], fd, md)
+			if err != nil {
+				return nil, err
+			}
+			mergeValue(out, merged)
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			if k.ShortTag() == "!!merge" {
+				continue
+			}
+			var vfd protoreflect.FieldDescriptor
+			switch {
+			case fd != nil && fd.IsMap():
+				vfd = fd.MapValue()
+			case md != nil:
+				vfd = md.Fields().ByJSONName(k.Value)
+				if vfd == nil {
+					vfd = md.Fields().ByName(protoreflect.Name(k.Value))
+				}
+			}
+			value, err := jsonValue(v, vfd, nil)
+			if err != nil {
+				return nil, err
+			}
+			out[k.Value] = value
+		}
+		return out, nil
+	}
+
+	switch tag := n.ShortTag(); {
+	case tag == "!!null":
+		return nil, nil
+	case isStringField(fd):
+		return n.Value, nil
+	case tag == "!!bool" || tag == "!!int" || tag == "!!float":
+		var v interface{}
+		if err := n.Decode(&v); err != nil {
+			return nil, err
+		}
+		if f, ok := v.(float64); ok {
+			// the protojson forms of the special floats.
+			switch {
+			case math.IsNaN(f):
+				return "NaN", nil
+			case math.IsInf(f, 1):
+				return "Infinity", nil
+			case math.IsInf(f, -1):
+				return "-Infinity", nil
+			}
+		}
+		return v, nil
+	default:
+		return n.Value, nil
+	}
+}
+
+// mergeValue adds the keys of the merged mappings to out.
+func mergeValue(out map[string]interface{}, merged interface{}) {
+	switch merged := merged.(type) {
+	case map[string]interface{}:
+		for k, v := range merged {
+			out[k] = v
+		}
+	case []interface{}:
+		// the first mappings of a sequence take precedence.
+		for i := len(merged) - 1; i >= 0; i-- {
+			mergeValue(out, merged[i])
+		}
+	}
+}
+
+// isBytesField reports whether the values of fd are bytes, base64 encoded in
+// the protojson form.
+func isBytesField(fd protoreflect.FieldDescriptor) bool {
+	if fd == nil {
+		return false
+	}
+	return fd.Kind() == protoreflect.BytesKind ||
+		fd.Message() != nil && fd.Message().FullName() == "google.protobuf.BytesValue"
+}
+
+// isStringField reports whether the protojson form of the values of fd is a
+// string.
+func isStringField(fd protoreflect.FieldDescriptor) bool {
+	if fd == nil {
+		return false
+	}
+	switch fd.Kind() {
+	case protoreflect.StringKind, protoreflect.BytesKind:
+		return true
+	case protoreflect.MessageKind:
+		switch fd.Message().FullName() {
+		case "google.protobuf.Timestamp", "google.protobuf.Duration", "google.protobuf.FieldMask",
+			"google.protobuf.StringValue", "google.protobuf.BytesValue":
+			return true
+		}
+	}
+	return false
+}
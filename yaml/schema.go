@@ -0,0 +1,87 @@
+package yaml
+
+import (
+	"strconv"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"gopkg.in/yaml.v3"
+
+	"github.com/sraphs/encoding/schema"
+)
+
+// validateSchema validates the document n against c.Schema. md is the
+// message n decodes into, nil if not a proto message.
+func (c Codec) validateSchema(n *yaml.Node, md protoreflect.MessageDescriptor) error {
+	var doc interface{}
+	if n.Kind != 0 {
+		var err error
+		if doc, err = jsonValue(n, nil, md); err != nil {
+			return err
+		}
+	}
+	var nodes map[string]*yaml.Node
+	return c.Schema.Validate(Name, doc, func(pointer string) (int, int) {
+		if nodes == nil {
+			nodes = make(map[string]*yaml.Node)
+			valueNodes(n, "", nodes)
+		}
+		if vn, ok := nodes[pointer]; ok {
+			return vn.Line, vn.Column
+		}
+		return 0, 0
+	})
+}
+
+// valueNodes adds to nodes the nodes of the values under n, keyed by JSON
+// Pointer.
+func valueNodes(n *yaml.Node, pointer string, nodes map[string]*yaml.Node) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) > 0 {
+			valueNodes(n.Content[0], pointer, nodes)
+		}
+	case yaml.AliasNode:
+		// the values under the alias are located at the anchor.
+		valueNodes(n.Alias, pointer, nodes)
+		nodes[pointer] = n
+	case yaml.SequenceNode:
+		nodes[pointer] = n
+		for i, c := range n.Content {
+			valueNodes(c, pointer+"/"+strconv.Itoa(i), nodes)
+		}
+	case yaml.MappingNode:
+		nodes[pointer] = n
+		// merged keys come first, the keys of n override them.
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].ShortTag() == "!!merge" {
+				mergedNodes(n.Content[i+1], pointer, nodes)
+			}
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			if k.ShortTag() != "!!merge" {
+				valueNodes(v, pointer+"/"+schema.EscapeToken(k.Value), nodes)
+			}
+		}
+	default:
+		nodes[pointer] = n
+	}
+}
+
+// mergedNodes adds the nodes of the values of the mappings merged by n into
+// the mapping at pointer.
+func mergedNodes(n *yaml.Node, pointer string, nodes map[string]*yaml.Node) {
+	switch n.Kind {
+	case yaml.AliasNode:
+		mergedNodes(n.Alias, pointer, nodes)
+	case yaml.SequenceNode:
+		// the first mappings of a sequence take precedence.
+		for i := len(n.Content) - 1; i >= 0; i-- {
+			mergedNodes(n.Content[i], pointer, nodes)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			valueNodes(n.Content[i+1], pointer+"/"+schema.EscapeToken(n.Content[i].Value), nodes)
+		}
+	}
+}
@@ -1,23 +1,287 @@
+// Package yaml defines the yaml codec.
+//
+// The generated proto messages are written and read as the Go structs they
+// are by gopkg.in/yaml.v3: the fields are named by their lowercased Go names,
+// such as noone, enums are numbers and the well-known types plain messages,
+// such as {seconds: 3, nanos: 0} for a Duration.
+//
+// The other messages, such as dynamicpb ones, have no Go fields and are
+// written and read in their protojson form, the form of the json codec: the
+// fields are named by their JSON names, such as numberOne, 64-bit integers
+// are strings, enums are written by name and the well-known types in their
+// JSON forms, such as 3s for a Duration. Unmarshal also reads the proto names
+// of the fields, and enums and 64-bit integers as numbers. The ProtoJSON and
+// Types options use this form for all the messages.
 package yaml
 
 import (
+	"encoding/base64"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 	"gopkg.in/yaml.v3"
+
+	"github.com/sraphs/encoding/interpolate"
+	"github.com/sraphs/encoding/merge"
+	"github.com/sraphs/encoding/redact"
+	"github.com/sraphs/encoding/schema"
+	"github.com/sraphs/encoding/secret"
+	"github.com/sraphs/encoding/validate"
 )
 
 // Name is the name registered for the yaml codec.
 const Name = "yaml"
 
 // Codec is a Codec implementation with yaml.
-type Codec struct{}
+type Codec struct {
+	// Interpolate expands the ${VAR} references of the string values. See
+	// the interpolate package for the syntax.
+	Interpolate bool
+	// Lookup resolves the references, interpolate.Env if nil.
+	Lookup interpolate.LookupFunc
+	// Resolvers replaces the string values that are secret references, such
+	// as file:///run/secrets/db_password, by their content. References are
+	// left as is if nil.
+	Resolvers secret.Resolvers
+	// Redact replaces the values of the sensitive fields, the struct fields
+	// tagged sensitive:"true" and the proto fields with the debug_redact
+	// option, by redact.Placeholder in the output of Marshal.
+	Redact bool
+	// Types resolves the google.protobuf.Any values and the extensions of
+	// proto messages, such as the types of a dynamic.Registry for dynamicpb
+	// messages, protoregistry.GlobalTypes if nil. If set, all the proto
+	// messages are written and read in their protojson form.
+	Types *protoregistry.Types
+	// ProtoJSON writes and reads the generated proto messages in their
+	// protojson form, as the dynamicpb messages are, instead of as Go
+	// structs.
+	ProtoJSON bool
+	// Mask limits the output of Marshal for proto messages to the fields of
+	// its paths, such as server.port, all of them if nil.
+	Mask *fieldmaskpb.FieldMask
+	// Merge is how Unmarshal combines data with the fields of the proto
+	// message already set. If zero, the message is reset in the protojson
+	// form, and the fields of data are set in the Go struct otherwise.
+	Merge merge.Mode
+	// Schema validates the documents read by Unmarshal before decoding them,
+	// failing with a *schema.Error telling the JSON Pointers and the lines
+	// and columns of the invalid values. Proto messages are validated in the
+	// form they are read in: the schemas generated by the schema package
+	// describe the protojson form. No validation if nil.
+	Schema *schema.Validator
+	// Validate validates the values decoded by Unmarshal, such as with their
+	// Validate methods, failing with a *validate.Error. No validation if nil.
+	Validate *validate.Registry
+}
 
-func (Codec) Marshal(v interface{}) ([]byte, error) {
+func (c Codec) Marshal(v interface{}) ([]byte, error) {
+	if c.Redact {
+		v = redact.Copy(v)
+	}
+	if m, ok := v.(proto.Message); ok {
+		if c.protoJSON(m) {
+			return c.marshalProto(m)
+		}
+		if c.Mask != nil {
+			return c.marshalMasked(m)
+		}
+	}
 	return yaml.Marshal(v)
 }
 
-func (Codec) Unmarshal(data []byte, v interface{}) error {
-	return yaml.Unmarshal(data, v)
+func (c Codec) Unmarshal(data []byte, v interface{}) error {
+	if err := c.unmarshal(data, v); err != nil {
+		return err
+	}
+	return c.Validate.Validate(c.Name(), v)
+}
+
+func (c Codec) unmarshal(data []byte, v interface{}) error {
+	m, isProto := protoMessage(v)
+	asJSON := isProto && c.protoJSON(m)
+	if !asJSON && !c.Interpolate && c.Resolvers == nil && c.Schema == nil {
+		if isProto {
+			return c.Merge.Decode(m, func(m proto.Message) error {
+				return yaml.Unmarshal(data, m)
+			})
+		}
+		return yaml.Unmarshal(data, v)
+	}
+
+	var n yaml.Node
+	if err := yaml.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	t := reflect.TypeOf(v)
+	var md protoreflect.MessageDescriptor
+	if asJSON {
+		// the Go fields of proto messages do not tell the protojson keys.
+		t, md = nil, m.ProtoReflect().Descriptor()
+	}
+	if err := c.rewriteNode(&n, t, nil, md); err != nil {
+		return err
+	}
+	if c.Schema != nil {
+		if err := c.validateSchema(&n, md); err != nil {
+			return err
+		}
+	}
+	if n.Kind == 0 {
+		return nil
+	}
+	if asJSON {
+		return c.unmarshalProto(&n, m)
+	}
+	if isProto {
+		return c.Merge.Decode(m, func(m proto.Message) error {
+			return n.Decode(m)
+		})
+	}
+	return n.Decode(v)
 }
 
 func (Codec) Name() string {
 	return Name
 }
+
+// rewriteNode interpolates and resolves the string scalars under n, leaving
+// the mapping keys alone. t is the type n decodes into, nil if unknown, or
+// for the protojson form fd the field n is the value of, or md the message n
+// is, as for jsonValue.
+func (c Codec) rewriteNode(n *yaml.Node, t reflect.Type, fd protoreflect.FieldDescriptor, md protoreflect.MessageDescriptor) error {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if fd != nil && !fd.IsMap() {
+		md = fd.Message()
+	}
+	switch n.Kind {
+	case yaml.ScalarNode:
+		if n.ShortTag() != "!!str" {
+			return nil
+		}
+		if c.Interpolate {
+			v, err := interpolate.Expand(n.Value, c.Lookup)
+			if err != nil {
+				return err
+			}
+			if v != n.Value && n.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) == 0 {
+				// resolve the type of plain scalars from their new value.
+				n.Tag = ""
+			}
+			n.Value = v
+		}
+		b, ok, err := c.Resolvers.Resolve(n.Value)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+		if t != nil && t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			// yaml only decodes byte slices from sequences.
+			seq := yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Style: yaml.FlowStyle, Line: n.Line, Column: n.Column}
+			for _, c := range b {
+				seq.Content = append(seq.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(int(c))})
+			}
+			*n = seq
+		} else if isBytesField(fd) {
+			// protojson reads bytes as base64.
+			n.Value, n.Tag = base64.StdEncoding.EncodeToString(b), "!!str"
+		} else {
+			// secrets are strings, whatever they look like.
+			n.Value, n.Tag = string(b), "!!str"
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(n.Content); i += 2 {
+			k := n.Content[i-1]
+			if k.ShortTag() == "!!merge" {
+				// the merged mappings are of the type of n.
+				if err := c.rewriteNode(n.Content[i], t, fd, md); err != nil {
+					return err
+				}
+				continue
+			}
+			var vt reflect.Type
+			var vfd protoreflect.FieldDescriptor
+			switch {
+			case t != nil && t.Kind() == reflect.Map:
+				vt = t.Elem()
+			case t != nil && t.Kind() == reflect.Struct:
+				vt = yamlFieldType(t, k.Value)
+			case fd != nil && fd.IsMap():
+				vfd = fd.MapValue()
+			case md != nil:
+				vfd = md.Fields().ByJSONName(k.Value)
+				if vfd == nil {
+					vfd = md.Fields().ByName(protoreflect.Name(k.Value))
+				}
+			}
+			if err := c.rewriteNode(n.Content[i], vt, vfd, nil); err != nil {
+				return err
+			}
+		}
+	default:
+		var et reflect.Type
+		if n.Kind == yaml.SequenceNode && t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			et = t.Elem()
+		} else if n.Kind == yaml.DocumentNode {
+			et = t
+		}
+		for _, child := range n.Content {
+			if err := c.rewriteNode(child, et, fd, md); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// yamlFieldType returns the type of the field of the struct t that yaml
+// decodes the key into, nil if there is none.
+func yamlFieldType(t reflect.Type, key string) reflect.Type {
+	if sf, ok := yamlField(t, key); ok {
+		return sf.Type
+	}
+	return nil
+}
+
+// yamlField returns the field of the struct t that yaml decodes the key
+// into.
+func yamlField(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("yaml")
+		name, opts := tag, ""
+		if i := strings.IndexByte(tag, ','); i >= 0 {
+			name, opts = tag[:i], tag[i+1:]
+		}
+		if strings.Contains(opts, "inline") {
+			ft := sf.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if sf, ok := yamlField(ft, key); ok {
+					return sf, true
+				}
+			}
+			continue
+		}
+		if sf.PkgPath != "" || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(sf.Name)
+		}
+		if name == key {
+			return sf, true
+		}
+	}
+	return reflect.StructField{}, false
+}
@@ -1,9 +1,20 @@
 package yaml
 
 import (
+	"errors"
 	"math"
 	"reflect"
 	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	complexData "github.com/sraphs/encoding/internal/testdata/complex"
+	testData "github.com/sraphs/encoding/internal/testdata/encoding"
+	"github.com/sraphs/encoding/schema"
+	"github.com/sraphs/encoding/secret"
 )
 
 func TestCodec_Unmarshal(t *testing.T) {
@@ -102,3 +113,304 @@ func TestCodec_Marshal(t *testing.T) {
 		t.Fatalf("want \"v: hi\n\" return \"%s\"", string(got))
 	}
 }
+
+func TestCodec_Interpolate(t *testing.T) {
+	lookup := func(name string) (string, bool) {
+		v, ok := map[string]string{"HOST": "db.local", "PORT": "5433"}[name]
+		return v, ok
+	}
+	var out struct {
+		URL   string            `yaml:"url"`
+		Port  int               `yaml:"port"`
+		Tags  []string          `yaml:"tags"`
+		Attrs map[string]string `yaml:"attrs"`
+	}
+	data := `
+url: postgres://${HOST}:${PORT:-5432}/app
+port: ${PORT}
+tags: [$HOST, "$$HOST"]
+attrs:
+  ${HOST}: ${MISSING:-none}
+`
+	if err := (Codec{Interpolate: true, Lookup: lookup}).Unmarshal([]byte(data), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.URL != "postgres://db.local:5433/app" || out.Port != 5433 {
+		t.Errorf("unexpected url or port: %+v", out)
+	}
+	if !reflect.DeepEqual(out.Tags, []string{"db.local", "$HOST"}) {
+		t.Errorf("unexpected tags: %v", out.Tags)
+	}
+	if !reflect.DeepEqual(out.Attrs, map[string]string{"${HOST}": "none"}) {
+		t.Errorf("unexpected attrs: %v", out.Attrs)
+	}
+
+	if err := (Codec{Interpolate: true, Lookup: lookup}).Unmarshal([]byte("url: ${MISSING:?url}"), &out); err == nil {
+		t.Error("expected an error for a missing required variable")
+	}
+
+	// without Interpolate, references are left as is.
+	if err := (Codec{}).Unmarshal([]byte("url: ${HOST}"), &out); err != nil || out.URL != "${HOST}" {
+		t.Errorf("unexpected interpolation: %v %v", out.URL, err)
+	}
+}
+
+func TestCodec_Secrets(t *testing.T) {
+	codec := Codec{Resolvers: secret.Resolvers{
+		"secretref": secret.Memory{"password": []byte("hunter2"), "flag": []byte("true")},
+	}}
+	var out struct {
+		Password string   `yaml:"password"`
+		Key      []byte   `yaml:"key"`
+		Flag     string   `yaml:"flag"`
+		List     []string `yaml:"list"`
+	}
+	data := `
+password: secretref://password
+key: secretref://password
+flag: secretref://flag
+list: [a, "secretref://password"]
+`
+	if err := codec.Unmarshal([]byte(data), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Password != "hunter2" || string(out.Key) != "hunter2" || out.Flag != "true" {
+		t.Errorf("unexpected values: %+v", out)
+	}
+	if !reflect.DeepEqual(out.List, []string{"a", "hunter2"}) {
+		t.Errorf("unexpected list: %v", out.List)
+	}
+	if err := codec.Unmarshal([]byte("password: secretref://missing"), &out); err == nil {
+		t.Error("expected an error for a missing secret")
+	}
+
+	// proto messages, the bytes fields in both forms.
+	m := &complexData.Complex{}
+	data = `
+numberOne: secretref://password
+byte: secretref://password
+bytes: secretref://password
+string: secretref://password
+simples: [secretref://password]
+map: {k: secretref://password}
+very_simple: {component: secretref://password}
+`
+	codec.ProtoJSON = true
+	if err := codec.Unmarshal([]byte(data), m); err != nil {
+		t.Fatal(err)
+	}
+	if m.NoOne != "hunter2" || string(m.Byte) != "hunter2" || string(m.Bytes.GetValue()) != "hunter2" {
+		t.Errorf("unexpected message: %v", m)
+	}
+	if m.String_.GetValue() != "hunter2" || m.Simples[0] != "hunter2" || m.Map["k"] != "hunter2" || m.Simple.Component != "hunter2" {
+		t.Errorf("unexpected nested message fields: %v", m)
+	}
+
+	m = &complexData.Complex{}
+	codec.ProtoJSON = false
+	if err := codec.Unmarshal([]byte("noone: secretref://password\nbyte: secretref://password\n"), m); err != nil {
+		t.Fatal(err)
+	}
+	if m.NoOne != "hunter2" || string(m.Byte) != "hunter2" {
+		t.Errorf("unexpected message: %v", m)
+	}
+}
+
+func TestCodec_Redact(t *testing.T) {
+	in := struct {
+		User     string `yaml:"user"`
+		Password string `yaml:"password" sensitive:"true"`
+	}{User: "sraph", Password: "hunter2"}
+
+	b, err := (Codec{Redact: true}).Marshal(&in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "user: sraph\npassword: '[REDACTED]'\n"; string(b) != want {
+		t.Errorf("expected %q, got %q", want, b)
+	}
+}
+
+func TestCodec_Proto(t *testing.T) {
+	in := &testData.TestModel{Id: 1, Name: "123", Hobby: []string{"study", "true"}}
+	for _, tt := range []struct {
+		codec Codec
+		want  string
+	}{
+		{Codec{}, "id: 1\nname: \"123\"\nhobby:\n    - study\n    - \"true\"\nattrs: {}\n"},
+		{Codec{ProtoJSON: true}, "id: \"1\"\nname: \"123\"\nhobby:\n    - study\n    - \"true\"\nattrs: {}\n"},
+	} {
+		b, err := tt.codec.Marshal(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tt.want {
+			t.Errorf("expected %q, got %q", tt.want, b)
+		}
+
+		// the scalars of string fields are strings, whatever they look like.
+		out := &testData.TestModel{}
+		if err := tt.codec.Unmarshal([]byte("id: 1\nname: 123\nhobby: [study, true]\nunknown: x\n"), out); err != nil {
+			t.Fatal(err)
+		}
+		if !proto.Equal(in, out) {
+			t.Errorf("expected %v, got %v", in, out)
+		}
+
+		var ptr *testData.TestModel
+		if err := tt.codec.Unmarshal(b, &ptr); err != nil {
+			t.Fatal(err)
+		}
+		if !proto.Equal(in, ptr) {
+			t.Errorf("expected %v, got %v", in, ptr)
+		}
+	}
+}
+
+func TestCodec_ProtoForm(t *testing.T) {
+	in := &complexData.Complex{
+		Id:       2233,
+		NoOne:    "x",
+		Simple:   &complexData.Simple{Component: "c"},
+		Sex:      complexData.Sex_woman,
+		Duration: &durationpb.Duration{Seconds: 3},
+		String_:  wrapperspb.String("s"),
+	}
+	for _, tt := range []struct {
+		codec Codec
+		want  string
+	}{
+		// lowercased Go names, enums as numbers and the well-known types as
+		// messages.
+		{Codec{}, "id: 2233\nnoone: x\nsimple:\n    component: c\nsimples: []\nb: false\nsex: 1\n" +
+			"age: 0\na: 0\ncount: 0\nprice: 0\nd: 0\nbyte: []\ntimestamp: null\nduration:\n    seconds: 3\n    nanos: 0\n" +
+			"field: null\ndouble: null\nfloat: null\nint64: null\nint32: null\nuint64: null\nuint32: null\nbool: null\n" +
+			"string_:\n    value: s\nbytes: null\nmap: {}\n"},
+		// JSON names, 64-bit integers as strings, enums by name and the JSON
+		// forms of the well-known types.
+		{Codec{ProtoJSON: true}, "id: \"2233\"\nnumberOne: x\nvery_simple:\n    component: c\nsimples: []\nb: false\nsex: woman\n" +
+			"age: 0\na: 0\ncount: \"0\"\nprice: 0\nd: 0\nbyte: \"\"\ntimestamp: null\nduration: 3s\nfield: null\n" +
+			"double: null\nfloat: null\nint64: null\nint32: null\nuint64: null\nuint32: null\nbool: null\n" +
+			"string: s\nbytes: null\nmap: {}\n"},
+	} {
+		b, err := tt.codec.Marshal(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tt.want {
+			t.Errorf("expected %q, got %q", tt.want, b)
+		}
+
+		out := &complexData.Complex{}
+		if err := tt.codec.Unmarshal(b, out); err != nil {
+			t.Fatal(err)
+		}
+		if !proto.Equal(in, out) {
+			t.Errorf("expected %v, got %v", in, out)
+		}
+	}
+}
+
+func TestCodec_Mask(t *testing.T) {
+	in := &testData.TestModel{Id: 1, Name: "sraph", Hobby: []string{"study"}}
+	mask := &fieldmaskpb.FieldMask{Paths: []string{"name", "hobby"}}
+	for _, tt := range []struct {
+		codec Codec
+		want  string
+	}{
+		{Codec{Mask: mask}, "name: sraph\nhobby:\n    - study\n"},
+		{Codec{Mask: mask, ProtoJSON: true}, "hobby:\n    - study\nname: sraph\n"},
+	} {
+		b, err := tt.codec.Marshal(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tt.want {
+			t.Errorf("expected %q, got %q", tt.want, b)
+		}
+
+		out := &testData.TestModel{}
+		fm, err := tt.codec.UnmarshalMask([]byte("name: sraph\nattrs:\n  k: v\n"), out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"attrs", "name"}; !reflect.DeepEqual(want, fm.GetPaths()) {
+			t.Errorf("expected %v, got %v", want, fm.GetPaths())
+		}
+		if out.Name != "sraph" {
+			t.Errorf("unexpected message %v", out)
+		}
+	}
+
+	// the nested messages are masked field by field.
+	c := &complexData.Complex{Id: 1, Simple: &complexData.Simple{Component: "c"}}
+	b, err := (Codec{Mask: &fieldmaskpb.FieldMask{Paths: []string{"very_simple.component"}}}).Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "simple:\n    component: c\n"; string(b) != want {
+		t.Errorf("expected %q, got %q", want, b)
+	}
+	fm, err := (Codec{}).UnmarshalMask([]byte("noone: x\nsimple: {component: c}\n"), &complexData.Complex{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"no_one", "simple.component"}; !reflect.DeepEqual(want, fm.GetPaths()) {
+		t.Errorf("expected %v, got %v", want, fm.GetPaths())
+	}
+}
+
+func TestCodec_Schema(t *testing.T) {
+	s, err := schema.Generate(&testData.TestModel{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := schema.NewValidator(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the generated schemas describe the protojson form.
+	c := Codec{Schema: v, ProtoJSON: true}
+
+	out := &testData.TestModel{}
+	if err := c.Unmarshal([]byte("id: 1\nname: 123\nhobby: [a]\n"), out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Name != "123" {
+		t.Errorf("unexpected message %v", out)
+	}
+
+	err = c.Unmarshal([]byte("name: sraph\nhobby:\n  - a\n  - {b: c}\nid: x\n"), &testData.TestModel{})
+	var serr *schema.Error
+	if !errors.As(err, &serr) {
+		t.Fatalf("expected a *schema.Error, got %v", err)
+	}
+	got := map[string][2]int{}
+	for _, v := range serr.Violations {
+		got[v.Pointer] = [2]int{v.Line, v.Column}
+	}
+	if want := map[string][2]int{"/hobby/1": {4, 5}, "/id": {5, 5}}; !reflect.DeepEqual(want, got) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	// Go values, with anchors and merge keys.
+	v, err = schema.Compile([]byte(`{"properties": {"port": {"type": "integer"}}, "additionalProperties": {"properties": {"port": {"type": "integer"}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]interface{}
+	err = Codec{Schema: v}.Unmarshal([]byte("base: &base\n  port: x\nserver:\n  <<: *base\nport: 1\n"), &m)
+	if !errors.As(err, &serr) {
+		t.Fatalf("expected a *schema.Error, got %v", err)
+	}
+	got = map[string][2]int{}
+	for _, v := range serr.Violations {
+		got[v.Pointer] = [2]int{v.Line, v.Column}
+	}
+	if want := map[string][2]int{"/base/port": {2, 9}, "/server/port": {2, 9}}; !reflect.DeepEqual(want, got) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if m != nil {
+		t.Errorf("an invalid document was decoded: %v", m)
+	}
+}